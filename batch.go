@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/batch"
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/hubbleapi"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// runBatch provisions every board listed in the --manifest file, one after another.
+// Credentials are resolved and dependencies installed once up front; a failing row
+// is recorded in the report and the remaining rows are still attempted.
+func (s *session) runBatch() error {
+	entries, err := batch.Load(s.opts.manifest, s.opts.catalog)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Invalid manifest: %v", err))
+		return err
+	}
+	ui.PrintInfo(fmt.Sprintf("Loaded %d boards from %s", len(entries), s.opts.manifest))
+	for _, entry := range entries {
+		ui.Redact(entry.DeviceKey)
+	}
+
+	if err := s.checkReboot(); err != nil {
+		return err
+	}
+	if err := s.configureCredentials(); err != nil {
+		return err
+	}
+	if err := s.checkRegistration(entries); err != nil {
+		return err
+	}
+
+	// Collect the dependencies of every board in the manifest
+	var deps []string
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		board, _ := s.opts.catalog.Board(entry.Board)
+		for _, dep := range s.dependencies(board) {
+			if !seen[dep] {
+				seen[dep] = true
+				deps = append(deps, dep)
+			}
+		}
+	}
+
+	missing, err := s.checkPrerequisites(deps)
+	if err != nil {
+		return err
+	}
+	if err := s.installDependencies(missing, deps); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	if !s.confirm(fmt.Sprintf("Provision %d boards now?", len(entries))) {
+		ui.PrintWarning("Batch provisioning cancelled")
+		return nil
+	}
+
+	s.beginStep("provision", "Provisioning boards")
+	results := make([]batch.Result, 0, len(entries))
+	failed := 0
+	for i, entry := range entries {
+		fmt.Println()
+		label := entry.Board
+		if entry.DeviceName != "" {
+			label = fmt.Sprintf("%s (%s)", entry.DeviceName, entry.Board)
+		}
+		ui.PrintInfo(fmt.Sprintf("[%d/%d] %s, manifest line %d", i+1, len(entries), label, entry.Line))
+
+		result := s.provision(entry)
+		if result.Status == batch.StatusFailed {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	reportPath := s.opts.report
+	if reportPath == "" {
+		reportPath = batch.DefaultReportPath(s.opts.manifest)
+	}
+
+	fmt.Println()
+	if s.opts.dryRun {
+		ui.PrintInfo(fmt.Sprintf("[dry run] would write report to %s", reportPath))
+	} else if err := batch.WriteReport(reportPath, results); err != nil {
+		ui.PrintError(fmt.Sprintf("Could not write report: %v", err))
+	} else {
+		ui.PrintInfo(fmt.Sprintf("Report written to %s", reportPath))
+	}
+
+	if failed > 0 {
+		err := errors.New("one or more boards failed to provision")
+		s.endStep(err)
+		ui.PrintResult(results)
+		ui.PrintWarning(fmt.Sprintf("%d of %d boards provisioned, %d failed", len(results)-failed, len(results), failed))
+		return err
+	}
+	s.endStep(nil)
+	ui.PrintResult(results)
+	ui.PrintSuccess(fmt.Sprintf("All %d boards provisioned in %s", len(results), time.Since(s.startTime).Round(time.Second)))
+	return nil
+}
+
+// provision flashes or generates a hex file for a single manifest entry
+func (s *session) provision(entry batch.Entry) batch.Result {
+	start := time.Now()
+	result := batch.Result{Entry: entry, DeviceName: entry.DeviceName}
+
+	board, _ := s.opts.catalog.Board(entry.Board)
+	s.cfg.Board = board.ID
+
+	flashResult, err := s.provisionBoard(board, entry, &result)
+	result.Duration = time.Since(start)
+
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Line %d failed: %v", entry.Line, err))
+		result.Status = batch.StatusFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = batch.StatusSuccess
+	if flashResult.DeviceName != "" {
+		result.DeviceName = flashResult.DeviceName
+	}
+	result.HexFilePath = flashResult.HexFilePath
+	if result.HexFilePath != "" {
+		ui.PrintSuccess(fmt.Sprintf("Hex file generated: %s", result.HexFilePath))
+	}
+	return result
+}
+
+// provisionBoard registers the entry's device and flashes it or generates its hex file
+func (s *session) provisionBoard(board *boards.Board, entry batch.Entry, result *batch.Result) (*platform.FlashResult, error) {
+	if err := s.checkMode(board); err != nil {
+		return nil, err
+	}
+
+	var registered *hubbleapi.Device
+	if entry.Registered() {
+		registered = &hubbleapi.Device{ID: entry.DeviceID, Key: entry.DeviceKey}
+	}
+	req, err := s.newFlashRequest(board, entry.DeviceName, entry.ProbeSerial, registered)
+	if err != nil {
+		return nil, err
+	}
+	result.DeviceID = req.DeviceID
+	result.ToolVersion = req.ToolVersion
+
+	if s.flashesDirectly(board) {
+		return s.installer.FlashBoard(req)
+	}
+	return s.installer.GenerateHexFile(req)
+}
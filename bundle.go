@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/bundle"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+const bundleUsageText = `Usage: hubble-install bundle <command> [flags]
+
+Commands:
+  create          Download the installers, Python runtime and pyhubbledemo into a bundle
+  verify <path>   Check a bundle against its checksums and describe its contents
+
+Create the bundle on a machine with internet access and the same operating
+system and architecture as the offline machines, then copy it over and pass
+it to install, flash, hex or check with --bundle.
+`
+
+// runBundle creates and verifies offline bundles
+func runBundle(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, bundleUsageText)
+		return exitError
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "create":
+		return runBundleCreate(args)
+	case "verify":
+		return runBundleVerify(args)
+	case "help", "-h", "--help":
+		fmt.Print(bundleUsageText)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown bundle command: %s\n\n", command)
+		fmt.Fprint(os.Stderr, bundleUsageText)
+		return exitError
+	}
+}
+
+// runBundleCreate downloads everything the given boards need into an offline bundle
+func runBundleCreate(args []string) int {
+	fs := flag.NewFlagSet("bundle create", flag.ContinueOnError)
+	board := fs.String("board", "", "only bundle what this board needs (default every board in the catalog)")
+	boardsFile := fs.String("boards-file", "", "board catalog to use instead of the built-in one (file path or URL)")
+	toolVersion := fs.String("tool-version", platform.DefaultToolVersion, "pyhubbledemo release to bundle, or \"latest\" for the newest on PyPI")
+	out := fs.String("out", fmt.Sprintf("hubble-bundle-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH), "where to write the bundle")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	catalog, code := loadBoards(*boardsFile)
+	if code >= 0 {
+		return code
+	}
+	if err := platform.ValidateToolVersion(*toolVersion); err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+
+	targets := catalog.Boards
+	if *board != "" {
+		b, err := catalog.Board(*board)
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("Invalid board: %v", err))
+			return exitError
+		}
+		targets = []boards.Board{*b}
+	}
+	var deps []string
+	for _, b := range targets {
+		for _, dep := range b.GetDependencies() {
+			if !slices.Contains(deps, dep) {
+				deps = append(deps, dep)
+			}
+		}
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Creating an offline bundle for %s/%s with %s", runtime.GOOS, runtime.GOARCH, strings.Join(deps, ", ")))
+	if err := platform.CreateBundle(platform.ExecRunner{}, deps, *toolVersion, *out); err != nil {
+		ui.PrintError(fmt.Sprintf("Could not create the offline bundle: %v", err))
+		return exitCodeFor(err)
+	}
+	ui.PrintSuccess(fmt.Sprintf("Offline bundle written to %s", *out))
+	ui.PrintInfo(fmt.Sprintf("Copy it to the offline machine and run: hubble-install --bundle %s", *out))
+	return exitOK
+}
+
+// bundleSummary describes a verified bundle
+type bundleSummary struct {
+	Platform    string   `json:"platform"`
+	ToolVersion string   `json:"tool_version"`
+	Created     string   `json:"created"`
+	Files       int      `json:"files"`
+	Installers  []string `json:"installers"`
+}
+
+// runBundleVerify checks a bundle against its manifest and describes it
+func runBundleVerify(args []string) int {
+	fs := flag.NewFlagSet("bundle verify", flag.ContinueOnError)
+	output := fs.String("output", "human", "output format: human or json")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: hubble-install bundle verify [flags] <path>")
+		return exitError
+	}
+	bundlePath := fs.Arg(0)
+	if code := setOutput(*output); code >= 0 {
+		return code
+	}
+
+	b, err := bundle.Open(bundlePath)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Invalid offline bundle: %v", err))
+		return exitError
+	}
+	defer b.Close()
+
+	summary := bundleSummary{
+		Platform:    b.Manifest.Platform,
+		ToolVersion: b.Manifest.ToolVersion,
+		Created:     b.Manifest.Created.Format("2006-01-02 15:04 MST"),
+		Files:       len(b.Manifest.Files),
+	}
+	for url := range b.Manifest.Downloads {
+		summary.Installers = append(summary.Installers, path.Base(url))
+	}
+	slices.Sort(summary.Installers)
+
+	if !ui.IsHuman() {
+		ui.PrintResult(summary)
+		return exitOK
+	}
+	ui.PrintSuccess(fmt.Sprintf("All %d files match their checksums", summary.Files))
+	fmt.Printf("  Platform:      %s\n", summary.Platform)
+	fmt.Printf("  pyhubbledemo:  %s\n", summary.ToolVersion)
+	fmt.Printf("  Created:       %s\n", summary.Created)
+	fmt.Printf("  Installers:    %s\n", strings.Join(summary.Installers, ", "))
+	return exitOK
+}
+
+// openBundle opens and verifies the --bundle given in opts, if any. The bundle
+// decides the pyhubbledemo release, as it holds no other; asking for another
+// one explicitly is an error.
+func openBundle(opts *options) (*bundle.Bundle, error) {
+	if opts.bundle == "" {
+		return nil, nil
+	}
+	b, err := bundle.Open(opts.bundle)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Invalid offline bundle: %v", err))
+		return nil, err
+	}
+
+	version := b.Manifest.ToolVersion
+	if opts.toolVersionSet && opts.toolVersion != version && opts.toolVersion != platform.LatestToolVersion {
+		b.Close()
+		err := fmt.Errorf("the offline bundle holds pyhubbledemo %s, not %s", version, opts.toolVersion)
+		ui.PrintError(err.Error())
+		return nil, err
+	}
+	opts.toolVersion = version
+	ui.PrintInfo(fmt.Sprintf("Installing from the offline bundle %s (pyhubbledemo %s)", opts.bundle, version))
+	// An offline machine cannot reach the Hubble API to check the credentials
+	opts.skipVerify = true
+	return b, nil
+}
+
+// closeBundle removes the files unpacked from an offline bundle, if any
+func closeBundle(b *bundle.Bundle) {
+	if b != nil {
+		b.Close()
+	}
+}
+
+// newInstaller returns the installer for this platform, which takes everything
+// from the offline bundle b when there is one
+func newInstaller(runner platform.Runner, b *bundle.Bundle) (platform.Installer, error) {
+	if b != nil {
+		return platform.NewBundleInstaller(runtime.GOOS, runner, b)
+	}
+	return platform.NewInstaller(runtime.GOOS, runner)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/bundle"
+	"github.com/HubbleNetwork/hubble-install/internal/config"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+)
+
+// flashInstaller is a stepInstaller that records the boards it is asked to flash
+type flashInstaller struct {
+	stepInstaller
+	flashed []platform.FlashRequest
+}
+
+func (i *flashInstaller) FlashBoard(req platform.FlashRequest) (*platform.FlashResult, error) {
+	i.flashed = append(i.flashed, req)
+	return &platform.FlashResult{DeviceID: req.DeviceID, DeviceName: req.DeviceName, ToolVersion: req.ToolVersion}, nil
+}
+
+// fakeBundle builds an offline bundle holding a stand-in uv release and
+// pyhubbledemo toolVersion, and returns its path
+func fakeBundle(t *testing.T, toolVersion string) string {
+	t.Helper()
+	dir := t.TempDir()
+	downloads := filepath.Join(dir, bundle.DownloadsDir)
+	if err := os.MkdirAll(downloads, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(downloads, "uv.tar.gz"), []byte("not really uv"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "hubble-bundle.tar.gz")
+	manifest := bundle.Manifest{
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+		ToolVersion: toolVersion,
+		Downloads:   map[string]string{"https://example.com/uv.tar.gz": bundle.DownloadsDir + "/uv.tar.gz"},
+	}
+	if err := bundle.Write(dir, manifest, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// offlineSession opens the bundle at path as the --bundle of a batch run from
+// manifest, on a machine where the Hubble API cannot be reached. It returns the
+// session and the number of requests that reached the API.
+func offlineSession(t *testing.T, path, manifest string) (*session, *flashInstaller, func() int32) {
+	t.Helper()
+	isolateConfig(t)
+	server, requests := hubbleAPI(t, http.StatusOK)
+	server.Close()
+
+	opts := &options{
+		bundle:   path,
+		manifest: manifest,
+		report:   filepath.Join(t.TempDir(), "report.csv"),
+		yes:      true,
+		catalog:  boards.Default(),
+	}
+	b, err := openBundle(opts)
+	if err != nil {
+		t.Fatalf("openBundle() = %v", err)
+	}
+	t.Cleanup(func() { closeBundle(b) })
+
+	runner := platformtest.NewRunner("uv").
+		On(platformtest.Response{Match: "/fake/bin/uv tool run", Output: "Usage: hubbledemo flash [--key KEY] [--serial SERIAL] BOARD\n"})
+	installer := &flashInstaller{}
+	s := &session{
+		opts:      opts,
+		runner:    runner,
+		installer: installer,
+		bundle:    b,
+		cfg:       &config.Config{OrgID: testOrgID, APIToken: testAPIToken},
+	}
+	return s, installer, requests.Load
+}
+
+// writeManifest writes a batch manifest for the test and returns its path
+func writeManifest(t *testing.T, rows ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "devices.csv")
+	data := strings.Join(append([]string{"board,device_name,device_id,device_key"}, rows...), "\n") + "\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenBundleImpliesSkipVerify(t *testing.T) {
+	opts := &options{bundle: fakeBundle(t, "0.91.0")}
+	b, err := openBundle(opts)
+	if err != nil {
+		t.Fatalf("openBundle() = %v", err)
+	}
+	defer closeBundle(b)
+
+	if !opts.skipVerify {
+		t.Errorf("--bundle left the credential check on")
+	}
+	if opts.toolVersion != "0.91.0" {
+		t.Errorf("tool version %q, want the bundled 0.91.0", opts.toolVersion)
+	}
+}
+
+func TestOfflineBatchFlashesRegisteredDevices(t *testing.T) {
+	manifest := writeManifest(t,
+		"nrf52840dk,line-a-1,dev-1,a2V5LTE=",
+		"nrf52840dk,line-a-2,dev-2,a2V5LTI=",
+	)
+	s, installer, requests := offlineSession(t, fakeBundle(t, "0.92.0"), manifest)
+
+	if err := s.runBatch(); err != nil {
+		t.Fatalf("runBatch() = %v", err)
+	}
+	if requests() != 0 {
+		t.Errorf("offline batch sent %d requests to the Hubble API", requests())
+	}
+	if len(installer.flashed) != 2 {
+		t.Fatalf("flashed %d boards, want 2", len(installer.flashed))
+	}
+	for i, req := range installer.flashed {
+		wantID, wantKey := []string{"dev-1", "dev-2"}[i], []string{"a2V5LTE=", "a2V5LTI="}[i]
+		if req.DeviceID != wantID || req.DeviceKey != wantKey || req.ToolVersion != "0.92.0" {
+			t.Errorf("flashed %s with key %q and pyhubbledemo %s, want %s with %q and 0.92.0", req.DeviceID, req.DeviceKey, req.ToolVersion, wantID, wantKey)
+		}
+	}
+}
+
+func TestOfflineRunNeedingRegistrationFailsUpFront(t *testing.T) {
+	path := fakeBundle(t, "0.93.0")
+
+	t.Run("batch", func(t *testing.T) {
+		manifest := writeManifest(t,
+			"nrf52840dk,line-a-1,dev-1,a2V5LTE=",
+			"nrf52840dk,line-a-2,,",
+		)
+		s, installer, _ := offlineSession(t, path, manifest)
+		out := captureMessages(t)
+
+		var networkErr *platform.NetworkError
+		if err := s.runBatch(); !errors.As(err, &networkErr) {
+			t.Fatalf("runBatch() = %v, want a NetworkError", err)
+		}
+		if installer.checked != 0 || len(installer.flashed) != 0 {
+			t.Errorf("checked dependencies %d times and flashed %d boards before failing", installer.checked, len(installer.flashed))
+		}
+		if !strings.Contains(out.printed(), "register the devices on a machine with internet access") {
+			t.Errorf("printed %q, want instructions to register the devices in advance", out.printed())
+		}
+	})
+
+	t.Run("single board", func(t *testing.T) {
+		s, installer, _ := offlineSession(t, path, "")
+		s.opts.board = "nrf52840dk"
+
+		var networkErr *platform.NetworkError
+		if err := s.prepare(); !errors.As(err, &networkErr) {
+			t.Fatalf("prepare() = %v, want a NetworkError", err)
+		}
+		if installer.checked != 0 {
+			t.Errorf("checked dependencies before failing")
+		}
+	})
+}
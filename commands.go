@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// runInstall runs the full guided installation: credentials, board, dependencies, then flash or hex
+func runInstall(args []string) int {
+	opts := &options{}
+	fs := newFlagSet("install", opts)
+	if code := parseOptions(fs, opts, args); code >= 0 {
+		return code
+	}
+
+	// Print welcome banner
+	ui.PrintBanner()
+	fmt.Println()
+
+	if !opts.yes {
+		// Show what will happen
+		ui.PrintInfo("This installer will:")
+		fmt.Println("  • Confirm your developer board model")
+		fmt.Println("  • Check for and install required dependencies")
+		fmt.Println("  • Configure your Hubble credentials")
+		fmt.Println("  • Register your board to your organization, and give it a name")
+		fmt.Println("  • Provision your board, or generate a hex file for you to flash")
+		fmt.Println()
+
+		if !ui.PromptYesNo("Ready to install?", true) {
+			ui.PrintWarning("Installation cancelled")
+			return exitCancelled
+		}
+		fmt.Println()
+	}
+
+	j := resumeJournal(opts, "install")
+	s, err := newSession(opts)
+	if err != nil {
+		return exitError
+	}
+	defer s.close()
+	s.journal = j
+
+	if opts.manifest != "" {
+		return exitCodeFor(s.runBatch())
+	}
+
+	if err := s.prepare(); err != nil {
+		return exitCodeFor(err)
+	}
+
+	if s.flashesDirectly(&s.board) {
+		return s.exit(s.flash())
+	}
+	return s.exit(s.generateHex())
+}
+
+// runFlash flashes a board that is programmed directly without the guided introduction
+func runFlash(args []string) int {
+	opts := &options{}
+	fs := newFlagSet("flash", opts)
+	if code := parseOptions(fs, opts, args); code >= 0 {
+		return code
+	}
+
+	j := resumeJournal(opts, "flash")
+	s, err := newSession(opts)
+	if err != nil {
+		return exitError
+	}
+	defer s.close()
+	s.journal = j
+	s.mode = modeFlash
+	if opts.manifest != "" {
+		return exitCodeFor(s.runBatch())
+	}
+	if err := s.prepare(); err != nil {
+		return exitCodeFor(err)
+	}
+	return s.exit(s.flash())
+}
+
+// runHex generates a hex file for a UniFlash board without the guided introduction
+func runHex(args []string) int {
+	opts := &options{}
+	fs := newFlagSet("hex", opts)
+	if code := parseOptions(fs, opts, args); code >= 0 {
+		return code
+	}
+
+	j := resumeJournal(opts, "hex")
+	s, err := newSession(opts)
+	if err != nil {
+		return exitError
+	}
+	defer s.close()
+	s.journal = j
+	s.mode = modeHex
+	if opts.manifest != "" {
+		return exitCodeFor(s.runBatch())
+	}
+	if err := s.prepare(); err != nil {
+		return exitCodeFor(err)
+	}
+	return s.exit(s.generateHex())
+}
+
+// checkResult is the structured outcome of checking one board's prerequisites
+type checkResult struct {
+	Board   string                       `json:"board"`
+	Missing []platform.MissingDependency `json:"missing"`
+	Error   string                       `json:"error,omitempty"`
+}
+
+// runCheck reports missing dependencies for one board, or for every board if none is given
+func runCheck(args []string) int {
+	opts := &options{}
+	fs := newFlagSet("check", opts)
+	if code := parseOptions(fs, opts, args); code >= 0 {
+		return code
+	}
+
+	b, err := openBundle(opts)
+	if err != nil {
+		return exitError
+	}
+	defer closeBundle(b)
+
+	installer, err := newInstaller(platform.ExecRunner{}, b)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Platform detection failed: %v", err))
+		return exitError
+	}
+
+	targets := opts.catalog.Boards
+	if opts.board != "" {
+		board, err := opts.catalog.Board(opts.board)
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("Invalid board: %v", err))
+			return exitError
+		}
+		targets = []boards.Board{*board}
+	}
+
+	if err := installer.CheckPendingReboot(); err != nil {
+		ui.PrintWarning(fmt.Sprintf("System reboot pending: %v", err))
+		return exitReboot
+	}
+
+	code := exitOK
+	results := make([]checkResult, 0, len(targets))
+	for _, board := range targets {
+		missing, err := installer.CheckPrerequisites(board.GetDependencies())
+		if missing == nil {
+			missing = []platform.MissingDependency{}
+		}
+		result := checkResult{Board: board.ID, Missing: missing}
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("%s: %v", board.Name, err))
+			result.Error = err.Error()
+			results = append(results, result)
+			code = exitError
+			continue
+		}
+		results = append(results, result)
+		if len(missing) == 0 {
+			ui.PrintSuccess(fmt.Sprintf("%s: all prerequisites satisfied", board.Name))
+			continue
+		}
+		ui.PrintWarning(fmt.Sprintf("%s: missing dependencies", board.Name))
+		for _, dep := range missing {
+			fmt.Printf("  • %s: %s\n", dep.Name, dep.Status)
+		}
+		if code == exitOK {
+			code = exitDependencyMissing
+		}
+	}
+
+	ui.PrintResult(results)
+	return code
+}
+
+// runBoards prints the supported developer boards
+func runBoards(args []string) int {
+	fs := flag.NewFlagSet("boards", flag.ContinueOnError)
+	output := fs.String("output", "human", "output format: human or json")
+	boardsFile := fs.String("boards-file", "", "board catalog to list instead of the built-in one (file path or URL)")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := setOutput(*output); code >= 0 {
+		return code
+	}
+	catalog, code := loadBoards(*boardsFile)
+	if code >= 0 {
+		return code
+	}
+	if !ui.IsHuman() {
+		ui.PrintResult(catalog.Boards)
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tVENDOR\tFLASH METHOD\tALIASES")
+	for _, board := range catalog.Boards {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", board.ID, board.Name, board.Vendor, board.FlashMethod, strings.Join(board.Aliases, ", "))
+	}
+	w.Flush()
+
+	return exitOK
+}
+
+// runProbes prints the J-Link probes connected to this machine
+func runProbes(args []string) int {
+	fs := flag.NewFlagSet("probes", flag.ContinueOnError)
+	output := fs.String("output", "human", "output format: human or json")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := setOutput(*output); code >= 0 {
+		return code
+	}
+
+	probes, err := platform.ListProbes()
+	if err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+	if !ui.IsHuman() {
+		if probes == nil {
+			probes = []platform.Probe{}
+		}
+		ui.PrintResult(probes)
+		return exitOK
+	}
+	if len(probes) == 0 {
+		ui.PrintWarning("No J-Link probes detected")
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERIAL\tPRODUCT\tCONNECTION")
+	for _, probe := range probes {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", probe.Serial, probe.Product, probe.Connection)
+	}
+	w.Flush()
+
+	return exitOK
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/batch"
+	"github.com/HubbleNetwork/hubble-install/internal/config"
+	"github.com/HubbleNetwork/hubble-install/internal/hubbleapi"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+const devicesUsageText = `Usage: hubble-install devices <command> [flags]
+
+Commands:
+  list                      List the devices in your organization
+  rename <device> <name>    Rename a device (by device ID or current name)
+  delete <device>           Delete a device (by device ID or current name)
+  register                  Register devices in advance, for provisioning offline
+
+Flags:
+  --org-id <id>       Hubble Org ID (overrides HUBBLE_ORG_ID)
+  --profile <name>    saved credential profile to use
+  --output <format>   output format for list: human or json (default human)
+  --yes               do not ask for confirmation or prompt for credentials
+
+Register flags:
+  --board <id>          board the devices will be flashed on (required)
+  --count <n>           number of devices to register (default 1)
+  --name-prefix <name>  name the devices <name>-1, <name>-2, ...
+  --out <file>          manifest to write the device IDs and keys to (default devices.csv)
+  --boards-file <path>  board catalog to check --board against (file path or URL)
+`
+
+// deviceOptions holds the flags of the devices subcommands
+type deviceOptions struct {
+	orgID   string
+	profile string
+	output  string
+	yes     bool
+
+	// Flags of the register command
+	board      string
+	count      int
+	namePrefix string
+	out        string
+	boardsFile string
+}
+
+// runDevices manages the devices registered to the organization
+func runDevices(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, devicesUsageText)
+		return exitError
+	}
+
+	command, args := args[0], args[1:]
+	opts := &deviceOptions{}
+	fs := flag.NewFlagSet("devices "+command, flag.ContinueOnError)
+	fs.StringVar(&opts.orgID, "org-id", "", "Hubble Org ID (overrides HUBBLE_ORG_ID and HUBBLE_CREDENTIALS)")
+	fs.StringVar(&opts.profile, "profile", "", "saved credential profile to use")
+	fs.StringVar(&opts.output, "output", "human", "output format for list: human or json")
+	fs.BoolVar(&opts.yes, "yes", false, "do not ask for confirmation or prompt for credentials")
+
+	switch command {
+	case "list":
+		if code := parseFlags(fs, args); code >= 0 {
+			return code
+		}
+		return runDevicesList(opts)
+	case "rename":
+		positional, code := parseDeviceArgs(fs, args, 2)
+		if code >= 0 {
+			return code
+		}
+		return runDevicesRename(opts, positional[0], positional[1])
+	case "delete":
+		positional, code := parseDeviceArgs(fs, args, 1)
+		if code >= 0 {
+			return code
+		}
+		return runDevicesDelete(opts, positional[0])
+	case "register":
+		fs.StringVar(&opts.board, "board", "", "board the devices will be flashed on")
+		fs.IntVar(&opts.count, "count", 1, "number of devices to register")
+		fs.StringVar(&opts.namePrefix, "name-prefix", "", "name the devices <name>-1, <name>-2, ...")
+		fs.StringVar(&opts.out, "out", "devices.csv", "manifest to write the device IDs and keys to")
+		fs.StringVar(&opts.boardsFile, "boards-file", "", "board catalog to check --board against (file path or URL)")
+		if code := parseFlags(fs, args); code >= 0 {
+			return code
+		}
+		return runDevicesRegister(opts)
+	case "help", "-h", "--help":
+		fmt.Print(devicesUsageText)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown devices command: %s\n\n", command)
+		fmt.Fprint(os.Stderr, devicesUsageText)
+		return exitError
+	}
+}
+
+// runDevicesList prints the organization's devices as a table or JSON
+func runDevicesList(opts *deviceOptions) int {
+	// table is the name the human format had before it matched the other commands
+	if opts.output == "table" {
+		opts.output = "human"
+	}
+	if code := setOutput(opts.output); code >= 0 {
+		return code
+	}
+
+	client, err := deviceClient(opts)
+	if err != nil {
+		return exitCodeFor(classifyAPIError(err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	devices, err := client.ListDevices(ctx)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Could not list devices: %v", err))
+		return exitCodeFor(classifyAPIError(err))
+	}
+
+	if opts.output == "json" {
+		if devices == nil {
+			devices = []hubbleapi.Device{}
+		}
+		ui.PrintResult(devices)
+		return exitOK
+	}
+
+	if len(devices) == 0 {
+		ui.PrintInfo("No devices registered to this organization")
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DEVICE ID\tNAME")
+	for _, device := range devices {
+		fmt.Fprintf(w, "%s\t%s\n", device.ID, device.Name)
+	}
+	w.Flush()
+
+	return exitOK
+}
+
+// runDevicesRename renames the device identified by ref
+func runDevicesRename(opts *deviceOptions, ref, name string) int {
+	client, err := deviceClient(opts)
+	if err != nil {
+		return exitCodeFor(classifyAPIError(err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	device, err := findDevice(ctx, client, ref)
+	if err != nil {
+		ui.PrintError(err.Error())
+		return exitCodeFor(classifyAPIError(err))
+	}
+
+	if err := client.SetDeviceName(ctx, device.ID, name); err != nil {
+		ui.PrintError(fmt.Sprintf("Could not rename device: %v", err))
+		return exitCodeFor(classifyAPIError(err))
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Renamed device %s from %q to %q", device.ID, device.Name, name))
+	return exitOK
+}
+
+// runDevicesDelete deletes the device identified by ref after confirmation
+func runDevicesDelete(opts *deviceOptions, ref string) int {
+	client, err := deviceClient(opts)
+	if err != nil {
+		return exitCodeFor(classifyAPIError(err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	device, err := findDevice(ctx, client, ref)
+	if err != nil {
+		ui.PrintError(err.Error())
+		return exitCodeFor(classifyAPIError(err))
+	}
+
+	label := device.ID
+	if device.Name != "" {
+		label = fmt.Sprintf("%q (%s)", device.Name, device.ID)
+	}
+	if !opts.yes && !ui.PromptYesNo(fmt.Sprintf("Delete device %s? This cannot be undone", label), false) {
+		ui.PrintWarning("Delete cancelled")
+		return exitCancelled
+	}
+
+	if err := client.DeleteDevice(ctx, device.ID); err != nil {
+		ui.PrintError(fmt.Sprintf("Could not delete device: %v", err))
+		return exitCodeFor(classifyAPIError(err))
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Deleted device %s", label))
+	return exitOK
+}
+
+// runDevicesRegister registers devices for a board and writes them, with their
+// keys, to a manifest that provisions them later without contacting the Hubble API
+func runDevicesRegister(opts *deviceOptions) int {
+	if opts.board == "" || opts.count < 1 {
+		ui.PrintError("Pass --board and a --count of at least 1")
+		return exitError
+	}
+	catalog, code := loadBoards(opts.boardsFile)
+	if code >= 0 {
+		return code
+	}
+	board, err := catalog.Board(opts.board)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Invalid board: %v", err))
+		return exitError
+	}
+	if _, err := os.Stat(opts.out); err == nil {
+		ui.PrintError(fmt.Sprintf("%s already exists; pass another --out", opts.out))
+		return exitError
+	}
+
+	client, err := deviceClient(opts)
+	if err != nil {
+		return exitCodeFor(classifyAPIError(err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute+time.Duration(opts.count)*5*time.Second)
+	defer cancel()
+
+	var entries []batch.Entry
+	for i := range opts.count {
+		name := ""
+		if opts.namePrefix != "" {
+			name = fmt.Sprintf("%s-%d", opts.namePrefix, i+1)
+		}
+		device, err := client.RegisterDevice(ctx, name)
+		var namingErr *hubbleapi.NamingError
+		if errors.As(err, &namingErr) {
+			ui.PrintWarning(fmt.Sprintf("%v; rename it later with 'hubble-install devices rename %s <name>'", err, device.ID))
+			name, err = "", nil
+		}
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("Could not register device %d of %d: %v", i+1, opts.count, err))
+			// Keep the devices registered so far, as their keys cannot be fetched again
+			if len(entries) > 0 && writeRegistered(opts.out, entries) {
+				ui.PrintInfo(fmt.Sprintf("The %d devices registered so far are in %s", len(entries), opts.out))
+			}
+			return exitCodeFor(classifyAPIError(err))
+		}
+		ui.Redact(device.Key)
+		entries = append(entries, batch.Entry{Board: board.ID, DeviceName: name, DeviceID: device.ID, DeviceKey: device.Key})
+	}
+
+	if !writeRegistered(opts.out, entries) {
+		return exitError
+	}
+	ui.PrintSuccess(fmt.Sprintf("Registered %d devices and wrote them to %s", len(entries), opts.out))
+	ui.PrintWarning("The file holds the devices' encryption keys: keep it as private as an API token")
+	ui.PrintInfo(fmt.Sprintf("Provision them offline with: hubble-install flash --bundle <bundle> --manifest %s", opts.out))
+	return exitOK
+}
+
+// writeRegistered writes the registered devices to a new manifest at path,
+// reporting whether it succeeded
+func writeRegistered(path string, entries []batch.Entry) bool {
+	if err := batch.WriteManifest(path, entries); err != nil {
+		ui.PrintError(err.Error())
+		return false
+	}
+	return true
+}
+
+// deviceClient resolves credentials the same way as an installation and returns an API client
+func deviceClient(opts *deviceOptions) (*hubbleapi.Client, error) {
+	cfg, _, err := config.PromptForConfig(config.Options{
+		OrgID:          opts.orgID,
+		Profile:        opts.profile,
+		NonInteractive: opts.yes,
+	})
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Configuration failed: %v", err))
+		return nil, &platform.CredentialInvalidError{Err: err}
+	}
+	client, err := hubbleapi.NewClient(cfg.OrgID, cfg.APIToken)
+	if err != nil {
+		ui.PrintError(err.Error())
+		return nil, err
+	}
+	return client, nil
+}
+
+// findDevice looks a device up by ID, or by name if the name is unique in the organization
+func findDevice(ctx context.Context, client *hubbleapi.Client, ref string) (*hubbleapi.Device, error) {
+	devices, err := client.ListDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list devices: %w", err)
+	}
+
+	var byName []hubbleapi.Device
+	for _, device := range devices {
+		if device.ID == ref {
+			return &device, nil
+		}
+		if device.Name == ref {
+			byName = append(byName, device)
+		}
+	}
+
+	switch len(byName) {
+	case 0:
+		return nil, fmt.Errorf("no device with ID or name %q", ref)
+	case 1:
+		return &byName[0], nil
+	default:
+		ids := make([]string, len(byName))
+		for i, device := range byName {
+			ids[i] = device.ID
+		}
+		return nil, fmt.Errorf("%d devices are named %q; use a device ID instead: %s", len(byName), ref, strings.Join(ids, ", "))
+	}
+}
+
+// parseDeviceArgs parses flags and exactly n positional arguments, in any order
+func parseDeviceArgs(fs *flag.FlagSet, args []string, n int) ([]string, int) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return nil, exitOK
+			}
+			return nil, exitError
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) != n {
+		fmt.Fprint(os.Stderr, devicesUsageText)
+		return nil, exitError
+	}
+	return positional, -1
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/batch"
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/hubbleapi"
+)
+
+// registrationAPI fakes the Hubble API's device registration, failing the
+// failAt-th registration (counting from 1) and every one after it
+func registrationAPI(t *testing.T, failAt int32) {
+	t.Helper()
+	var registered atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/org/"+testOrgID+"/devices" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		switch r.Method {
+		case http.MethodPost:
+			n := registered.Add(1)
+			if n >= failAt {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"message": "database unavailable"}`))
+				return
+			}
+			id := "dev-" + strconv.Itoa(int(n))
+			json.NewEncoder(w).Encode(map[string][]hubbleapi.Device{"devices": {{ID: id, Key: "key-" + id}}})
+		case http.MethodPatch:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(hubbleapi.BaseURLEnv, server.URL)
+}
+
+func TestDevicesRegisterPartialFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		failAt int32
+		want   []batch.Entry // Devices written to the manifest, or nil for no manifest
+	}{
+		{
+			name:   "fails after two devices",
+			failAt: 3,
+			want: []batch.Entry{
+				{Line: 2, Board: "nrf52840dk", DeviceName: "line-a-1", DeviceID: "dev-1", DeviceKey: "key-dev-1"},
+				{Line: 3, Board: "nrf52840dk", DeviceName: "line-a-2", DeviceID: "dev-2", DeviceKey: "key-dev-2"},
+			},
+		},
+		{name: "fails on the first device", failAt: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateConfig(t)
+			registrationAPI(t, tt.failAt)
+			out := captureMessages(t)
+			path := filepath.Join(t.TempDir(), "devices.csv")
+
+			code := runDevices([]string{"register", "--yes", "--board", "nrf52840dk", "--count", "3", "--name-prefix", "line-a", "--out", path})
+			if code == exitOK {
+				t.Errorf("devices register exits %d after a failed registration", code)
+			}
+
+			if tt.want == nil {
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("wrote a manifest without any registered device: %v", err)
+				}
+				return
+			}
+			entries, err := batch.Load(path, boards.Default())
+			if err != nil {
+				t.Fatalf("reading the manifest: %v", err)
+			}
+			if !slices.Equal(entries, tt.want) {
+				t.Errorf("manifest holds %+v, want %+v", entries, tt.want)
+			}
+			if want := fmt.Sprintf("The %d devices registered so far are in %s", len(tt.want), path); !slices.Contains(out.messages, want) {
+				t.Errorf("printed %q, want it to say %q", out.printed(), want)
+			}
+		})
+	}
+}
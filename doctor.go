@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/doctor"
+	"github.com/HubbleNetwork/hubble-install/internal/download"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// doctorReport is the structured result of the doctor command
+type doctorReport struct {
+	Status  doctor.Status   `json:"status"` // Worst status of any check
+	Version string          `json:"version"`
+	OS      string          `json:"os"`
+	Arch    string          `json:"arch"`
+	Checks  []doctor.Result `json:"checks"`
+}
+
+// runDoctor checks this machine for everything installing and flashing depend on
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	output := fs.String("output", "human", "output format: human or json")
+	boardsFile := fs.String("boards-file", "", "board catalog to check instead of the built-in one (file path or URL)")
+	toolVersion := fs.String("tool-version", platform.DefaultToolVersion, "pyhubbledemo release to look for")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := setOutput(*output); code >= 0 {
+		return code
+	}
+	catalog, code := loadBoards(*boardsFile)
+	if code >= 0 {
+		return code
+	}
+
+	report, err := diagnose(catalog, *toolVersion)
+	if err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+
+	for _, result := range report.Checks {
+		line := fmt.Sprintf("%s: %s", result.Name, result.Message)
+		switch result.Status {
+		case doctor.Pass:
+			ui.PrintSuccess(line)
+		case doctor.Warn:
+			ui.PrintWarning(line)
+		default:
+			ui.PrintError(line)
+		}
+		for _, detail := range result.Details {
+			fmt.Printf("    %s\n", detail)
+		}
+	}
+	ui.PrintResult(report)
+
+	if report.Status == doctor.Fail {
+		return exitError
+	}
+	return exitOK
+}
+
+// diagnose runs every doctor check against this machine
+func diagnose(catalog *boards.Catalog, toolVersion string) (*doctorReport, error) {
+	runner := platform.ExecRunner{}
+	installer, err := platform.NewInstaller(runtime.GOOS, runner)
+	if err != nil {
+		return nil, fmt.Errorf("platform detection failed: %w", err)
+	}
+	httpClient, err := download.NewHTTPClient(os.Getenv(download.CABundleEnv))
+	if err != nil {
+		return nil, err
+	}
+
+	env := &doctor.Env{
+		GOOS:        runtime.GOOS,
+		Runner:      runner,
+		Installer:   installer,
+		Catalog:     catalog,
+		ToolVersion: toolVersion,
+		HTTPClient:  httpClient,
+		Endpoints:   doctor.DefaultEndpoints(),
+		Timeout:     10 * time.Second,
+	}
+	results := doctor.Run(env, doctor.Checks)
+	return &doctorReport{
+		Status:  doctor.Worst(results),
+		Version: Version,
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		Checks:  results,
+	}, nil
+}
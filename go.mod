@@ -4,11 +4,11 @@ go 1.24.0
 
 require (
 	github.com/fatih/color v1.16.0
+	golang.org/x/sys v0.39.0
 	golang.org/x/term v0.15.0
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/sys v0.39.0 // indirect
 )
@@ -0,0 +1,236 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+)
+
+// Manifest column names (the header row is required, column order is free)
+const (
+	ColumnBoard       = "board"
+	ColumnDeviceName  = "device_name"
+	ColumnProbeSerial = "probe_serial"
+	ColumnDeviceID    = "device_id"
+	ColumnDeviceKey   = "device_key"
+)
+
+// Entry is a single board to provision, read from one manifest row
+type Entry struct {
+	Line        int    // Line number in the manifest, for error messages and the report
+	Board       string // Canonical board ID
+	DeviceName  string // Optional device name
+	ProbeSerial string // Optional J-Link serial number
+	DeviceID    string // ID of a device registered in advance, if any
+	DeviceKey   string // Key of that device; the entry is flashed without registering a new one
+}
+
+// Registered reports whether the entry's device was registered in advance
+func (e Entry) Registered() bool {
+	return e.DeviceKey != ""
+}
+
+// Result status values
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// Result records the outcome of provisioning one manifest entry
+type Result struct {
+	Entry       Entry
+	Status      string
+	DeviceID    string
+	DeviceName  string
+	HexFilePath string
+	ToolVersion string // pyhubbledemo release used, for tracing a bad batch to a tool release
+	Error       string
+	Duration    time.Duration
+}
+
+// MarshalJSON flattens the result into one object per manifest row
+func (r Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Line        int    `json:"line"`
+		Board       string `json:"board"`
+		ProbeSerial string `json:"probe_serial,omitempty"`
+		Status      string `json:"status"`
+		DeviceID    string `json:"device_id,omitempty"`
+		DeviceName  string `json:"device_name,omitempty"`
+		HexFilePath string `json:"hex_file,omitempty"`
+		ToolVersion string `json:"tool_version,omitempty"`
+		Error       string `json:"error,omitempty"`
+		DurationMS  int64  `json:"duration_ms"`
+	}{
+		Line:        r.Entry.Line,
+		Board:       r.Entry.Board,
+		ProbeSerial: r.Entry.ProbeSerial,
+		Status:      r.Status,
+		DeviceID:    r.DeviceID,
+		DeviceName:  r.DeviceName,
+		HexFilePath: r.HexFilePath,
+		ToolVersion: r.ToolVersion,
+		Error:       r.Error,
+		DurationMS:  r.Duration.Milliseconds(),
+	})
+}
+
+// Load reads a CSV manifest from a file, resolving boards in catalog
+func Load(path string, catalog *boards.Catalog) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer f.Close()
+
+	return Parse(f, catalog)
+}
+
+// Parse reads a CSV manifest. The first row must be a header naming the columns;
+// only the board column is required. Blank lines and lines starting with # are skipped.
+func Parse(r io.Reader, catalog *boards.Catalog) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("manifest is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns[ColumnBoard]; !ok {
+		return nil, fmt.Errorf("manifest header must include a %q column", ColumnBoard)
+	}
+
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var entries []Entry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		boardID := field(record, ColumnBoard)
+		if boardID == "" {
+			return nil, fmt.Errorf("line %d: board is required", line)
+		}
+		board, err := catalog.Board(boardID)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		deviceID, deviceKey := field(record, ColumnDeviceID), field(record, ColumnDeviceKey)
+		if (deviceID == "") != (deviceKey == "") {
+			return nil, fmt.Errorf("line %d: %s and %s must be given together", line, ColumnDeviceID, ColumnDeviceKey)
+		}
+
+		entries = append(entries, Entry{
+			Line:        line,
+			Board:       board.ID,
+			DeviceName:  field(record, ColumnDeviceName),
+			ProbeSerial: field(record, ColumnProbeSerial),
+			DeviceID:    deviceID,
+			DeviceKey:   deviceKey,
+		})
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("manifest contains no boards")
+	}
+
+	return entries, nil
+}
+
+// WriteManifest writes entries as a new manifest at path, with the device IDs and
+// keys of devices registered in advance. The keys are secret, so the file is only
+// readable by its owner and an existing file is never overwritten.
+func WriteManifest(path string, entries []Entry) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+
+	writer := csv.NewWriter(f)
+	writer.Write([]string{ColumnBoard, ColumnDeviceName, ColumnProbeSerial, ColumnDeviceID, ColumnDeviceKey})
+	for _, e := range entries {
+		writer.Write([]string{e.Board, e.DeviceName, e.ProbeSerial, e.DeviceID, e.DeviceKey})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return f.Close()
+}
+
+// WriteReport writes one CSV row per result to a file
+func WriteReport(path string, results []Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+
+	if err := writeReport(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeReport writes the report CSV to w
+func writeReport(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"line", ColumnBoard, ColumnDeviceName, ColumnProbeSerial, "status", ColumnDeviceID, "hex_file", "tool_version", "duration", "error"})
+	for _, r := range results {
+		writer.Write([]string{
+			fmt.Sprintf("%d", r.Entry.Line),
+			r.Entry.Board,
+			r.DeviceName,
+			r.Entry.ProbeSerial,
+			r.Status,
+			r.DeviceID,
+			r.HexFilePath,
+			r.ToolVersion,
+			r.Duration.Round(time.Millisecond).String(),
+			r.Error,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// DefaultReportPath returns the report path used when none is given: the manifest
+// path with its extension replaced by "-report.csv"
+func DefaultReportPath(manifestPath string) string {
+	base := strings.TrimSuffix(manifestPath, ".csv")
+	return base + "-report.csv"
+}
@@ -0,0 +1,64 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+)
+
+func TestParseRegisteredDevices(t *testing.T) {
+	tests := []struct {
+		name    string
+		row     string
+		want    Entry
+		wantErr bool
+	}{
+		{name: "registered", row: "nrf52840dk,bench-01,dev-1,a2V5", want: Entry{Line: 2, Board: "nrf52840dk", DeviceName: "bench-01", DeviceID: "dev-1", DeviceKey: "a2V5"}},
+		{name: "not registered", row: "nrf52840dk,bench-01,,", want: Entry{Line: 2, Board: "nrf52840dk", DeviceName: "bench-01"}},
+		{name: "ID without key", row: "nrf52840dk,bench-01,dev-1,", wantErr: true},
+		{name: "key without ID", row: "nrf52840dk,bench-01,,a2V5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Parse(strings.NewReader("board,device_name,device_id,device_key\n"+tt.row+"\n"), boards.Default())
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Parse() = %+v, want an error", entries)
+				}
+				return
+			}
+			if err != nil || len(entries) != 1 || entries[0] != tt.want {
+				t.Errorf("Parse() = %+v, %v, want %+v", entries, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.csv")
+	entries := []Entry{
+		{Line: 2, Board: "nrf52840dk", DeviceName: "line-a-1", DeviceID: "dev-1", DeviceKey: "a2V5LTE="},
+		{Line: 3, Board: "nrf52840dk", DeviceID: "dev-2", DeviceKey: "a2V5LTI="},
+	}
+	if err := WriteManifest(path, entries); err != nil {
+		t.Fatalf("WriteManifest() = %v", err)
+	}
+
+	got, err := Load(path, boards.Default())
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if !slices.Equal(got, entries) {
+		t.Errorf("Load() = %+v, want %+v", got, entries)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 && os.PathSeparator == '/' {
+		t.Errorf("manifest of device keys has mode %v, want it private", info.Mode().Perm())
+	}
+	if err := WriteManifest(path, entries); err == nil {
+		t.Errorf("WriteManifest() overwrote an existing manifest")
+	}
+}
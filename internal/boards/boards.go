@@ -1,96 +1,164 @@
 package boards
 
-import "fmt"
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
 
 // Flash methods
 const (
-	FlashMethodJLink    = "jlink"    // Direct flash via SEGGER J-Link
-	FlashMethodUniflash = "uniflash" // Generate hex file for TI Uniflash
+	FlashMethodJLink     = "jlink"     // Direct flash via SEGGER J-Link
+	FlashMethodUniflash  = "uniflash"  // Direct flash via TI UniFlash (DSLite), or a hex file for UniFlash
+	FlashMethodCommander = "commander" // Direct flash via Silicon Labs Simplicity Commander
 )
 
 // Board represents a developer board that can be flashed
 type Board struct {
-	ID          string
-	Name        string
-	Description string
-	Vendor      string
-	FlashMethod string // "jlink" or "uniflash"
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Description    string   `json:"description"`
+	Vendor         string   `json:"vendor"`
+	FlashMethod    string   `json:"flash_method"`               // "jlink", "uniflash" or "commander"
+	Dependencies   []string `json:"dependencies"`               // Tools the installer must provide, e.g. "uv"
+	Aliases        []string `json:"aliases,omitempty"`          // Other IDs accepted by --board
+	MinToolVersion string   `json:"min_tool_version,omitempty"` // Oldest pyhubbledemo release that supports the board
+	Target         string   `json:"target,omitempty"`           // Device part number for the flashing tool, e.g. "CC2340R5"
+	USB            []USBID  `json:"usb,omitempty"`              // IDs of the on-board debug probe, for detecting the connected board
+}
+
+// USBID identifies a USB device by its vendor and product IDs, as four lowercase hex digits
+type USBID struct {
+	VendorID     string `json:"vendor_id"`
+	ProductID    string `json:"product_id"`
+	SerialPrefix string `json:"serial_prefix,omitempty"` // Start of the serial number, for a probe model other boards use too
+}
+
+// Matches reports whether a USB device with the given IDs and serial number is this probe
+func (id USBID) Matches(vendorID, productID, serial string) bool {
+	return id.VendorID == vendorID && id.ProductID == productID && strings.HasPrefix(serial, id.SerialPrefix)
+}
+
+// FlashCapability describes the ways the installer can get firmware onto a board
+type FlashCapability struct {
+	Direct bool   // The installer can program the board itself
+	Hex    bool   // A hex file can be generated for the user to flash by hand
+	Tool   string // Dependency that programs the board directly, e.g. "segger-jlink"
 }
 
-// RequiresJLink returns true if this board requires SEGGER J-Link
-func (b *Board) RequiresJLink() bool {
-	return b.FlashMethod == FlashMethodJLink
+// Optional returns true if the board can be flashed directly, but falls back to a
+// hex file when Tool is not installed
+func (c FlashCapability) Optional() bool {
+	return c.Direct && c.Hex
+}
+
+// Capability returns how this board can be flashed
+func (b *Board) Capability() FlashCapability {
+	switch b.FlashMethod {
+	case FlashMethodJLink:
+		return FlashCapability{Direct: true, Tool: "segger-jlink"}
+	case FlashMethodCommander:
+		return FlashCapability{Direct: true, Tool: "simplicity-commander"}
+	case FlashMethodUniflash:
+		// UniFlash needs the device part number to build its target configuration
+		return FlashCapability{Direct: b.Target != "", Hex: true, Tool: "uniflash"}
+	}
+	return FlashCapability{}
 }
 
 // GetDependencies returns the list of dependencies required for this board
 func (b *Board) GetDependencies() []string {
-	if b.RequiresJLink() {
-		// Nordic DKs use a J-Link probe (often on-board). We need:
-		// - uv: to run our Python flashing tool (pyhubbledemo)
-		// - segger-jlink: provides J-Link drivers/DLLs that pylink-square uses
-		return []string{"uv", "segger-jlink"}
-	}
-	return []string{"uv"}
+	return append([]string(nil), b.Dependencies...)
 }
 
-// Available boards for flashing
-var AvailableBoards = []Board{
-	{
-		ID:          "nrf21540dk",
-		Name:        "nRF21540 DK",
-		Description: "Nordic Semiconductor nRF21540 Development Kit",
-		Vendor:      "Nordic",
-		FlashMethod: FlashMethodJLink,
-	},
-	{
-		ID:          "nrf52840dk",
-		Name:        "nRF52840 DK",
-		Description: "Nordic Semiconductor nRF52840 Development Kit",
-		Vendor:      "Nordic",
-		FlashMethod: FlashMethodJLink,
-	},
-	{
-		ID:          "lp_em_cc2340r5",
-		Name:        "TI CC2340R5",
-		Description: "Texas Instruments CC2340R5 LaunchPad",
-		Vendor:      "Texas Instruments",
-		FlashMethod: FlashMethodUniflash,
-	},
-	{
-		ID:          "lp_em_cc2340r53",
-		Name:        "TI CC2340R53",
-		Description: "Texas Instruments CC2340R53 LaunchPad",
-		Vendor:      "Texas Instruments",
-		FlashMethod: FlashMethodUniflash,
-	},
-	// {
-	// 	ID:          "xg22_ek4108a",
-	// 	Name:        "xG22 EK4108A",
-	// 	Description: "Silicon Labs xG22 Explorer Kit",
-	// 	Vendor:      "Silicon Labs",
-	// },
-	// {
-	// 	ID:          "xg24_ek2703a",
-	// 	Name:        "xG24 EK2703A",
-	// 	Description: "Silicon Labs xG24 Explorer Kit",
-	// 	Vendor:      "Silicon Labs",
-	// },
+// builtin is the embedded default catalog
+var builtin = mustParseCatalog(defaultCatalog)
+
+// Default returns the board catalog built into the installer
+func Default() *Catalog {
+	return &Catalog{Version: builtin.Version, Boards: slices.Clone(builtin.Boards)}
 }
 
-// GetBoard returns a board by its ID
-func GetBoard(id string) (*Board, error) {
-	for _, board := range AvailableBoards {
+// Board returns a board by its ID or one of its aliases
+func (c *Catalog) Board(id string) (*Board, error) {
+	for _, board := range c.Boards {
 		if board.ID == id {
 			return &board, nil
 		}
+		for _, alias := range board.Aliases {
+			if alias == id {
+				return &board, nil
+			}
+		}
 	}
 	return nil, fmt.Errorf("board not found: %s", id)
 }
 
-// FormatBoardList returns a formatted string of all available boards
-func FormatBoardList() string {
+// SupportsToolVersion reports whether the given pyhubbledemo release is recent
+// enough for this board
+func (b *Board) SupportsToolVersion(version string) bool {
+	return b.MinToolVersion == "" || compareVersions(version, b.MinToolVersion) >= 0
+}
+
+// compareVersions compares the numeric release parts of two versions, e.g. "0.10.1"
+// and "0.9", returning -1, 0 or 1. Pre-release suffixes such as "rc1" are ignored.
+func compareVersions(a, b string) int {
+	partsA, partsB := versionParts(a), versionParts(b)
+	for i := 0; i < max(len(partsA), len(partsB)); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x = partsA[i]
+		}
+		if i < len(partsB) {
+			y = partsB[i]
+		}
+		if x != y {
+			return cmp.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+// versionParts returns the leading dot-separated numbers of a version, stopping
+// at the first part that is not purely numeric ("0.5.0rc1" gives 0, 5, 0)
+func versionParts(version string) []int {
+	var parts []int
+	for _, field := range strings.Split(version, ".") {
+		digits := field[:len(field)-len(strings.TrimLeft(field, "0123456789"))]
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+		if digits != field {
+			break
+		}
+	}
+	return parts
+}
+
+// MatchUSB returns the boards whose debug probe is the USB device with the given
+// IDs and serial number. Boards sharing a probe model all match, so the result
+// may hold several boards.
+func (c *Catalog) MatchUSB(vendorID, productID, serial string) []Board {
+	var matches []Board
+	for _, board := range c.Boards {
+		for _, id := range board.USB {
+			if id.Matches(vendorID, productID, serial) {
+				matches = append(matches, board)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// FormatBoardList returns a formatted string of all boards in the catalog
+func (c *Catalog) FormatBoardList() string {
 	result := ""
-	for i, board := range AvailableBoards {
+	for i, board := range c.Boards {
 		result += fmt.Sprintf("%d. %s - %s\n", i+1, board.Name, board.Description)
 	}
 	return result
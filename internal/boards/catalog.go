@@ -0,0 +1,186 @@
+package boards
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/download"
+)
+
+// CatalogVersion is the catalog schema version this installer understands
+const CatalogVersion = 1
+
+// KnownDependencies are the dependency names the platform installers can provide
+var KnownDependencies = []string{"uv", "segger-jlink", "simplicity-commander", "uniflash"}
+
+// defaultCatalog is the board catalog shipped with the installer
+//
+//go:embed catalog.json
+var defaultCatalog []byte
+
+// Catalog is a versioned list of supported boards
+type Catalog struct {
+	Version int     `json:"version"`
+	Boards  []Board `json:"boards"`
+}
+
+var (
+	boardIDPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+	versionPattern = regexp.MustCompile(`^\d+(\.\d+){0,2}$`)
+	targetPattern  = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	usbIDPattern   = regexp.MustCompile(`^[0-9a-f]{4}$`)
+	serialPattern  = regexp.MustCompile(`^[A-Za-z0-9]*$`)
+)
+
+// ParseCatalog decodes a catalog and validates it against the schema. Unknown
+// fields are rejected so that a typo cannot silently drop a setting.
+func ParseCatalog(data []byte) (*Catalog, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var catalog Catalog
+	if err := dec.Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("invalid board catalog: %w", err)
+	}
+	if err := catalog.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid board catalog: %w", err)
+	}
+	return &catalog, nil
+}
+
+// Validate checks the catalog against the schema
+func (c *Catalog) Validate() error {
+	if c.Version != CatalogVersion {
+		return fmt.Errorf("unsupported version %d (this installer reads version %d)", c.Version, CatalogVersion)
+	}
+	if len(c.Boards) == 0 {
+		return fmt.Errorf("no boards listed")
+	}
+
+	// IDs and aliases share one namespace, as --board accepts either
+	seen := make(map[string]string)
+	claim := func(name, id string) error {
+		if !boardIDPattern.MatchString(name) {
+			return fmt.Errorf("board %q: %q is not a valid ID (use lowercase letters, digits and underscores)", id, name)
+		}
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("board %q: %q is already used by board %q", id, name, other)
+		}
+		seen[name] = id
+		return nil
+	}
+
+	for i, board := range c.Boards {
+		if board.ID == "" {
+			return fmt.Errorf("board %d: missing id", i+1)
+		}
+		if err := claim(board.ID, board.ID); err != nil {
+			return err
+		}
+		for _, alias := range board.Aliases {
+			if err := claim(alias, board.ID); err != nil {
+				return err
+			}
+		}
+		if board.Name == "" {
+			return fmt.Errorf("board %q: missing name", board.ID)
+		}
+		switch board.FlashMethod {
+		case FlashMethodJLink, FlashMethodUniflash, FlashMethodCommander:
+		default:
+			return fmt.Errorf("board %q: unknown flash method %q", board.ID, board.FlashMethod)
+		}
+		if len(board.Dependencies) == 0 {
+			return fmt.Errorf("board %q: no dependencies listed", board.ID)
+		}
+		for _, dep := range board.Dependencies {
+			if !slices.Contains(KnownDependencies, dep) {
+				return fmt.Errorf("board %q: unknown dependency %q (known: %s)", board.ID, dep, strings.Join(KnownDependencies, ", "))
+			}
+		}
+		if board.MinToolVersion != "" && !versionPattern.MatchString(board.MinToolVersion) {
+			return fmt.Errorf("board %q: min_tool_version %q is not a version number", board.ID, board.MinToolVersion)
+		}
+		if board.Target != "" && !targetPattern.MatchString(board.Target) {
+			return fmt.Errorf("board %q: target %q is not a device part number", board.ID, board.Target)
+		}
+		if board.FlashMethod == FlashMethodCommander && board.Target == "" {
+			return fmt.Errorf("board %q: Simplicity Commander needs the target device part number", board.ID)
+		}
+		for _, id := range board.USB {
+			if !usbIDPattern.MatchString(id.VendorID) || !usbIDPattern.MatchString(id.ProductID) {
+				return fmt.Errorf("board %q: USB ID %s:%s must be two sets of four lowercase hex digits", board.ID, id.VendorID, id.ProductID)
+			}
+			if !serialPattern.MatchString(id.SerialPrefix) {
+				return fmt.Errorf("board %q: serial_prefix %q must be letters and digits", board.ID, id.SerialPrefix)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadCatalog reads a catalog from a local file or an https URL. URLs are
+// fetched through the proxies and certificate authorities downloads use.
+func LoadCatalog(source string) (*Catalog, error) {
+	data, err := readCatalog(source)
+	if err != nil {
+		return nil, fmt.Errorf("could not read board catalog %s: %w", source, err)
+	}
+	catalog, err := ParseCatalog(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return catalog, nil
+}
+
+// readCatalog fetches the raw catalog from a file path or URL
+func readCatalog(source string) ([]byte, error) {
+	if strings.HasPrefix(source, "http://") {
+		return nil, fmt.Errorf("catalog URLs must use https")
+	}
+	if !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+
+	client, err := download.NewHTTPClient(os.Getenv(download.CABundleEnv))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	// Catalogs are small; cap how much of a wrong URL is read
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// mustParseCatalog parses the embedded catalog; an invalid one is a packaging bug
+func mustParseCatalog(data []byte) *Catalog {
+	catalog, err := ParseCatalog(data)
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
@@ -0,0 +1,157 @@
+package boards
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/download"
+)
+
+func TestDefaultCatalogIsValid(t *testing.T) {
+	if _, err := ParseCatalog(defaultCatalog); err != nil {
+		t.Fatalf("embedded catalog: %v", err)
+	}
+}
+
+func TestMatchUSBSharedProbe(t *testing.T) {
+	catalog := Catalog{Version: CatalogVersion, Boards: []Board{
+		{ID: "dk_a", Name: "A", FlashMethod: FlashMethodJLink, Dependencies: []string{"uv"}, USB: []USBID{{VendorID: "1366", ProductID: "1015", SerialPrefix: "000683"}}},
+		{ID: "dk_b", Name: "B", FlashMethod: FlashMethodJLink, Dependencies: []string{"uv"}, USB: []USBID{{VendorID: "1366", ProductID: "1015"}}},
+		{ID: "lp_a", Name: "C", FlashMethod: FlashMethodUniflash, Dependencies: []string{"uv"}, USB: []USBID{{VendorID: "0451", ProductID: "bef3"}}},
+		{ID: "lp_b", Name: "D", FlashMethod: FlashMethodUniflash, Dependencies: []string{"uv"}, USB: []USBID{{VendorID: "0451", ProductID: "bef3"}}},
+	}}
+	// Boards sharing a probe model are valid; detection offers the menu for them
+	if err := catalog.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := []struct {
+		name                        string
+		vendorID, productID, serial string
+		want                        []string
+	}{
+		{"shared probe", "0451", "bef3", "L1100ABC", []string{"lp_a", "lp_b"}},
+		{"serial prefix and shared probe", "1366", "1015", "000683123456", []string{"dk_a", "dk_b"}},
+		{"other serial", "1366", "1015", "001050123456", []string{"dk_b"}},
+		{"unknown probe", "1366", "0105", "000440123456", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, board := range catalog.MatchUSB(tt.vendorID, tt.productID, tt.serial) {
+				got = append(got, board.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("MatchUSB() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRequiresCommanderTarget(t *testing.T) {
+	catalog := Catalog{Version: CatalogVersion, Boards: []Board{
+		{ID: "xg24", Name: "xG24", FlashMethod: FlashMethodCommander, Dependencies: []string{"uv", "simplicity-commander"}},
+	}}
+	if err := catalog.Validate(); err == nil || !strings.Contains(err.Error(), "target device") {
+		t.Errorf("Validate() = %v, want a missing target error", err)
+	}
+
+	catalog.Boards[0].Target = "EFR32MG24B210F1536IM48"
+	if err := catalog.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+// customCatalog returns a valid catalog holding a single board
+func customCatalog(t *testing.T) []byte {
+	t.Helper()
+	data, err := json.Marshal(Catalog{Version: CatalogVersion, Boards: []Board{
+		{ID: "custom", Name: "Custom", FlashMethod: FlashMethodJLink, Dependencies: []string{"uv"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// trustServer makes catalog downloads trust the certificate of server through
+// the CA bundle setting downloads use
+func trustServer(t *testing.T, server *httptest.Server) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, cert, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(download.CABundleEnv, path)
+}
+
+func TestLoadCatalog(t *testing.T) {
+	data := customCatalog(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/catalog.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	trustServer(t, server)
+
+	file := filepath.Join(t.TempDir(), "catalog.json")
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{name: "file", source: file},
+		{name: "https URL", source: server.URL + "/catalog.json"},
+		{name: "http URL", source: "http" + strings.TrimPrefix(server.URL, "https") + "/catalog.json", wantErr: "must use https"},
+		{name: "missing page", source: server.URL + "/missing.json", wantErr: "404"},
+		{name: "missing file", source: filepath.Join(t.TempDir(), "missing.json"), wantErr: "could not read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog, err := LoadCatalog(tt.source)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadCatalog() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadCatalog() = %v", err)
+			}
+			if _, err := catalog.Board("custom"); err != nil {
+				t.Errorf("loaded catalog: %v", err)
+			}
+			if _, err := Default().Board("custom"); err == nil {
+				t.Errorf("loading a catalog changed the built-in one")
+			}
+		})
+	}
+}
+
+func TestLoadCatalogRejectsUntrustedServer(t *testing.T) {
+	data := customCatalog(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(download.CABundleEnv, "")
+
+	if _, err := LoadCatalog(server.URL); err == nil {
+		t.Errorf("LoadCatalog() trusted a server no certificate authority vouches for")
+	}
+}
@@ -0,0 +1,374 @@
+// Package bundle reads and writes offline bundles: gzipped tarballs holding the
+// installers, the Python runtime and the uv cache needed to install the
+// dependencies and flash boards on a machine without internet access.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Format is the version of the bundle layout this installer reads and writes
+const Format = 1
+
+// Directories and files at the root of a bundle
+const (
+	ManifestFile = "manifest.json" // The Manifest
+	DownloadsDir = "downloads"     // Installers, by the file name of their download URL
+	CacheDir     = "uv-cache"      // uv cache holding the pyhubbledemo wheels (UV_CACHE_DIR)
+	PythonDir    = "python"        // uv-managed Python to run pyhubbledemo with (UV_PYTHON_INSTALL_DIR)
+)
+
+// Manifest describes the contents of a bundle
+type Manifest struct {
+	Format      int               `json:"format"`
+	Platform    string            `json:"platform"`     // GOOS/GOARCH the bundle was created on and for
+	ToolVersion string            `json:"tool_version"` // pyhubbledemo release in the uv cache
+	Created     time.Time         `json:"created"`
+	Downloads   map[string]string `json:"downloads"` // Bundle path of each download, by its URL
+	Files       []File            `json:"files"`
+}
+
+// File is a file or symbolic link in a bundle
+type File struct {
+	Path   string `json:"path"`             // Slash-separated path relative to the bundle root
+	SHA256 string `json:"sha256,omitempty"` // Checksum of a regular file
+	Size   int64  `json:"size,omitempty"`
+	Mode   uint32 `json:"mode,omitempty"` // Permission bits of a regular file
+	Link   string `json:"link,omitempty"` // Target of a symbolic link, relative to the link
+}
+
+// Bundle is an unpacked, verified offline bundle
+type Bundle struct {
+	Dir      string
+	Manifest Manifest
+
+	temporary bool // Dir was unpacked by Open and is removed by Close
+}
+
+// Download returns the path of the bundled file that was downloaded from url
+func (b *Bundle) Download(url string) (string, error) {
+	name, ok := b.Manifest.Downloads[url]
+	if !ok {
+		return "", fmt.Errorf("%s is not in the offline bundle", url)
+	}
+	return filepath.Join(b.Dir, filepath.FromSlash(name)), nil
+}
+
+// Close removes the files unpacked by Open. A bundle opened from a directory is left as-is.
+func (b *Bundle) Close() error {
+	if !b.temporary {
+		return nil
+	}
+	return os.RemoveAll(b.Dir)
+}
+
+// Write records every file under dir in manifest, writes the manifest into dir,
+// and packs dir into a gzipped tarball at dest
+func Write(dir string, manifest Manifest, dest string) error {
+	files, err := scan(dir)
+	if err != nil {
+		return err
+	}
+	manifest.Format = Format
+	manifest.Files = files
+	for url, name := range manifest.Downloads {
+		if !slices.ContainsFunc(files, func(f File) bool { return f.Path == name }) {
+			return fmt.Errorf("download %s is missing from the bundle (expected %s)", url, name)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write bundle manifest: %w", err)
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	if err := pack(dir, files, out); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	return out.Close()
+}
+
+// scan lists and checksums the files under dir, except the manifest
+func scan(dir string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if entry.IsDir() || name == ManifestFile {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			link, err := relativeLink(dir, name, target)
+			if err != nil {
+				return err
+			}
+			files = append(files, File{Path: name, Link: link})
+		case info.Mode().IsRegular():
+			sum, err := checksum(p)
+			if err != nil {
+				return err
+			}
+			files = append(files, File{Path: name, SHA256: sum, Size: info.Size(), Mode: uint32(info.Mode().Perm())})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle contents: %w", err)
+	}
+	return files, nil
+}
+
+// relativeLink rewrites the target of the link at name so that it is relative and
+// stays inside the bundle. Absolute targets inside dir are made relative, so that
+// the bundle can be unpacked anywhere.
+func relativeLink(dir, name, target string) (string, error) {
+	if filepath.IsAbs(target) {
+		rel, err := filepath.Rel(filepath.Join(dir, filepath.FromSlash(path.Dir(name))), target)
+		if err != nil {
+			return "", err
+		}
+		target = rel
+	}
+	target = filepath.ToSlash(target)
+	if !inside(path.Join(path.Dir(name), target)) {
+		return "", fmt.Errorf("%s links to %s, outside the bundle", name, target)
+	}
+	return target, nil
+}
+
+// pack writes the manifest and then files from dir as a gzipped tarball
+func pack(dir string, files []File, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	entries := append([]File{{Path: ManifestFile, Mode: 0644}}, files...)
+	for _, file := range entries {
+		header := &tar.Header{Name: file.Path, ModTime: time.Now()}
+		if file.Link != "" {
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = file.Link
+			header.Mode = 0777
+			if err := tw.WriteHeader(header); err != nil {
+				return fmt.Errorf("failed to write bundle: %w", err)
+			}
+			continue
+		}
+
+		src, err := os.Open(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+		info, err := src.Stat()
+		if err == nil {
+			header.Typeflag = tar.TypeReg
+			header.Mode = int64(file.Mode)
+			header.Size = info.Size()
+			err = tw.WriteHeader(header)
+		}
+		if err == nil {
+			_, err = io.Copy(tw, src)
+		}
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return gz.Close()
+}
+
+// Open unpacks the bundle at path into a temporary directory and verifies it
+// against its manifest. path may also be a directory holding an unpacked bundle,
+// which is verified in place.
+func Open(path string) (*Bundle, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	b := &Bundle{Dir: path}
+	if !info.IsDir() {
+		dir, err := os.MkdirTemp("", "hubble-bundle-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bundle directory: %w", err)
+		}
+		b = &Bundle{Dir: dir, temporary: true}
+		if err := unpack(path, dir); err != nil {
+			b.Close()
+			return nil, err
+		}
+	}
+
+	if err := b.verify(); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// unpack extracts the gzipped tarball archive into dir, refusing entries that
+// would land outside dir
+func unpack(archive, dir string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s is not a bundle: %w", archive, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+
+		name := path.Clean(header.Name)
+		if !inside(name) {
+			return fmt.Errorf("bundle entry %s is outside the bundle", header.Name)
+		}
+		dest := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("failed to unpack bundle: %w", err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(dest, 0755)
+		case tar.TypeSymlink:
+			if filepath.IsAbs(header.Linkname) || !inside(path.Join(path.Dir(name), header.Linkname)) {
+				return fmt.Errorf("bundle entry %s links outside the bundle", header.Name)
+			}
+			err = os.Symlink(header.Linkname, dest)
+		case tar.TypeReg:
+			err = writeFile(dest, tr, fs.FileMode(header.Mode).Perm())
+		default:
+			return fmt.Errorf("bundle entry %s has unsupported type %q", header.Name, header.Typeflag)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to unpack bundle: %w", err)
+		}
+	}
+}
+
+// writeFile copies r into a new file at path
+func writeFile(path string, r io.Reader, mode fs.FileMode) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// verify reads the manifest and checks every file it lists
+func (b *Bundle) verify() error {
+	data, err := os.ReadFile(filepath.Join(b.Dir, ManifestFile))
+	if err != nil {
+		return fmt.Errorf("bundle has no manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &b.Manifest); err != nil {
+		return fmt.Errorf("bundle manifest is invalid: %w", err)
+	}
+	if b.Manifest.Format != Format {
+		return fmt.Errorf("bundle format %d is not supported by this installer (expected %d)", b.Manifest.Format, Format)
+	}
+
+	for _, file := range b.Manifest.Files {
+		if !inside(path.Clean(file.Path)) {
+			return fmt.Errorf("bundle file %s is outside the bundle", file.Path)
+		}
+		p := filepath.Join(b.Dir, filepath.FromSlash(file.Path))
+		if file.Link != "" {
+			if target, err := os.Readlink(p); err != nil || filepath.ToSlash(target) != file.Link {
+				return fmt.Errorf("bundle link %s does not match the manifest", file.Path)
+			}
+			continue
+		}
+		sum, err := checksum(p)
+		if err != nil {
+			return fmt.Errorf("bundle file %s is missing: %w", file.Path, err)
+		}
+		if sum != file.SHA256 {
+			return fmt.Errorf("bundle file %s does not match its checksum; the bundle is corrupt or was modified", file.Path)
+		}
+	}
+
+	for url, name := range b.Manifest.Downloads {
+		if !slices.ContainsFunc(b.Manifest.Files, func(f File) bool { return f.Path == name }) {
+			return fmt.Errorf("bundle manifest lists %s for %s, but not its checksum", name, url)
+		}
+	}
+	return nil
+}
+
+// checksum returns the hex SHA-256 of the file at path
+func checksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// inside reports whether the cleaned, slash-separated path stays within the bundle root
+func inside(name string) bool {
+	return name != ".." && !strings.HasPrefix(name, "../") && !path.IsAbs(name)
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/secrets"
 	"github.com/HubbleNetwork/hubble-install/internal/ui"
 )
 
@@ -15,6 +16,7 @@ type Config struct {
 	OrgID    string
 	APIToken string
 	Board    string
+	Profile  string // Saved profile the credentials came from, if any
 }
 
 // validateCredentials checks if the credentials have the expected format
@@ -64,15 +66,66 @@ func min(a, b int) int {
 	return b
 }
 
+// Options controls how credentials are resolved by PromptForConfig
+type Options struct {
+	OrgID          string          // Org ID supplied on the command line (takes precedence over HUBBLE_CREDENTIALS and HUBBLE_ORG_ID)
+	Profile        string          // Saved profile to use, taking precedence over the environment; empty falls back to the current profile
+	NonInteractive bool            // Return an error instead of prompting for missing values
+	NoSave         bool            // Never offer to save prompted credentials as a profile
+	Catalog        *boards.Catalog // Catalog resolving the board_id in HUBBLE_CREDENTIALS; nil uses the built-in one
+	StorePath      string          // Credential store holding the saved profiles; empty uses DefaultStorePath
+}
+
+// loadStore reads the credential store the options choose
+func (opts Options) loadStore() (*Store, error) {
+	if opts.StorePath != "" {
+		return LoadStoreFrom(opts.StorePath)
+	}
+	return LoadStore()
+}
+
 // PromptForConfig prompts the user for all required configuration
-// Returns the config and a boolean indicating if credentials were pre-configured
-func PromptForConfig() (*Config, bool, error) {
+// Returns the config and a boolean indicating if credentials were pre-configured.
+// Whatever its source, the API token is masked in the run log from then on.
+func PromptForConfig(opts Options) (*Config, bool, error) {
+	config, preConfigured, err := promptForConfig(opts)
+	if config != nil {
+		ui.Redact(config.APIToken)
+	}
+	return config, preConfigured, err
+}
+
+// promptForConfig resolves the configuration for PromptForConfig
+func promptForConfig(opts Options) (*Config, bool, error) {
 	config := &Config{}
 	preConfigured := false
 
-	// Check for base64 encoded credentials first (passed from install.sh)
+	// Flags choose the credentials before anything in the environment does. A
+	// profile carries its own Org ID, so overriding it would pair the profile's
+	// token with another organization.
+	if opts.Profile != "" && opts.OrgID != "" {
+		return nil, false, fmt.Errorf("--org-id and --profile both choose the organization: pass only one")
+	}
+	if opts.Profile != "" {
+		store, err := opts.loadStore()
+		if err != nil {
+			return nil, false, err
+		}
+		if err := config.applyProfile(store, opts.Profile); err != nil {
+			return nil, false, err
+		}
+		return config, true, nil
+	}
+
+	// Check for base64 encoded credentials (passed from install.sh), unless
+	// --org-id chose another organization
 	// Format: org_id:api_key or org_id:api_key:board_id
-	if encodedCreds := os.Getenv("HUBBLE_CREDENTIALS"); encodedCreds != "" {
+	encodedCreds := os.Getenv("HUBBLE_CREDENTIALS")
+	if encodedCreds != "" && opts.OrgID != "" {
+		ui.PrintInfo("Ignoring HUBBLE_CREDENTIALS, as --org-id was given")
+		encodedCreds = ""
+	}
+	if encodedCreds != "" {
 		decoded, err := base64.StdEncoding.DecodeString(encodedCreds)
 		if err == nil {
 			parts := strings.SplitN(string(decoded), ":", 3)
@@ -89,7 +142,11 @@ func PromptForConfig() (*Config, bool, error) {
 						boardID := strings.TrimSpace(parts[2])
 						if boardID != "" {
 							// Validate board ID exists and resolve to canonical ID
-							board, err := boards.GetBoard(boardID)
+							catalog := opts.Catalog
+							if catalog == nil {
+								catalog = boards.Default()
+							}
+							board, err := catalog.Board(boardID)
 							if err != nil {
 								return nil, false, fmt.Errorf("invalid board_id from HUBBLE_CREDENTIALS: %w", err)
 							}
@@ -103,8 +160,11 @@ func PromptForConfig() (*Config, bool, error) {
 		}
 	}
 
-	// Check environment variables
+	// Check environment variables (a command line Org ID overrides the environment)
 	envOrgID := os.Getenv("HUBBLE_ORG_ID")
+	if opts.OrgID != "" {
+		envOrgID = opts.OrgID
+	}
 	envAPIToken := os.Getenv("HUBBLE_API_TOKEN")
 
 	// If both are present, use them
@@ -120,6 +180,27 @@ func PromptForConfig() (*Config, bool, error) {
 		return config, preConfigured, nil
 	}
 
+	// Fall back to the current saved profile when nothing was supplied for this run
+	if envOrgID == "" && envAPIToken == "" {
+		store, err := opts.loadStore()
+		if err != nil {
+			ui.PrintWarning(fmt.Sprintf("Ignoring saved profiles: %v", err))
+		} else if store.Current != "" {
+			if err := config.applyProfile(store, store.Current); err != nil {
+				return nil, false, err
+			}
+			return config, true, nil
+		}
+	}
+
+	// Without a terminal there is nobody to prompt, so report what is missing
+	if opts.NonInteractive {
+		if envOrgID == "" {
+			return nil, false, fmt.Errorf("org ID is required: pass --org-id or set HUBBLE_ORG_ID")
+		}
+		return nil, false, fmt.Errorf("API token is required: set HUBBLE_API_TOKEN")
+	}
+
 	// Print info about where to find credentials
 	ui.PrintInfo("Get your credentials at: https://dash.hubble.com/developer/api-tokens")
 	fmt.Println()
@@ -163,9 +244,61 @@ func PromptForConfig() (*Config, bool, error) {
 
 	ui.PrintSuccess("Credentials configured")
 
+	// Storing credentials is opt-in; the default answer keeps them off disk
+	if envOrgID == "" && envAPIToken == "" && !opts.NoSave {
+		fmt.Println()
+		if ui.PromptYesNo("Save these credentials as a profile for future runs?", false) {
+			offerSaveProfile(config, opts)
+		}
+	}
+
 	return config, preConfigured, nil
 }
 
+// applyProfile loads the named profile from store into the config
+func (c *Config) applyProfile(store *Store, name string) error {
+	profile, err := store.Get(name)
+	if err != nil {
+		return err
+	}
+	if err := validateCredentials(profile.OrgID, profile.APIToken); err != nil {
+		return fmt.Errorf("invalid credentials in profile %q: %w", name, err)
+	}
+
+	c.OrgID = profile.OrgID
+	c.APIToken = profile.APIToken
+	c.Profile = name
+	ui.PrintSuccess(fmt.Sprintf("Using saved profile %q", name))
+	return nil
+}
+
+// offerSaveProfile asks for a profile name and saves the credentials under it.
+// Failures are reported but never stop the installation.
+func offerSaveProfile(config *Config, opts Options) {
+	name := ui.PromptOptionalInput("Profile name (default: \"default\")")
+	if name == "" {
+		name = "default"
+	}
+
+	backend := secrets.Default()
+	store, err := opts.loadStore()
+	if err == nil {
+		err = store.Add(name, Profile{OrgID: config.OrgID, APIToken: config.APIToken}, backend)
+	}
+	if err == nil {
+		err = store.Use(name)
+	}
+	if err == nil {
+		err = store.Save()
+	}
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Could not save profile: %v", err))
+		return
+	}
+	config.Profile = name
+	ui.PrintSuccess(fmt.Sprintf("Saved profile %q to %s (API token in %s)", name, store.Path(), backend.Name()))
+}
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	if c.OrgID == "" {
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+)
+
+func TestPromptForConfigPrecedence(t *testing.T) {
+	const (
+		profileOrgID = "11111111-1111-1111-1111-111111111111"
+		credsOrgID   = "22222222-2222-2222-2222-222222222222"
+		envOrgID     = "33333333-3333-3333-3333-333333333333"
+		flagOrgID    = "44444444-4444-4444-4444-444444444444"
+	)
+	credentials := base64.StdEncoding.EncodeToString([]byte(credsOrgID + ":" + testAPIToken))
+
+	tests := []struct {
+		name        string
+		opts        Options
+		credentials string // HUBBLE_CREDENTIALS
+		envOrgID    string // HUBBLE_ORG_ID
+		envToken    string // HUBBLE_API_TOKEN
+		wantOrgID   string
+		wantProfile string
+		wantErr     bool
+	}{
+		{name: "profile over HUBBLE_CREDENTIALS", opts: Options{Profile: "work"}, credentials: credentials, wantOrgID: profileOrgID, wantProfile: "work"},
+		{name: "profile over HUBBLE_ORG_ID", opts: Options{Profile: "work"}, envOrgID: envOrgID, envToken: testAPIToken, wantOrgID: profileOrgID, wantProfile: "work"},
+		{name: "profile with org ID", opts: Options{Profile: "work", OrgID: flagOrgID}, wantErr: true},
+		{name: "org ID over HUBBLE_CREDENTIALS", opts: Options{OrgID: flagOrgID}, credentials: credentials, envToken: testAPIToken, wantOrgID: flagOrgID},
+		{name: "org ID over HUBBLE_ORG_ID", opts: Options{OrgID: flagOrgID}, envOrgID: envOrgID, envToken: testAPIToken, wantOrgID: flagOrgID},
+		{name: "org ID without a token", opts: Options{OrgID: flagOrgID}, credentials: credentials, wantErr: true},
+		{name: "HUBBLE_CREDENTIALS over HUBBLE_ORG_ID", credentials: credentials, envOrgID: envOrgID, envToken: testAPIToken, wantOrgID: credsOrgID},
+		{name: "environment over the default profile", envOrgID: envOrgID, envToken: testAPIToken, wantOrgID: envOrgID},
+		{name: "default profile", wantOrgID: profileOrgID, wantProfile: "work"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HUBBLE_CREDENTIALS", tt.credentials)
+			t.Setenv("HUBBLE_ORG_ID", tt.envOrgID)
+			t.Setenv("HUBBLE_API_TOKEN", tt.envToken)
+
+			tt.opts.StorePath = filepath.Join(t.TempDir(), "credentials.json")
+			store, err := LoadStoreFrom(tt.opts.StorePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Add("work", Profile{OrgID: profileOrgID, APIToken: testAPIToken}, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.opts.NonInteractive = true
+			cfg, _, err := PromptForConfig(tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("PromptForConfig() = %+v, want an error", cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PromptForConfig() = %v", err)
+			}
+			if cfg.OrgID != tt.wantOrgID || cfg.Profile != tt.wantProfile {
+				t.Errorf("PromptForConfig() chose Org ID %s from profile %q, want %s from %q", cfg.OrgID, cfg.Profile, tt.wantOrgID, tt.wantProfile)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/HubbleNetwork/hubble-install/internal/secrets"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// Profile is a named set of saved credentials. The API token is either kept in the
+// profile itself or, when TokenStore names a secret backend, held by that backend.
+type Profile struct {
+	OrgID      string `json:"org_id"`
+	APIToken   string `json:"api_token,omitempty"`
+	TokenStore string `json:"token_store,omitempty"`
+}
+
+// TokenStorePlaintext keeps the API token in the profile file itself
+const TokenStorePlaintext = "plaintext"
+
+// Store holds the saved credential profiles. It is only written when the user
+// explicitly saves a profile; by default credentials are never stored on disk.
+type Store struct {
+	Current  string             `json:"current,omitempty"`
+	Profiles map[string]Profile `json:"profiles"`
+
+	path     string
+	backends func(name string) (secrets.Backend, error) // Looks up a profile's secret backend; nil means secrets.ByName
+}
+
+// DefaultStorePath returns the location of the credential store in the user config directory
+func DefaultStorePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+	return filepath.Join(configDir, "hubble", "credentials.json"), nil
+}
+
+// LoadStore reads the credential store from its default location.
+// A missing file yields an empty store.
+func LoadStore() (*Store, error) {
+	path, err := DefaultStorePath()
+	if err != nil {
+		return nil, err
+	}
+	return LoadStoreFrom(path)
+}
+
+// LoadStoreFrom reads the credential store from path. A missing file yields an empty store.
+func LoadStoreFrom(path string) (*Store, error) {
+	store := &Store{Profiles: make(map[string]Profile), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential store: %w", err)
+	}
+
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("credential store %s is corrupt: %w", path, err)
+	}
+	if store.Profiles == nil {
+		store.Profiles = make(map[string]Profile)
+	}
+	return store, nil
+}
+
+// Path returns the file the store is read from and saved to
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Save writes the store to disk, readable only by the current user
+func (s *Store) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode credential store: %w", err)
+	}
+
+	// Write to a temporary file first so a failed write never truncates existing profiles
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credential store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write credential store: %w", err)
+	}
+
+	// WriteFile only applies the mode to new files; tighten an existing one too
+	return os.Chmod(s.path, 0600)
+}
+
+// Add validates and stores a profile, replacing any existing profile with the same name.
+// The API token is written to backend, or kept in the profile file if backend is nil.
+// The token of a replaced profile is deleted from a backend the new one does not use.
+// The first profile added becomes the current profile.
+func (s *Store) Add(name string, profile Profile, backend secrets.Backend) error {
+	if err := validateProfileName(name); err != nil {
+		return err
+	}
+	if err := validateCredentials(profile.OrgID, profile.APIToken); err != nil {
+		return err
+	}
+	ui.Redact(profile.APIToken)
+
+	profile.TokenStore = ""
+	if backend != nil {
+		if err := backend.Set(name, profile.APIToken); err != nil {
+			return fmt.Errorf("failed to store API token in %s: %w", backend.Name(), err)
+		}
+		profile.APIToken = ""
+		profile.TokenStore = backend.Name()
+	}
+
+	// The new token is stored, so a token left behind only costs a warning
+	if previous, ok := s.Profiles[name]; ok && previous.TokenStore != "" && previous.TokenStore != profile.TokenStore {
+		if err := s.deleteToken(name, previous.TokenStore); err != nil {
+			ui.PrintWarning(fmt.Sprintf("The previous API token of profile %q is still in %s: %v", name, previous.TokenStore, err))
+		}
+	}
+
+	s.Profiles[name] = profile
+	if s.Current == "" {
+		s.Current = name
+	}
+	return nil
+}
+
+// Remove deletes a profile and its stored token, clearing the current profile if it was the one removed
+func (s *Store) Remove(name string) error {
+	profile, ok := s.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+
+	if profile.TokenStore != "" {
+		if err := s.deleteToken(name, profile.TokenStore); err != nil {
+			return err
+		}
+	}
+
+	delete(s.Profiles, name)
+	if s.Current == name {
+		s.Current = ""
+	}
+	return nil
+}
+
+// Use makes name the profile consulted when no other credentials are given
+func (s *Store) Use(name string) error {
+	if _, ok := s.Profiles[name]; !ok {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+	s.Current = name
+	return nil
+}
+
+// Get returns the named profile with its API token resolved from the secret
+// backend. The token is masked in the run log from then on.
+func (s *Store) Get(name string) (Profile, error) {
+	profile, ok := s.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile not found: %s", name)
+	}
+	if profile.TokenStore == "" {
+		ui.Redact(profile.APIToken)
+		return profile, nil
+	}
+
+	backend, err := s.backend(profile.TokenStore)
+	if err != nil {
+		return Profile{}, err
+	}
+	token, err := backend.Get(name)
+	if err != nil {
+		return Profile{}, fmt.Errorf("failed to read API token for profile %q from %s: %w", name, profile.TokenStore, err)
+	}
+	ui.Redact(token)
+	profile.APIToken = token
+	return profile, nil
+}
+
+// backend returns the secret backend a profile's token is stored in
+func (s *Store) backend(tokenStore string) (secrets.Backend, error) {
+	if s.backends != nil {
+		return s.backends(tokenStore)
+	}
+	return secrets.ByName(tokenStore)
+}
+
+// deleteToken removes the API token of profile name from the backend tokenStore
+func (s *Store) deleteToken(name, tokenStore string) error {
+	backend, err := s.backend(tokenStore)
+	if err != nil {
+		return err
+	}
+	if err := backend.Delete(name); err != nil {
+		return fmt.Errorf("failed to remove API token from %s: %w", tokenStore, err)
+	}
+	return nil
+}
+
+// BackendFor returns the secret backend for a --token-store value: "keyring", "file",
+// "plaintext" (nil backend), or empty for the default (keyring, falling back to file)
+func BackendFor(tokenStore string) (secrets.Backend, error) {
+	switch tokenStore {
+	case "":
+		return secrets.Default(), nil
+	case TokenStorePlaintext:
+		return nil, nil
+	}
+
+	backend, err := secrets.ByName(tokenStore)
+	if err != nil {
+		return nil, err
+	}
+	if !backend.Available() {
+		return nil, fmt.Errorf("%s: %w", tokenStore, secrets.ErrUnavailable)
+	}
+	return backend, nil
+}
+
+// Names returns the profile names in alphabetical order
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.Profiles))
+	for name := range s.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MaskToken hides all but the last four characters of an API token for display
+func MaskToken(token string) string {
+	if len(token) <= 4 {
+		return strings.Repeat("*", len(token))
+	}
+	return strings.Repeat("*", 8) + token[len(token)-4:]
+}
+
+// validateProfileName checks that a profile name is usable as a single CLI argument
+func validateProfileName(name string) error {
+	if name == "" {
+		return fmt.Errorf("profile name cannot be empty")
+	}
+	if strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("profile name cannot contain whitespace: %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/secrets"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+const (
+	testOrgID    = "0f61efd0-24a7-4a2e-ae0f-8549d14ed901"
+	testAPIToken = "eb31d24113fadb77c6d89d65a8007c0eed3595e2255aaf1d7d81783900ab33be4332457a27861f67cc78fe930ea52941"
+)
+
+// fakeBackend keeps secrets in memory
+type fakeBackend struct {
+	name    string
+	secrets map[string]string
+}
+
+func newFakeBackend(name string) *fakeBackend {
+	return &fakeBackend{name: name, secrets: make(map[string]string)}
+}
+
+func (f *fakeBackend) Name() string    { return f.name }
+func (f *fakeBackend) Available() bool { return true }
+
+func (f *fakeBackend) Get(account string) (string, error) {
+	secret, ok := f.secrets[account]
+	if !ok {
+		return "", secrets.ErrNotFound
+	}
+	return secret, nil
+}
+
+func (f *fakeBackend) Set(account, secret string) error {
+	f.secrets[account] = secret
+	return nil
+}
+
+func (f *fakeBackend) Delete(account string) error {
+	delete(f.secrets, account)
+	return nil
+}
+
+// fakeStore returns an empty store whose profiles use the given backends
+func fakeStore(t *testing.T, backends ...*fakeBackend) *Store {
+	t.Helper()
+	store, err := LoadStoreFrom(filepath.Join(t.TempDir(), "credentials.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.backends = func(name string) (secrets.Backend, error) {
+		for _, backend := range backends {
+			if backend.name == name {
+				return backend, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown secret backend: %s", name)
+	}
+	return store
+}
+
+func TestAddReplacingKeyringProfileDeletesOldToken(t *testing.T) {
+	keyring, file := newFakeBackend(secrets.BackendKeyring), newFakeBackend(secrets.BackendFile)
+
+	tests := []struct {
+		name        string
+		replacement secrets.Backend
+		wantKeyring bool
+	}{
+		{"with a plaintext profile", nil, false},
+		{"in another backend", file, false},
+		{"in the same backend", keyring, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := fakeStore(t, keyring, file)
+			if err := store.Add("work", Profile{OrgID: testOrgID, APIToken: testAPIToken}, keyring); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Add("work", Profile{OrgID: testOrgID, APIToken: testAPIToken}, tt.replacement); err != nil {
+				t.Fatalf("Add() = %v", err)
+			}
+
+			if _, inKeyring := keyring.secrets["work"]; inKeyring != tt.wantKeyring {
+				t.Errorf("token in keyring: %v, want %v", inKeyring, tt.wantKeyring)
+			}
+			profile, err := store.Get("work")
+			if err != nil || profile.APIToken != testAPIToken {
+				t.Errorf("Get() = %+v, %v, want the new token", profile, err)
+			}
+		})
+	}
+}
+
+func TestGetMasksKeyringTokenInLog(t *testing.T) {
+	// Not shaped like a Hubble token, so only registering it masks it
+	const token = "keyring-held-token"
+	keyring := newFakeBackend(secrets.BackendKeyring)
+	keyring.secrets["work"] = token
+	store := fakeStore(t, keyring)
+	store.Profiles["work"] = Profile{OrgID: testOrgID, TokenStore: secrets.BackendKeyring}
+
+	path := filepath.Join(t.TempDir(), "run.log")
+	if err := ui.OpenLog(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ui.CloseLog)
+
+	profile, err := store.Get("work")
+	if err != nil || profile.APIToken != token {
+		t.Fatalf("Get() = %+v, %v", profile, err)
+	}
+	ui.Logf("$ hubbledemo flash --token %s", profile.APIToken)
+	ui.CloseLog()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Errorf("log leaked the keyring token:\n%s", data)
+	}
+}
@@ -0,0 +1,109 @@
+// Package doctor diagnoses the machine the installer runs on: pending reboots,
+// missing dependencies, tool versions, connected probes and network access. Each
+// diagnosis is a Check, so support can ask for one report covering all of them.
+package doctor
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+)
+
+// Status is the outcome of a check
+type Status string
+
+// Check outcomes, from best to worst
+const (
+	Pass Status = "pass" // Nothing to do
+	Warn Status = "warn" // Installing or flashing may run into trouble
+	Fail Status = "fail" // Installing or flashing cannot succeed until this is fixed
+)
+
+// Result is what a check found
+type Result struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Status  Status   `json:"status"`
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"` // One line per finding, e.g. per board or endpoint
+}
+
+// Check is one diagnosis. Run only inspects the system through env, so a check
+// can be exercised against a scripted platform.Runner.
+type Check struct {
+	ID   string // Machine-readable ID, e.g. "reboot"
+	Name string // Title shown in the report
+	Run  func(env *Env) Result
+}
+
+// Endpoint is a server the installer needs to reach
+type Endpoint struct {
+	Name     string
+	URL      string
+	Required bool // Provisioning cannot work without it, rather than only installing
+}
+
+// Env is what checks inspect
+type Env struct {
+	GOOS        string
+	Runner      platform.Runner
+	Installer   platform.Installer
+	Catalog     *boards.Catalog // Boards whose dependencies and probes are checked
+	ToolVersion string          // pyhubbledemo release flashing uses
+	HTTPClient  *http.Client
+	Endpoints   []Endpoint
+	Timeout     time.Duration // Limit for each network request
+}
+
+// Checks are the checks a report runs, in order
+var Checks = []Check{
+	{ID: "reboot", Name: "Pending reboot", Run: checkReboot},
+	{ID: "prerequisites", Name: "Board dependencies", Run: checkPrerequisites},
+	{ID: "path", Name: "PATH", Run: checkPath},
+	{ID: "uv", Name: "uv", Run: checkUV},
+	{ID: "jlink", Name: "SEGGER J-Link", Run: checkJLink},
+	{ID: "pyhubbledemo", Name: "pyhubbledemo", Run: checkTool},
+	{ID: "probes", Name: "Connected boards", Run: checkProbes},
+	{ID: "proxy", Name: "Proxy", Run: checkProxy},
+	{ID: "network", Name: "Network", Run: checkNetwork},
+}
+
+// Run runs checks against env and returns their results in order
+func Run(env *Env, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		result := check.Run(env)
+		result.ID, result.Name = check.ID, check.Name
+		results = append(results, result)
+	}
+	return results
+}
+
+// Worst returns the worst status among results
+func Worst(results []Result) Status {
+	worst := Pass
+	for _, result := range results {
+		switch {
+		case result.Status == Fail:
+			return Fail
+		case result.Status == Warn:
+			worst = Warn
+		}
+	}
+	return worst
+}
+
+// pass, warn and fail build results
+func pass(message string, details ...string) Result {
+	return Result{Status: Pass, Message: message, Details: details}
+}
+
+func warn(message string, details ...string) Result {
+	return Result{Status: Warn, Message: message, Details: details}
+}
+
+func fail(message string, details ...string) Result {
+	return Result{Status: Fail, Message: message, Details: details}
+}
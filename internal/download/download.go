@@ -0,0 +1,341 @@
+// Package download fetches installers over slow or unreliable networks.
+// Interrupted transfers resume where they stopped with HTTP Range requests,
+// failures are retried with exponential backoff, and progress is rendered
+// through the ui package. Proxies are taken from HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY, and extra certificate authorities from HUBBLE_CA_BUNDLE.
+package download
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// CABundleEnv names a PEM file of certificate authorities trusted in addition to
+// the system ones, for networks that intercept TLS
+const CABundleEnv = "HUBBLE_CA_BUNDLE"
+
+// Defaults for a Client
+const (
+	DefaultAttempts     = 5
+	DefaultBackoff      = time.Second
+	DefaultMaxBackoff   = 30 * time.Second
+	DefaultStallTimeout = time.Minute
+)
+
+// errStalled cancels a transfer that has stopped receiving data
+var errStalled = errors.New("no data received")
+
+// StatusError is an unexpected HTTP response status
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "bad status: " + e.Status
+}
+
+// retryable reports whether the server may answer differently next time
+func (e *StatusError) retryable() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Client downloads files, resuming and retrying interrupted transfers
+type Client struct {
+	HTTPClient   *http.Client
+	Attempts     int           // Tries before giving up, including the first
+	Backoff      time.Duration // Wait before the first retry, doubled for each one after it
+	MaxBackoff   time.Duration // Longest wait between retries
+	StallTimeout time.Duration // How long a transfer may go without receiving data
+	Progress     bool          // Render a progress bar
+}
+
+// NewClient creates a client that trusts the certificate authorities in
+// HUBBLE_CA_BUNDLE, if set, and renders progress
+func NewClient() (*Client, error) {
+	httpClient, err := NewHTTPClient(os.Getenv(CABundleEnv))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		HTTPClient:   httpClient,
+		Attempts:     DefaultAttempts,
+		Backoff:      DefaultBackoff,
+		MaxBackoff:   DefaultMaxBackoff,
+		StallTimeout: DefaultStallTimeout,
+		Progress:     true,
+	}, nil
+}
+
+// NewHTTPClient creates an HTTP client that uses the proxies from the environment
+// and trusts the certificate authorities in the PEM file caFile (if not empty) as
+// well as the system ones. It sets no overall timeout, as large downloads on slow
+// networks take a long time; Client detects stalled transfers instead.
+func NewHTTPClient(caFile string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.ResponseHeaderTimeout = DefaultStallTimeout
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s holds no PEM certificates", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// Fetch downloads url into destPath. The transfer is written to destPath.part
+// and moved into place once complete; a transfer that fails is resumed from the
+// partial file, up to Attempts times.
+func (c *Client) Fetch(ctx context.Context, url, destPath string) error {
+	partPath := destPath + ".part"
+	t := &transfer{client: c, url: url, partPath: partPath}
+	if c.Progress {
+		t.progress = ui.NewProgress(path.Base(url))
+		defer t.progress.Done()
+	}
+
+	attempts := max(c.Attempts, 1)
+	backoff := c.Backoff
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if t.progress != nil {
+				t.progress.Done()
+			}
+			ui.PrintWarning(fmt.Sprintf("Download interrupted (%v); retrying in %s (attempt %d of %d)...", err, backoff, attempt, attempts))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, max(c.MaxBackoff, c.Backoff))
+		}
+
+		err = t.attempt(ctx)
+		if err == nil {
+			if err := os.Rename(partPath, destPath); err != nil {
+				os.Remove(partPath)
+				return fmt.Errorf("failed to save file: %w", err)
+			}
+			return nil
+		}
+		if ctx.Err() != nil || !retryable(err) {
+			break
+		}
+	}
+	os.Remove(partPath)
+	return err
+}
+
+// retryable reports whether a failed attempt may succeed if tried again. Local
+// file errors, untrusted certificates and client errors will not.
+func retryable(err error) bool {
+	var status *StatusError
+	var fileErr *fileError
+	var certErr *tls.CertificateVerificationError
+	switch {
+	case errors.As(err, &status):
+		return status.retryable()
+	case errors.As(err, &fileErr), errors.As(err, &certErr):
+		return false
+	}
+	return true
+}
+
+// transfer is the state of one download carried across attempts
+type transfer struct {
+	client    *Client
+	url       string
+	partPath  string
+	validator string // ETag or Last-Modified of the partial content, sent as If-Range
+	progress  *ui.Progress
+}
+
+// fileError is a failure to write the download locally, which retrying cannot fix
+type fileError struct {
+	err error
+}
+
+func (e *fileError) Error() string {
+	return "failed to save file: " + e.err.Error()
+}
+
+func (e *fileError) Unwrap() error {
+	return e.err
+}
+
+// attempt requests the rest of the file after what is already in the partial
+// file and appends it
+func (t *transfer) attempt(parent context.Context) error {
+	var offset int64
+	if info, err := os.Stat(t.partPath); err == nil && t.validator != "" {
+		offset = info.Size()
+	}
+
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+	stallTimeout := t.client.StallTimeout
+	if stallTimeout <= 0 {
+		stallTimeout = DefaultStallTimeout
+	}
+	watchdog := time.AfterFunc(stallTimeout, func() { cancel(errStalled) })
+	defer watchdog.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", t.validator)
+	}
+
+	httpClient := t.client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return stallCause(ctx, err, stallTimeout)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(resp) == offset:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusPartialContent:
+		// Not the range that was asked for; start over on the next attempt
+		t.validator = ""
+		return fmt.Errorf("server resumed the download at the wrong offset")
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the range or the file changed; start over
+		offset = 0
+		flags |= os.O_TRUNC
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// Nothing is left after the partial file when an earlier attempt received
+		// the last byte but failed before the end of the response
+		if size := contentRangeSize(resp); size == -1 || size == offset {
+			return nil
+		}
+		t.validator = ""
+		return fmt.Errorf("server cannot resume the download at byte %d", offset)
+	default:
+		t.validator = ""
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	// Only resume later if the server can tell whether the file changed in between
+	t.validator = resp.Header.Get("ETag")
+	if t.validator == "" || strings.HasPrefix(t.validator, "W/") {
+		t.validator = resp.Header.Get("Last-Modified")
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" && resp.StatusCode != http.StatusPartialContent {
+		t.validator = ""
+	}
+
+	out, err := os.OpenFile(t.partPath, flags, 0644)
+	if err != nil {
+		return &fileError{err: err}
+	}
+	defer out.Close()
+
+	if t.progress != nil {
+		total := int64(-1)
+		if resp.ContentLength >= 0 {
+			total = offset + resp.ContentLength
+		}
+		t.progress.Start(offset, total)
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			watchdog.Reset(stallTimeout)
+			if _, err := out.Write(buf[:n]); err != nil {
+				return &fileError{err: err}
+			}
+			if t.progress != nil {
+				t.progress.Add(int64(n))
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return stallCause(ctx, readErr, stallTimeout)
+		}
+	}
+	if err := out.Close(); err != nil {
+		return &fileError{err: err}
+	}
+
+	if resp.ContentLength >= 0 {
+		if info, err := os.Stat(t.partPath); err == nil && info.Size() != offset+resp.ContentLength {
+			return fmt.Errorf("received %d of %d bytes", info.Size(), offset+resp.ContentLength)
+		}
+	}
+	return nil
+}
+
+// stallCause reports a transfer cancelled by the stall watchdog as stalled
+func stallCause(ctx context.Context, err error, timeout time.Duration) error {
+	if errors.Is(context.Cause(ctx), errStalled) {
+		return fmt.Errorf("%w for %s", errStalled, timeout)
+	}
+	return err
+}
+
+// contentRangeStart returns the first byte of a 206 response, or -1 if it is missing
+func contentRangeStart(resp *http.Response) int64 {
+	value, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, ok := strings.Cut(value, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// contentRangeSize returns the complete length of the file from a 416 response's
+// Content-Range, e.g. "bytes */1234", or -1 if it is missing
+func contentRangeSize(resp *http.Response) int64 {
+	value, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes */")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,131 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// payload is the file the test servers serve
+var payload = bytes.Repeat([]byte("hubble "), 10000)
+
+// testClient returns a client that retries without waiting
+func testClient() *Client {
+	return &Client{HTTPClient: http.DefaultClient, Attempts: 3, Backoff: time.Millisecond, StallTimeout: 5 * time.Second}
+}
+
+// flakyServer serves payload with range support, running first for the first
+// request instead. It returns the Range header of every request so far.
+func flakyServer(t *testing.T, first func(w http.ResponseWriter)) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		isFirst := len(ranges) == 1
+		mu.Unlock()
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Accept-Ranges", "bytes")
+		if isFirst {
+			first(w)
+			return
+		}
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(payload))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(ranges)
+	}
+}
+
+// hangUp sends body, announcing length bytes (-1 for a chunked response), then
+// drops the connection
+func hangUp(t *testing.T, w http.ResponseWriter, body []byte, length int) {
+	if length >= 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(length))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	w.(http.Flusher).Flush()
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conn.Close()
+}
+
+// fetch downloads url and returns the saved file
+func fetch(t *testing.T, url string) []byte {
+	t.Helper()
+	dest := filepath.Join(t.TempDir(), "file.bin")
+	if err := testClient().Fetch(context.Background(), url, dest); err != nil {
+		t.Fatalf("Fetch() = %v", err)
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Errorf("partial file left behind")
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestFetchResumesDroppedConnection(t *testing.T) {
+	half := len(payload) / 2
+	server, ranges := flakyServer(t, func(w http.ResponseWriter) {
+		hangUp(t, w, payload[:half], len(payload))
+	})
+
+	if got := fetch(t, server.URL); !bytes.Equal(got, payload) {
+		t.Fatalf("downloaded %d bytes that differ from the %d served", len(got), len(payload))
+	}
+	want := []string{"", "bytes=" + strconv.Itoa(half) + "-"}
+	if got := ranges(); !slices.Equal(got, want) {
+		t.Errorf("requested ranges %q, want %q", got, want)
+	}
+}
+
+func TestFetchFinishesCompletePartialFile(t *testing.T) {
+	// Every byte arrives, but the connection drops before the response ends, so
+	// the resumed request asks for a range past the end of the file
+	server, ranges := flakyServer(t, func(w http.ResponseWriter) {
+		hangUp(t, w, payload, -1)
+	})
+
+	if got := fetch(t, server.URL); !bytes.Equal(got, payload) {
+		t.Fatalf("downloaded %d bytes that differ from the %d served", len(got), len(payload))
+	}
+	want := []string{"", "bytes=" + strconv.Itoa(len(payload)) + "-"}
+	if got := ranges(); !slices.Equal(got, want) {
+		t.Errorf("requested ranges %q, want %q", got, want)
+	}
+}
+
+func TestFetchRestartsWhenPartialFileIsTooLong(t *testing.T) {
+	// More arrives than the file now holds, so the partial file cannot be finished
+	extra := append(slices.Clone(payload), "stale"...)
+	server, ranges := flakyServer(t, func(w http.ResponseWriter) {
+		hangUp(t, w, extra, -1)
+	})
+
+	if got := fetch(t, server.URL); !bytes.Equal(got, payload) {
+		t.Fatalf("downloaded %d bytes that differ from the %d served", len(got), len(payload))
+	}
+	if got := ranges(); len(got) != 3 || got[2] != "" {
+		t.Errorf("requested ranges %q, want a fresh download after the rejected resume", got)
+	}
+}
@@ -0,0 +1,185 @@
+package hubbleapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/download"
+)
+
+// DefaultBaseURL is the production Hubble platform API
+const DefaultBaseURL = "https://api.hubble.com"
+
+// BaseURLEnv overrides the API base URL (e.g. for staging)
+const BaseURLEnv = "HUBBLE_API_URL"
+
+var (
+	// ErrUnauthorized means the API token was rejected: mistyped, expired or revoked
+	ErrUnauthorized = errors.New("API token is invalid, expired or revoked")
+
+	// ErrForbidden means the token is valid but does not belong to the organization
+	ErrForbidden = errors.New("API token is not authorized for this organization")
+
+	// ErrOrgNotFound means no organization exists with the given Org ID
+	ErrOrgNotFound = errors.New("organization not found")
+
+	// ErrNotFound means the requested resource (e.g. a device) does not exist
+	ErrNotFound = errors.New("not found")
+)
+
+// NetworkError is returned when the API could not be reached at all
+type NetworkError struct {
+	URL string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("could not reach Hubble API at %s: %v", e.URL, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// APIError is returned for unexpected HTTP responses
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("Hubble API returned %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("Hubble API returned %d", e.StatusCode)
+}
+
+// Client talks to the Hubble platform API on behalf of one organization
+type Client struct {
+	BaseURL    string
+	OrgID      string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// BaseURL returns the API base URL from HUBBLE_API_URL, or the production API if it is unset
+func BaseURL() string {
+	baseURL := os.Getenv(BaseURLEnv)
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return strings.TrimRight(baseURL, "/")
+}
+
+// NewClient creates a client for orgID at BaseURL. Like downloads, it honors the
+// proxies from the environment and the certificate authorities in HUBBLE_CA_BUNDLE.
+func NewClient(orgID, token string) (*Client, error) {
+	httpClient, err := download.NewHTTPClient(os.Getenv(download.CABundleEnv))
+	if err != nil {
+		return nil, err
+	}
+	httpClient.Timeout = 30 * time.Second
+	return &Client{
+		BaseURL:    BaseURL(),
+		OrgID:      orgID,
+		Token:      token,
+		HTTPClient: httpClient,
+	}, nil
+}
+
+// VerifyCredentials confirms that the organization exists and the token may access it
+func (c *Client) VerifyCredentials(ctx context.Context) error {
+	err := c.do(ctx, http.MethodGet, c.orgPath(""), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return ErrOrgNotFound
+	}
+	return err
+}
+
+// orgPath returns the API path for a resource under the client's organization
+func (c *Client) orgPath(suffix string) string {
+	return "/api/org/" + url.PathEscape(c.OrgID) + suffix
+}
+
+// do sends a JSON request and decodes a JSON response into out (if non-nil)
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	requestURL := c.BaseURL + path
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return &NetworkError{URL: c.BaseURL, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode API response: %w", err)
+	}
+	return nil
+}
+
+// checkResponse maps non-2xx responses to the package's errors
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	// Prefer the server's error message when it sends one
+	var payload struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if json.Unmarshal(data, &payload) == nil && payload.Message == "" {
+		payload.Message = payload.Error
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return &APIError{StatusCode: resp.StatusCode, Message: payload.Message}
+	}
+}
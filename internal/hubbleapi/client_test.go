@@ -0,0 +1,50 @@
+package hubbleapi
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/download"
+)
+
+func TestNewClientTrustsCABundle(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // The untrusted handshake is expected
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	t.Setenv(BaseURLEnv, server.URL)
+
+	t.Setenv(download.CABundleEnv, "")
+	client, err := NewClient("org-1", "secret")
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	if err := client.VerifyCredentials(context.Background()); err == nil {
+		t.Fatalf("VerifyCredentials() trusted a certificate outside the system pool")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(download.CABundleEnv, caFile)
+	if client, err = NewClient("org-1", "secret"); err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	if err := client.VerifyCredentials(context.Background()); err != nil {
+		t.Errorf("VerifyCredentials() = %v, want the CA bundle trusted", err)
+	}
+
+	t.Setenv(download.CABundleEnv, filepath.Join(t.TempDir(), "missing.pem"))
+	if _, err := NewClient("org-1", "secret"); err == nil {
+		t.Errorf("NewClient() ignored a missing CA bundle")
+	}
+}
@@ -0,0 +1,115 @@
+package hubbleapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DefaultEncryption is the key type requested for newly registered devices
+const DefaultEncryption = "AES-256-CTR"
+
+// Device is a device registered to an organization
+type Device struct {
+	ID   string `json:"device_id"`
+	Name string `json:"name,omitempty"`
+	Key  string `json:"key,omitempty"` // Base64 encryption key, only returned at registration
+}
+
+// registerRequest is the body of a device registration request
+type registerRequest struct {
+	Count      int    `json:"n_devices"`
+	Encryption string `json:"encryption"`
+}
+
+// devicesResponse wraps the device lists returned by the API. A list that does
+// not fit in one response carries a token that requests the next page.
+type devicesResponse struct {
+	Devices           []Device `json:"devices"`
+	ContinuationToken string   `json:"continuation_token,omitempty"`
+}
+
+// NamingError means a device was registered, but could not be given its name
+type NamingError struct {
+	DeviceID string
+	Err      error
+}
+
+func (e *NamingError) Error() string {
+	return fmt.Sprintf("device %s was registered, but could not be named: %v", e.DeviceID, e.Err)
+}
+
+func (e *NamingError) Unwrap() error {
+	return e.Err
+}
+
+// updateRequest is the body of a device update request
+type updateRequest struct {
+	Devices []Device `json:"devices"`
+}
+
+// RegisterDevice registers a new device in the organization and, if name is not
+// empty, names it. The returned device carries its ID and encryption key. When
+// naming fails, the registered device is returned together with a *NamingError,
+// so the caller can go on with the unnamed device or delete it.
+func (c *Client) RegisterDevice(ctx context.Context, name string) (*Device, error) {
+	var resp devicesResponse
+	req := registerRequest{Count: 1, Encryption: DefaultEncryption}
+	if err := c.do(ctx, http.MethodPost, c.orgPath("/devices"), req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Devices) != 1 || resp.Devices[0].ID == "" || resp.Devices[0].Key == "" {
+		return nil, errors.New("device registration returned no device")
+	}
+
+	device := resp.Devices[0]
+	if name != "" {
+		if err := c.SetDeviceName(ctx, device.ID, name); err != nil {
+			return &device, &NamingError{DeviceID: device.ID, Err: err}
+		}
+		device.Name = name
+	}
+	return &device, nil
+}
+
+// SetDeviceName renames a device in the organization
+func (c *Client) SetDeviceName(ctx context.Context, deviceID, name string) error {
+	req := updateRequest{Devices: []Device{{ID: deviceID, Name: name}}}
+	return c.do(ctx, http.MethodPatch, c.orgPath("/devices"), req, nil)
+}
+
+// maxDevicePages bounds ListDevices, in case the API keeps returning a next page
+const maxDevicePages = 1000
+
+// ListDevices returns every device registered to the organization, following the
+// continuation token from page to page
+func (c *Client) ListDevices(ctx context.Context) ([]Device, error) {
+	var devices []Device
+	token := ""
+	for range maxDevicePages {
+		path := c.orgPath("/devices")
+		if token != "" {
+			path += "?" + url.Values{"continuation_token": {token}}.Encode()
+		}
+		var resp devicesResponse
+		if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+			return nil, err
+		}
+		devices = append(devices, resp.Devices...)
+		if resp.ContinuationToken == "" {
+			return devices, nil
+		}
+		if resp.ContinuationToken == token {
+			return nil, errors.New("device list did not advance past a page")
+		}
+		token = resp.ContinuationToken
+	}
+	return nil, fmt.Errorf("device list has more than %d pages", maxDevicePages)
+}
+
+// DeleteDevice removes a device from the organization
+func (c *Client) DeleteDevice(ctx context.Context, deviceID string) error {
+	return c.do(ctx, http.MethodDelete, c.orgPath("/devices/"+url.PathEscape(deviceID)), nil, nil)
+}
@@ -0,0 +1,157 @@
+package hubbleapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestClient returns a client for org "org-1" that talks to handler
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &Client{BaseURL: server.URL, OrgID: "org-1", Token: "secret", HTTPClient: server.Client()}
+}
+
+func TestRegisterDevice(t *testing.T) {
+	var renamed updateRequest
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if r.URL.Path != "/api/org/org-1/devices" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		switch r.Method {
+		case http.MethodPost:
+			var req registerRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Count != 1 || req.Encryption != DefaultEncryption {
+				t.Errorf("register request = %+v", req)
+			}
+			json.NewEncoder(w).Encode(devicesResponse{Devices: []Device{{ID: "dev-1", Key: "a2V5"}}})
+		case http.MethodPatch:
+			json.NewDecoder(r.Body).Decode(&renamed)
+		}
+	})
+
+	device, err := client.RegisterDevice(context.Background(), "sensor-1")
+	if err != nil {
+		t.Fatalf("RegisterDevice() = %v", err)
+	}
+	if device.ID != "dev-1" || device.Key != "a2V5" || device.Name != "sensor-1" {
+		t.Errorf("RegisterDevice() = %+v", device)
+	}
+	if len(renamed.Devices) != 1 || renamed.Devices[0].ID != "dev-1" || renamed.Devices[0].Name != "sensor-1" {
+		t.Errorf("rename request = %+v", renamed)
+	}
+}
+
+func TestRegisterDeviceNamingFails(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message": "database unavailable"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(devicesResponse{Devices: []Device{{ID: "dev-1", Key: "a2V5"}}})
+	})
+
+	device, err := client.RegisterDevice(context.Background(), "sensor-1")
+	var namingErr *NamingError
+	if !errors.As(err, &namingErr) {
+		t.Fatalf("RegisterDevice() = %v, want a NamingError", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "database unavailable" {
+		t.Errorf("NamingError wraps %v, want the API error", namingErr.Err)
+	}
+	if device == nil || device.ID != "dev-1" || device.Key != "a2V5" || device.Name != "" {
+		t.Errorf("RegisterDevice() returned device %+v, want the registered, unnamed device", device)
+	}
+}
+
+func TestRegisterDeviceErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"rejected token", http.StatusUnauthorized, "", ErrUnauthorized},
+		{"other organization", http.StatusForbidden, "", ErrForbidden},
+		{"no device returned", http.StatusOK, `{"devices": []}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			device, err := client.RegisterDevice(context.Background(), "")
+			if err == nil || device != nil {
+				t.Fatalf("RegisterDevice() = %+v, %v, want an error", device, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("RegisterDevice() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestListDevicesFollowsPages(t *testing.T) {
+	pages := map[string]devicesResponse{
+		"":       {Devices: []Device{{ID: "dev-1"}, {ID: "dev-2"}}, ContinuationToken: "page-2"},
+		"page-2": {Devices: []Device{{ID: "dev-3"}}, ContinuationToken: "page-3"},
+		"page-3": {Devices: []Device{{ID: "dev-4"}}},
+	}
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(pages[r.URL.Query().Get("continuation_token")])
+	})
+
+	devices, err := client.ListDevices(context.Background())
+	if err != nil {
+		t.Fatalf("ListDevices() = %v", err)
+	}
+	if len(devices) != 4 || devices[3].ID != "dev-4" {
+		t.Errorf("ListDevices() = %+v, want the devices of all three pages", devices)
+	}
+	if requests != 3 {
+		t.Errorf("ListDevices() sent %d requests, want 3", requests)
+	}
+}
+
+func TestListDevicesStuckPage(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(devicesResponse{Devices: []Device{{ID: "dev-1"}}, ContinuationToken: "same"})
+	})
+	if _, err := client.ListDevices(context.Background()); err == nil {
+		t.Fatal("ListDevices() succeeded on a page that repeats forever")
+	}
+}
+
+func TestVerifyCredentialsOrgNotFound(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	if err := client.VerifyCredentials(context.Background()); !errors.Is(err, ErrOrgNotFound) {
+		t.Errorf("VerifyCredentials() = %v, want ErrOrgNotFound", err)
+	}
+}
+
+func TestNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	client := &Client{BaseURL: server.URL, OrgID: "org-1", Token: "secret"}
+
+	var netErr *NetworkError
+	if _, err := client.ListDevices(context.Background()); !errors.As(err, &netErr) {
+		t.Errorf("ListDevices() = %v, want a NetworkError", err)
+	}
+}
@@ -0,0 +1,163 @@
+// Package journal records how far an installation got, so that a run stopped by
+// a reboot or a failure can resume where it left off. The journal never holds
+// secrets: on resume the API token comes from the saved profile, the
+// environment, or a prompt, as it did the first time.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Version is the journal format this installer reads and writes. A journal in
+// any other format is ignored rather than misread.
+const Version = 1
+
+// Actions that an interrupted run left outstanding
+const (
+	PendingReboot  = "reboot"  // The system must be rebooted before continuing
+	PendingInstall = "install" // The user agreed to install dependencies, which has not finished
+)
+
+// Journal is the saved state of an installation in progress
+type Journal struct {
+	Version     int       `json:"version"`
+	Command     string    `json:"command"`                // Command that was run: install, flash or hex
+	Board       string    `json:"board,omitempty"`        // Chosen board ID
+	DeviceName  string    `json:"device_name,omitempty"`  // Name the device is registered under
+	OrgID       string    `json:"org_id,omitempty"`       // Org ID, when not taken from a saved profile
+	Profile     string    `json:"profile,omitempty"`      // Saved credential profile that was used
+	ToolVersion string    `json:"tool_version,omitempty"` // Exact pyhubbledemo release
+	Bundle      string    `json:"bundle,omitempty"`       // Offline bundle that was installed from
+	Completed   []string  `json:"completed"`              // IDs of the steps that finished, in order
+	Pending     []string  `json:"pending,omitempty"`      // Outstanding actions
+	Updated     time.Time `json:"updated"`
+
+	path string
+}
+
+// DefaultPath returns the location of the journal in the user config directory
+func DefaultPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+	return filepath.Join(configDir, "hubble", "install-state.json"), nil
+}
+
+// New returns an empty journal for command, saved to path
+func New(path, command string) *Journal {
+	return &Journal{Version: Version, Command: command, Completed: []string{}, path: path}
+}
+
+// Load reads the journal at path. A missing file yields a nil journal and no error.
+func Load(path string) (*Journal, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read installation state: %w", err)
+	}
+
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("installation state %s is corrupt: %w", path, err)
+	}
+	if header.Version != Version {
+		return nil, fmt.Errorf("installation state %s has format %d, which this installer does not read (expected %d)", path, header.Version, Version)
+	}
+
+	j := &Journal{path: path}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("installation state %s is corrupt: %w", path, err)
+	}
+	return j, nil
+}
+
+// Path returns the file the journal is saved to
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Done reports whether step has finished
+func (j *Journal) Done(step string) bool {
+	return slices.Contains(j.Completed, step)
+}
+
+// Complete records that step has finished
+func (j *Journal) Complete(step string) {
+	if !j.Done(step) {
+		j.Completed = append(j.Completed, step)
+	}
+}
+
+// Forget records that steps have not finished after all
+func (j *Journal) Forget(steps ...string) {
+	j.Completed = slices.DeleteFunc(j.Completed, func(step string) bool { return slices.Contains(steps, step) })
+}
+
+// LastStep returns the last step that finished, or "" if none has
+func (j *Journal) LastStep() string {
+	if len(j.Completed) == 0 {
+		return ""
+	}
+	return j.Completed[len(j.Completed)-1]
+}
+
+// IsPending reports whether action is outstanding
+func (j *Journal) IsPending(action string) bool {
+	return slices.Contains(j.Pending, action)
+}
+
+// AddPending records that action is outstanding
+func (j *Journal) AddPending(action string) {
+	if !j.IsPending(action) {
+		j.Pending = append(j.Pending, action)
+	}
+}
+
+// Resolve records that action is no longer outstanding
+func (j *Journal) Resolve(action string) {
+	j.Pending = slices.DeleteFunc(j.Pending, func(a string) bool { return a == action })
+}
+
+// Save writes the journal to disk, readable only by the current user
+func (j *Journal) Save() error {
+	j.Version = Version
+	j.Updated = time.Now().UTC()
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode installation state: %w", err)
+	}
+
+	// Write to a temporary file first so an interruption never leaves a truncated journal
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write installation state: %w", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write installation state: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the journal once the installation has finished
+func (j *Journal) Remove() error {
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove installation state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/bundle"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// bundleDownloads returns the bundle path of every installer needed to install deps
+// on goos and goarch, by download URL. UniFlash is never bundled, as TI only
+// distributes it behind a license agreement.
+func bundleDownloads(goos, goarch string, deps []string) (map[string]string, error) {
+	uvURL, err := uvReleaseURL(goos, goarch)
+	if err != nil {
+		return nil, err
+	}
+	urls := []string{uvURL}
+	if slices.Contains(deps, "segger-jlink") {
+		if goos == "linux" {
+			// The package format is chosen on the target, by its package manager
+			urls = append(urls, jlinkDownloadURL(goos, goarch, "deb"), jlinkDownloadURL(goos, goarch, "rpm"))
+		} else {
+			urls = append(urls, jlinkDownloadURL(goos, goarch, ""))
+		}
+	}
+	if slices.Contains(deps, "simplicity-commander") {
+		urls = append(urls, commanderURL(goos))
+	}
+
+	downloads := make(map[string]string, len(urls))
+	for _, url := range urls {
+		downloads[url] = path.Join(bundle.DownloadsDir, path.Base(url))
+	}
+	return downloads, nil
+}
+
+// CreateBundle downloads everything needed to install deps and flash boards with
+// the given pyhubbledemo release on this platform, and packs it into an offline
+// bundle at dest. The bundle only works on the operating system and architecture
+// it was created on, as the Python runtime and wheels in it are native code.
+func CreateBundle(r Runner, deps []string, toolVersion, dest string) error {
+	version, err := ResolveToolVersion(toolVersion)
+	if err != nil {
+		return err
+	}
+	downloads, err := bundleDownloads(runtime.GOOS, runtime.GOARCH, deps)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(deps, "uniflash") {
+		ui.PrintWarning("TI UniFlash cannot be bundled; install it on the offline machine from " + uniflashDownloadPage)
+	}
+
+	staging, err := os.MkdirTemp("", "hubble-bundle-create-")
+	if err != nil {
+		return fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	urls := make([]string, 0, len(downloads))
+	for url := range downloads {
+		urls = append(urls, url)
+	}
+	slices.Sort(urls)
+	for _, url := range urls {
+		destPath := filepath.Join(staging, filepath.FromSlash(downloads[url]))
+		if err := r.MkdirAll(filepath.Dir(destPath)); err != nil {
+			return fmt.Errorf("failed to create bundle directory: %w", err)
+		}
+		if err := r.Download(url, destPath); err != nil {
+			return err
+		}
+	}
+
+	// Fill the cache with the bundled uv itself, so that the cache format matches the uv that reads it
+	toolDir, err := os.MkdirTemp("", "hubble-bundle-uv-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(toolDir)
+
+	uvURL, _ := uvReleaseURL(runtime.GOOS, runtime.GOARCH)
+	if err := r.Run(uvUnpackCommand(runtime.GOOS, filepath.Join(staging, filepath.FromSlash(downloads[uvURL])), toolDir)); err != nil {
+		return fmt.Errorf("failed to unpack uv: %w", err)
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Downloading pyhubbledemo %s and a Python runtime for it...", version))
+	cmd := Command{
+		Path: filepath.Join(toolDir, UVExecutable(runtime.GOOS)),
+		Args: []string{"tool", "run", "--from", toolRequirement(version), "hubbledemo", "--help"},
+		Env: []string{
+			"UV_CACHE_DIR=" + filepath.Join(staging, bundle.CacheDir),
+			"UV_PYTHON_INSTALL_DIR=" + filepath.Join(staging, bundle.PythonDir),
+			"UV_PYTHON_PREFERENCE=only-managed",
+		},
+	}
+	if err := r.Run(cmd); err != nil {
+		return &NetworkError{Op: "download pyhubbledemo " + version, Err: err}
+	}
+
+	// The tool environments point at the staging directory; uv rebuilds them from the cache
+	for _, pattern := range []string{"environments-v*", "builds-v*"} {
+		matches, _ := r.Glob(filepath.Join(staging, bundle.CacheDir, pattern))
+		for _, match := range matches {
+			if err := r.RemoveAll(match); err != nil {
+				return fmt.Errorf("failed to clean the uv cache: %w", err)
+			}
+		}
+	}
+
+	manifest := bundle.Manifest{
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+		ToolVersion: version,
+		Created:     time.Now().UTC(),
+		Downloads:   downloads,
+	}
+	return bundle.Write(staging, manifest, dest)
+}
+
+// NewBundleInstaller returns the installer for the named operating system that
+// installs dependencies and runs pyhubbledemo from the offline bundle b, without
+// reaching the network
+func NewBundleInstaller(goos string, runner Runner, b *bundle.Bundle) (Installer, error) {
+	if platform := goos + "/" + runtime.GOARCH; b.Manifest.Platform != platform {
+		return nil, fmt.Errorf("the offline bundle was created for %s and cannot be used on %s", b.Manifest.Platform, platform)
+	}
+	useBundle(runner, b)
+
+	switch goos {
+	case "darwin":
+		installer := NewDarwinInstaller(runner)
+		installer.bundle = b
+		return installer, nil
+	case "linux":
+		installer := NewLinuxInstaller(runner)
+		installer.bundle = b
+		return installer, nil
+	case "windows":
+		installer := NewWindowsInstaller(runner)
+		installer.bundle = b
+		return installer, nil
+	default:
+		return nil, fmt.Errorf("unsupported platform: %s", goos)
+	}
+}
+
+// useBundle points uv at the Python runtime and package cache in the offline
+// bundle, and keeps it from reaching the network
+func useBundle(r Runner, b *bundle.Bundle) {
+	r.Setenv("UV_OFFLINE", "1")
+	r.Setenv("UV_CACHE_DIR", filepath.Join(b.Dir, bundle.CacheDir))
+	r.Setenv("UV_PYTHON_INSTALL_DIR", filepath.Join(b.Dir, bundle.PythonDir))
+	r.Setenv("UV_PYTHON_PREFERENCE", "only-managed")
+	r.Setenv("UV_PYTHON_DOWNLOADS", "never")
+}
+
+// fetchInstaller fetches url into destPath and checks it against its pinned checksum.
+// With an offline bundle the file is copied from the bundle instead, and a URL
+// missing from it is an error.
+func fetchInstaller(r Runner, b *bundle.Bundle, url, destPath string) error {
+	if b == nil {
+		return r.Download(url, destPath)
+	}
+	src, err := b.Download(url)
+	if err != nil {
+		return err
+	}
+	// The bundle's own checksums prove it is intact; the pins prove it holds what this installer expects
+	if err := pinnedDownloads.Verify(url, src); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s from the offline bundle: %w", path.Base(url), err)
+	}
+	ui.PrintInfo(fmt.Sprintf("Using %s from the offline bundle", path.Base(url)))
+	return r.WriteFile(destPath, data)
+}
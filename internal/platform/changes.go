@@ -0,0 +1,376 @@
+package platform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// Kinds of change the installer makes to the system
+const (
+	ChangePackage        = "package"         // A package installed with a package manager
+	ChangeReceipt        = "receipt"         // A macOS installer package, by its receipt ID
+	ChangeFiles          = "files"           // A file or directory the installer created
+	ChangeUninstaller    = "uninstaller"     // A program installed by a vendor installer that ships an uninstaller
+	ChangeGroup          = "group"           // A group the user was added to
+	ChangePathEntry      = "path_entry"      // A directory added to the persistent PATH
+	ChangePackageManager = "package_manager" // Homebrew or Chocolatey
+)
+
+// Change is something the installer added to the system. Only additions are
+// recorded, never things that were there before, so undoing a change can only
+// remove what the installer itself put there.
+type Change struct {
+	Kind    string    `json:"kind"`
+	Name    string    `json:"name"`              // Dependency or component it belongs to, e.g. "uv"
+	Manager string    `json:"manager,omitempty"` // Package manager of a ChangePackage: brew, choco, dpkg, dnf or yum
+	Package string    `json:"package,omitempty"` // Package name, or receipt ID of a ChangeReceipt
+	Path    string    `json:"path,omitempty"`    // File, directory, uninstaller or PATH entry
+	Group   string    `json:"group,omitempty"`
+	User    string    `json:"user,omitempty"`
+	Root    bool      `json:"root,omitempty"` // Undoing it needs administrator rights
+	Time    time.Time `json:"time"`
+}
+
+// String describes the change for people
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangePackage:
+		return fmt.Sprintf("%s (%s package %s)", c.Name, c.Manager, c.Package)
+	case ChangeReceipt:
+		return fmt.Sprintf("%s (installer package %s)", c.Name, c.Package)
+	case ChangeFiles:
+		return fmt.Sprintf("%s (%s)", c.Name, c.Path)
+	case ChangeUninstaller:
+		return fmt.Sprintf("%s (removed with %s)", c.Name, c.Path)
+	case ChangeGroup:
+		return fmt.Sprintf("membership of %s in the %s group", c.User, c.Group)
+	case ChangePathEntry:
+		return fmt.Sprintf("%s on PATH", c.Path)
+	case ChangePackageManager:
+		return fmt.Sprintf("%s (package manager at %s)", c.Name, c.Path)
+	default:
+		return c.Name
+	}
+}
+
+// changeLog collects the changes an installer makes. It is safe for concurrent
+// use, as DarwinInstaller installs packages in parallel.
+type changeLog struct {
+	mu      sync.Mutex
+	changes []Change
+}
+
+// record adds a change, stamped with the current time
+func (l *changeLog) record(c Change) {
+	if c.Time.IsZero() {
+		c.Time = time.Now().UTC()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, c)
+}
+
+// take returns the changes recorded so far and forgets them
+func (l *changeLog) take() []Change {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changes := l.changes
+	l.changes = nil
+	return changes
+}
+
+// recordNewFiles runs install and records each of paths that it created as a
+// ChangeFiles for name. Paths that existed before are left out, so that undoing
+// the install never removes them.
+func (l *changeLog) recordNewFiles(r Runner, name string, paths []string, install func() error) error {
+	var fresh []string
+	for _, path := range paths {
+		if _, err := r.Stat(path); err != nil {
+			fresh = append(fresh, path)
+		}
+	}
+	err := install()
+	for _, path := range fresh {
+		if _, statErr := r.Stat(path); statErr == nil {
+			l.record(Change{Kind: ChangeFiles, Name: name, Path: path})
+		}
+	}
+	return err
+}
+
+// Undo reverts a change recorded by an installer on goos
+func Undo(r Runner, goos string, c Change) error {
+	switch c.Kind {
+	case ChangePackage:
+		return r.Run(uninstallPackageCommand(c))
+	case ChangeReceipt:
+		return undoReceipt(r, c)
+	case ChangeFiles:
+		if c.Root {
+			return r.Run(Command{Path: "sudo", Args: []string{"rm", "-rf", c.Path}, Show: true})
+		}
+		return r.RemoveAll(c.Path)
+	case ChangeUninstaller:
+		if _, err := r.Stat(c.Path); err != nil {
+			return fmt.Errorf("uninstaller %s is gone: %w", c.Path, err)
+		}
+		return r.Run(Command{Path: c.Path, Args: []string{"/S"}, Show: true})
+	case ChangeGroup:
+		return r.Run(Command{Path: "sudo", Args: []string{"gpasswd", "-d", c.User, c.Group}, Show: true})
+	case ChangePathEntry:
+		return r.Run(removePathEntryCommand(c.Path))
+	case ChangePackageManager:
+		return undoPackageManager(r, goos, c)
+	default:
+		return fmt.Errorf("unknown change %q; this installer is older than the one that made it", c.Kind)
+	}
+}
+
+// uninstallPackageCommand builds the command that removes a package
+func uninstallPackageCommand(c Change) Command {
+	switch c.Manager {
+	case "brew":
+		return Command{Path: "brew", Args: []string{"uninstall", c.Package}, Show: true}
+	case "choco":
+		return Command{Path: "choco", Args: []string{"uninstall", c.Package, "-y"}, Show: true}
+	case "dpkg":
+		return Command{Path: "sudo", Args: []string{"dpkg", "-r", c.Package}, Show: true}
+	default:
+		return Command{Path: "sudo", Args: []string{c.Manager, "remove", "-y", c.Package}, Show: true}
+	}
+}
+
+// undoReceipt removes the files of a macOS installer package and forgets its receipt
+func undoReceipt(r Runner, c Change) error {
+	info, err := r.Output(Command{Path: "pkgutil", Args: []string{"--pkg-info", c.Package}, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("installer package %s is not installed: %w", c.Package, err)
+	}
+	location := "/"
+	for _, line := range strings.Split(string(info), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "location:"); ok {
+			location = filepath.Join("/", strings.TrimSpace(value))
+		}
+	}
+
+	out, err := r.Output(Command{Path: "pkgutil", Args: []string{"--only-files", "--files", c.Package}, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to list the files of %s: %w", c.Package, err)
+	}
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, filepath.Join(location, line))
+		}
+	}
+	// Remove in batches to keep command lines short
+	for start := 0; start < len(files); start += 200 {
+		batch := files[start:min(start+200, len(files))]
+		if err := r.Run(Command{Path: "sudo", Args: append([]string{"rm", "-f", "--"}, batch...)}); err != nil {
+			return fmt.Errorf("failed to remove the files of %s: %w", c.Package, err)
+		}
+	}
+	return r.Run(Command{Path: "sudo", Args: []string{"pkgutil", "--forget", c.Package}})
+}
+
+// removePathEntryCommand builds the command that takes dir off the machine PATH on Windows
+func removePathEntryCommand(dir string) Command {
+	script := fmt.Sprintf(`$path = [Environment]::GetEnvironmentVariable('Path', 'Machine'); `+
+		`$kept = ($path -split ';') | Where-Object { $_ -and $_.TrimEnd('\') -ne '%s'.TrimEnd('\') }; `+
+		`[Environment]::SetEnvironmentVariable('Path', ($kept -join ';'), 'Machine')`,
+		strings.ReplaceAll(dir, "'", "''"))
+	return Command{Path: "powershell", Args: []string{"-NoProfile", "-Command", script}}
+}
+
+// undoPackageManager removes Homebrew or Chocolatey. Only uninstall does this,
+// once OtherPackages finds nothing else installed with it and the user agrees.
+func undoPackageManager(r Runner, goos string, c Change) error {
+	switch goos {
+	case "darwin":
+		paths, err := homebrewPaths(c.Path)
+		if err != nil {
+			return err
+		}
+		if err := r.Run(Command{Path: "sudo", Args: append([]string{"rm", "-rf", "--"}, paths...), Show: true}); err != nil {
+			return err
+		}
+		if c.Package == "" {
+			return nil
+		}
+		return r.Run(Command{Path: "sudo", Args: []string{"pkgutil", "--forget", c.Package}})
+	case "windows":
+		if c.Path == "" {
+			return fmt.Errorf("the Chocolatey install location was not recorded")
+		}
+		if err := r.RemoveAll(c.Path); err != nil {
+			return err
+		}
+		script := `[Environment]::SetEnvironmentVariable('ChocolateyInstall', $null, 'Machine'); ` +
+			`[Environment]::SetEnvironmentVariable('ChocolateyLastPathUpdate', $null, 'User')`
+		if err := r.Run(Command{Path: "powershell", Args: []string{"-NoProfile", "-Command", script}}); err != nil {
+			return err
+		}
+		// The Chocolatey installer also puts its bin directory on the machine PATH
+		return r.Run(removePathEntryCommand(filepath.Join(c.Path, "bin")))
+	default:
+		return fmt.Errorf("%s is not removed on %s", c.Name, goos)
+	}
+}
+
+// homebrewPaths returns what a Homebrew installation whose repository is at
+// repository consists of. Locations other than the default ones are refused, so
+// that a wrong record can never remove a shared directory like /usr/local.
+func homebrewPaths(repository string) ([]string, error) {
+	switch {
+	case repository == "/opt/homebrew":
+		// On Apple Silicon the prefix holds nothing but Homebrew
+		return []string{repository}, nil
+	case repository == "/usr/local/Homebrew":
+		prefix := filepath.Dir(repository)
+		return []string{
+			repository,
+			filepath.Join(prefix, "bin", "brew"),
+			filepath.Join(prefix, "Cellar"),
+			filepath.Join(prefix, "Caskroom"),
+			filepath.Join(prefix, "var", "homebrew"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("the Homebrew installation at %q is not in a default location; remove it yourself", repository)
+	}
+}
+
+// OtherPackages lists the packages installed with the package manager of a
+// ChangePackageManager, other than itself. A package manager is only offered for
+// removal when this finds none. An error means the packages could not be listed.
+func OtherPackages(r Runner, goos string, c Change) ([]string, error) {
+	var packages []string
+	switch goos {
+	case "darwin":
+		for _, kind := range []string{"--formula", "--cask"} {
+			out, err := r.Output(Command{Path: "brew", Args: []string{"list", kind, "-1"}, ReadOnly: true})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list Homebrew packages: %w", err)
+			}
+			packages = append(packages, strings.Fields(string(out))...)
+		}
+	case "windows":
+		out, err := r.Output(Command{Path: "choco", Args: []string{"list", "--limit-output"}, ReadOnly: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Chocolatey packages: %w", err)
+		}
+		// One name|version line per package, Chocolatey itself included
+		for _, line := range strings.Fields(string(out)) {
+			if name, _, _ := strings.Cut(line, "|"); !strings.EqualFold(name, "chocolatey") {
+				packages = append(packages, name)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s is not removed on %s", c.Name, goos)
+	}
+	return packages, nil
+}
+
+// Rollback undoes changes in the reverse of the order they were made, and
+// returns those that it did not undo. Package managers are never removed, even
+// when they were installed in the same run: other software may come to depend on
+// them, so only uninstall offers to remove them. They are returned unchanged.
+func Rollback(r Runner, goos string, changes []Change) []Change {
+	var kept []Change
+	for _, c := range slices.Backward(changes) {
+		if c.Kind == ChangePackageManager {
+			kept = append(kept, c)
+			continue
+		}
+		if err := Undo(r, goos, c); err != nil {
+			ui.PrintWarning(fmt.Sprintf("Could not remove %s: %v", c, err))
+			kept = append(kept, c)
+			continue
+		}
+		ui.PrintSuccess(fmt.Sprintf("Removed %s", c))
+	}
+	slices.Reverse(kept)
+	return kept
+}
+
+// InstallManifestVersion is the install manifest format this installer reads and writes
+const InstallManifestVersion = 1
+
+// InstallManifest lists what every run of the installer added to this system,
+// so that uninstall removes exactly that
+type InstallManifest struct {
+	Version int      `json:"version"`
+	Changes []Change `json:"changes"`
+
+	path string
+}
+
+// DefaultInstallManifestPath returns the location of the install manifest in the user config directory
+func DefaultInstallManifestPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+	return filepath.Join(configDir, "hubble", "installed.json"), nil
+}
+
+// LoadInstallManifest reads the install manifest at path. A missing file yields
+// an empty manifest.
+func LoadInstallManifest(path string) (*InstallManifest, error) {
+	m := &InstallManifest{Version: InstallManifestVersion, Changes: []Change{}, path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read install manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("install manifest %s is corrupt: %w", path, err)
+	}
+	if m.Version != InstallManifestVersion {
+		return nil, fmt.Errorf("install manifest %s has format %d, which this installer does not read (expected %d)", path, m.Version, InstallManifestVersion)
+	}
+	return m, nil
+}
+
+// Save writes the manifest, or removes it once it lists no changes
+func (m *InstallManifest) Save() error {
+	if len(m.Changes) == 0 {
+		if err := os.Remove(m.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove install manifest: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode install manifest: %w", err)
+	}
+
+	// Write to a temporary file first so a failed write never loses the record
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write install manifest: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write install manifest: %w", err)
+	}
+	return nil
+}
+
+// Path returns the file the manifest is read from and saved to
+func (m *InstallManifest) Path() string {
+	return m.path
+}
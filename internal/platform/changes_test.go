@@ -0,0 +1,133 @@
+package platform_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+)
+
+func TestDarwinRollbackRemovesOnlyWhatTheInstallerAdded(t *testing.T) {
+	// brew and uv were there before; segger-jlink is installed by this run
+	runner := platformtest.NewRunner("brew", "uv")
+	installer := platform.NewDarwinInstaller(runner)
+	if err := installer.InstallDependencies([]string{"uv", "segger-jlink"}); err != nil {
+		t.Fatalf("InstallDependencies() = %v", err)
+	}
+
+	changes := installer.TakeChanges()
+	if len(changes) != 1 || changes[0].Package != "segger-jlink" {
+		t.Fatalf("recorded %v, want only the segger-jlink package", changes)
+	}
+
+	if kept := platform.Rollback(runner, "darwin", changes); len(kept) != 0 {
+		t.Errorf("Rollback() kept %v", kept)
+	}
+	if !runner.Ran("brew uninstall segger-jlink") {
+		t.Error("segger-jlink was not removed")
+	}
+	if runner.Ran("brew uninstall uv") {
+		t.Error("uv, installed before the run, was removed")
+	}
+}
+
+func TestWindowsRollbackKeepsChocolatey(t *testing.T) {
+	// Chocolatey is installed by InstallDependencies itself on Windows, so its
+	// record reaches the rollback of a failed dependency installation
+	runner := platformtest.NewRunner()
+	installer := platform.NewWindowsInstaller(runner)
+	if err := installer.InstallDependencies([]string{"uv", "uniflash"}); err == nil {
+		t.Fatal("InstallDependencies() succeeded, want UniFlash to be missing")
+	}
+
+	changes := installer.TakeChanges()
+	kinds := make([]string, len(changes))
+	for i, c := range changes {
+		kinds[i] = c.Kind
+	}
+	if !slices.Contains(kinds, platform.ChangePackageManager) {
+		t.Fatalf("recorded %v, want Chocolatey among them", changes)
+	}
+
+	removedBefore, callsBefore := len(runner.Removed()), len(runner.Calls())
+	kept := platform.Rollback(runner, "windows", changes)
+	if len(kept) != 1 || kept[0].Kind != platform.ChangePackageManager {
+		t.Errorf("Rollback() kept %v, want only Chocolatey", kept)
+	}
+	if !runner.Ran("choco uninstall uv") {
+		t.Error("uv, installed by the failed run, was not removed")
+	}
+	if removed := runner.Removed()[removedBefore:]; len(removed) > 0 {
+		t.Errorf("Rollback() deleted %v", removed)
+	}
+	for _, line := range runner.Calls()[callsBefore:] {
+		if strings.Contains(line, "ChocolateyInstall") {
+			t.Errorf("Rollback() ran %q", line)
+		}
+	}
+}
+
+func TestOtherPackages(t *testing.T) {
+	chocolatey := platform.Change{Kind: platform.ChangePackageManager, Name: "Chocolatey", Path: `C:\ProgramData\chocolatey`}
+	homebrew := platform.Change{Kind: platform.ChangePackageManager, Name: "Homebrew", Path: "/opt/homebrew"}
+
+	tests := []struct {
+		name   string
+		goos   string
+		change platform.Change
+		script []platformtest.Response
+		want   []string
+	}{
+		{
+			name:   "Chocolatey alone",
+			goos:   "windows",
+			change: chocolatey,
+			script: []platformtest.Response{{Match: "choco list", Output: "chocolatey|2.4.1\n"}},
+		},
+		{
+			name:   "Chocolatey with a package",
+			goos:   "windows",
+			change: chocolatey,
+			script: []platformtest.Response{{Match: "choco list", Output: "chocolatey|2.4.1\ngit|2.47.0\n"}},
+			want:   []string{"git"},
+		},
+		{
+			name:   "Homebrew with a formula and a cask",
+			goos:   "darwin",
+			change: homebrew,
+			script: []platformtest.Response{
+				{Match: "brew list --formula", Output: "wget\n"},
+				{Match: "brew list --cask", Output: "firefox\n"},
+			},
+			want: []string{"wget", "firefox"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := platformtest.NewRunner()
+			for _, resp := range tt.script {
+				runner.On(resp)
+			}
+			got, err := platform.OtherPackages(runner, tt.goos, tt.change)
+			if err != nil {
+				t.Fatalf("OtherPackages() = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("OtherPackages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUndoHomebrewRefusesUnexpectedLocation(t *testing.T) {
+	runner := platformtest.NewRunner()
+	c := platform.Change{Kind: platform.ChangePackageManager, Name: "Homebrew", Path: "/usr/local"}
+	if err := platform.Undo(runner, "darwin", c); err == nil {
+		t.Fatal("Undo() removed Homebrew from /usr/local")
+	}
+	if len(runner.Calls()) > 0 {
+		t.Errorf("Undo() ran %v", runner.Calls())
+	}
+}
@@ -0,0 +1,168 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HubbleNetwork/hubble-install/internal/bundle"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// Silicon Labs publishes Simplicity Commander, its command-line programmer, as one
+// zip per platform that wraps the actual package (a tarball, dmg or inner zip)
+const commanderDownloadURL = "https://www.silabs.com/documents/public/software/SimplicityCommander-%s.zip"
+
+// commanderInstallDir returns where the installer unpacks Simplicity Commander
+func commanderInstallDir(r Runner, goos string) string {
+	switch goos {
+	case "windows":
+		return filepath.Join(r.Getenv("LOCALAPPDATA"), "Programs", "SimplicityCommander")
+	case "darwin":
+		return "/Applications"
+	default:
+		return filepath.Join(r.Getenv("HOME"), ".local", "share", "hubble", "commander")
+	}
+}
+
+// commanderInstallPath returns what installCommander adds: the application
+// bundle on macOS, or the directory it unpacks into elsewhere
+func commanderInstallPath(r Runner, goos string) string {
+	if goos == "darwin" {
+		return filepath.Join(commanderInstallDir(r, goos), "Commander.app")
+	}
+	return commanderInstallDir(r, goos)
+}
+
+// commanderLocations lists where Simplicity Commander is found when it is not on
+// PATH: where this installer puts it first, then inside Simplicity Studio
+func commanderLocations(r Runner, goos string) []string {
+	switch goos {
+	case "windows":
+		return []string{
+			filepath.Join(commanderInstallDir(r, goos), "Simplicity Commander", "commander.exe"),
+			`C:\SiliconLabs\SimplicityStudio\v5\developer\adapter_packs\commander\commander.exe`,
+		}
+	case "darwin":
+		return []string{
+			"/Applications/Commander.app/Contents/MacOS/commander",
+			"/Applications/Simplicity Studio.app/Contents/Eclipse/developer/adapter_packs/commander/Commander.app/Contents/MacOS/commander",
+		}
+	default:
+		return []string{
+			filepath.Join(commanderInstallDir(r, goos), "commander", "commander"),
+			filepath.Join(r.Getenv("HOME"), "SimplicityStudio_v5", "developer", "adapter_packs", "commander", "commander"),
+		}
+	}
+}
+
+// findCommander locates the Simplicity Commander executable
+func findCommander(r Runner, goos string) (string, error) {
+	if path, err := r.LookPath("commander"); err == nil {
+		return path, nil
+	}
+	for _, path := range commanderLocations(r, goos) {
+		if _, err := r.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("commander not found on PATH or in the default install locations")
+}
+
+// commanderURL returns the URL of the Simplicity Commander download for goos
+func commanderURL(goos string) string {
+	platformName := map[string]string{"darwin": "Mac", "linux": "Linux", "windows": "Windows"}[goos]
+	return fmt.Sprintf(commanderDownloadURL, platformName)
+}
+
+// installCommander downloads Simplicity Commander from Silicon Labs, or takes it
+// from the offline bundle b, and unpacks it for the current user (or into
+// /Applications on macOS). What it unpacks is recorded in changes.
+func installCommander(r Runner, goos string, b *bundle.Bundle, changes *changeLog) error {
+	url := commanderURL(goos)
+
+	tempDir := filepath.Join(os.TempDir(), "hubble-commander-install")
+	if err := r.MkdirAll(tempDir); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer r.RemoveAll(tempDir) // Clean up after installation
+
+	zipPath := filepath.Join(tempDir, "SimplicityCommander.zip")
+	if err := fetchInstaller(r, b, url, zipPath); err != nil {
+		ui.PrintInfo("You can download Simplicity Commander manually from: https://www.silabs.com/developer-tools/simplicity-studio/simplicity-commander")
+		return fmt.Errorf("download failed: %w", err)
+	}
+
+	cmd := commanderUnpackCommand(goos, zipPath, commanderInstallDir(r, goos))
+	cmd.Creates = commanderLocations(r, goos)[:1]
+	if err := changes.recordNewFiles(r, "simplicity-commander", []string{commanderInstallPath(r, goos)}, func() error { return r.Run(cmd) }); err != nil {
+		return fmt.Errorf("failed to unpack Simplicity Commander: %w", err)
+	}
+
+	if _, err := findCommander(r, goos); err != nil {
+		return fmt.Errorf("unpacked Simplicity Commander, but %w", err)
+	}
+	return nil
+}
+
+// commanderUnpackCommand builds the command that unpacks the downloaded zip into installDir
+func commanderUnpackCommand(goos, zipPath, installDir string) Command {
+	switch goos {
+	case "windows":
+		quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+		script := fmt.Sprintf(`$ErrorActionPreference = 'Stop'; `+
+			`$tmp = Split-Path -Parent %[1]s; `+
+			`Expand-Archive -Force -Path %[1]s -DestinationPath $tmp; `+
+			`$inner = Get-ChildItem -Path $tmp -Filter 'Commander_win32_*.zip' | Select-Object -First 1; `+
+			`Expand-Archive -Force -Path $inner.FullName -DestinationPath %[2]s`,
+			quote(zipPath), quote(installDir))
+		return Command{Path: "powershell", Args: []string{"-NoProfile", "-Command", script}, Show: true}
+	case "darwin":
+		script := `set -e; cd "$(dirname "$1")"; unzip -oq "$1"; ` +
+			`hdiutil attach -nobrowse -quiet -mountpoint mnt Commander_osx_*.dmg; ` +
+			`cp -R mnt/Commander.app "$2"/; hdiutil detach -quiet mnt`
+		return Command{Path: "sh", Args: []string{"-c", script, "sh", zipPath, installDir}, Show: true}
+	default:
+		script := `set -e; cd "$(dirname "$1")"; unzip -oq "$1"; mkdir -p "$2"; ` +
+			`tar -xjf Commander_linux_"$(uname -m)"_*.tar.bz -C "$2"`
+		return Command{Path: "sh", Args: []string{"-c", script, "sh", zipPath, installDir}, Show: true}
+	}
+}
+
+// flashWithCommander generates the board's hex file with pyhubbledemo, then
+// programs it with Simplicity Commander over the kit's on-board debugger
+func flashWithCommander(r Runner, goos, uvPath string, req FlashRequest) (*FlashResult, error) {
+	commanderPath, err := findCommander(r, goos)
+	if err != nil {
+		return nil, &DependencyMissingError{Dependencies: []string{"simplicity-commander"}, Err: err}
+	}
+
+	if req.Target == "" {
+		return nil, &FlashFailedError{Board: req.Board, Err: fmt.Errorf("the board catalog gives no target device for Simplicity Commander")}
+	}
+
+	tempDir := filepath.Join(os.TempDir(), "hubble-commander-flash")
+	if err := r.MkdirAll(tempDir); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer r.RemoveAll(tempDir) // The hex file holds the device key; do not leave it behind
+
+	hexFilePath := filepath.Join(tempDir, req.Board+".hex")
+	if err := r.Run(hubbledemoCommand(uvPath, req, hexFilePath)); err != nil {
+		return nil, &FlashFailedError{Board: req.Board, Err: err}
+	}
+
+	// Without --device, Commander programs whatever part it detects, and fails on
+	// kits whose debugger does not report one
+	args := []string{"flash", hexFilePath, "--device", req.Target}
+	if req.ProbeSerial != "" {
+		args = append(args, "--serialno", req.ProbeSerial)
+	}
+	if err := r.Run(Command{Path: commanderPath, Args: args, Show: true}); err != nil {
+		return nil, &FlashFailedError{Board: req.Board, Err: fmt.Errorf("commander flash failed: %w", err)}
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Board %s flashed successfully!", req.Board))
+	return newFlashResult(req, ""), nil
+}
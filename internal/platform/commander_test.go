@@ -0,0 +1,44 @@
+package platform_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+)
+
+// macCommander is where Simplicity Commander is installed on macOS
+const macCommander = "/Applications/Commander.app/Contents/MacOS/commander"
+
+func TestFlashWithCommanderNamesTheDevice(t *testing.T) {
+	runner := platformtest.NewRunner("uv").AddFile(macCommander)
+	installer := platform.NewDarwinInstaller(runner)
+	req := platform.FlashRequest{Board: "xg24_ek2703a", FlashMethod: boards.FlashMethodCommander, Target: "EFR32MG24B210F1536IM48", ProbeSerial: "440123456"}
+	if _, err := installer.FlashBoard(req); err != nil {
+		t.Fatalf("FlashBoard() = %v", err)
+	}
+
+	var flash string
+	for _, line := range runner.Calls() {
+		if strings.HasPrefix(line, macCommander+" flash ") {
+			flash = line
+		}
+	}
+	if !strings.Contains(flash, "--device EFR32MG24B210F1536IM48") || !strings.Contains(flash, "--serialno 440123456") {
+		t.Errorf("commander ran as %q, want the target device and probe serial", flash)
+	}
+}
+
+func TestFlashWithCommanderNeedsTarget(t *testing.T) {
+	runner := platformtest.NewRunner("uv").AddFile(macCommander)
+	installer := platform.NewDarwinInstaller(runner)
+	req := platform.FlashRequest{Board: "xg24_ek2703a", FlashMethod: boards.FlashMethodCommander}
+	if _, err := installer.FlashBoard(req); err == nil {
+		t.Fatal("FlashBoard() flashed without a target device")
+	}
+	if runner.Ran(macCommander) {
+		t.Errorf("commander ran without a target device: %v", runner.Calls())
+	}
+}
@@ -3,20 +3,36 @@ package platform
 import (
 	"fmt"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
 
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/bundle"
 	"github.com/HubbleNetwork/hubble-install/internal/ui"
 )
 
+// homebrewVersion is the Homebrew release the installer sets up
+const homebrewVersion = "4.4.15"
+
+// homebrewPackageURL is the installer package of a Homebrew release. Unlike the
+// install script on its default branch, a release asset never changes, so its
+// checksum can be pinned.
+const homebrewPackageURL = "https://github.com/Homebrew/brew/releases/download/%s/Homebrew-%s.pkg"
+
 // DarwinInstaller implements the Installer interface for macOS
-type DarwinInstaller struct{}
+type DarwinInstaller struct {
+	runner  Runner
+	bundle  *bundle.Bundle // Offline bundle to install from instead of downloading, if any
+	changes changeLog      // What this installer added to the system
+}
 
-// NewDarwinInstaller creates a new macOS installer
-func NewDarwinInstaller() *DarwinInstaller {
-	return &DarwinInstaller{}
+// NewDarwinInstaller creates a new macOS installer that runs commands through runner
+func NewDarwinInstaller(runner Runner) *DarwinInstaller {
+	return &DarwinInstaller{runner: runner}
 }
 
 // Name returns the platform name
@@ -24,6 +40,11 @@ func (d *DarwinInstaller) Name() string {
 	return "macOS"
 }
 
+// TakeChanges returns what the installer added to the system since the last call
+func (d *DarwinInstaller) TakeChanges() []Change {
+	return d.changes.take()
+}
+
 // CheckPendingReboot checks if a system reboot is pending (not typically needed on macOS)
 func (d *DarwinInstaller) CheckPendingReboot() error {
 	// macOS doesn't typically require reboot checks for package installations
@@ -33,20 +54,14 @@ func (d *DarwinInstaller) CheckPendingReboot() error {
 // ensureSudoAccess validates sudo access upfront to avoid multiple password prompts
 func (d *DarwinInstaller) ensureSudoAccess() error {
 	// Check if we already have valid sudo credentials
-	checkCmd := exec.Command("sudo", "-n", "true")
-	if err := checkCmd.Run(); err == nil {
+	if err := d.runner.Run(Command{Path: "sudo", Args: []string{"-n", "true"}, ReadOnly: true}); err == nil {
 		// Already have valid sudo, no need to prompt
 		return nil
 	}
 
 	// Need to prompt for password
 	ui.PrintWarning("Administrator access required for installation")
-	cmd := exec.Command("sudo", "-v")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
+	if err := d.runner.Run(Command{Path: "sudo", Args: []string{"-v"}, Stdin: true, Show: true}); err != nil {
 		return fmt.Errorf("failed to obtain sudo access: %w", err)
 	}
 
@@ -57,8 +72,8 @@ func (d *DarwinInstaller) ensureSudoAccess() error {
 func (d *DarwinInstaller) CheckPrerequisites(requiredDeps []string) ([]MissingDependency, error) {
 	var missing []MissingDependency
 
-	// Check for Homebrew (always required for installing other deps)
-	if !d.commandExists("brew") {
+	// Check for Homebrew (required for installing other deps, except from an offline bundle)
+	if !d.commandExists("brew") && d.bundle == nil {
 		missing = append(missing, MissingDependency{
 			Name:   "Homebrew",
 			Status: "Not installed",
@@ -82,6 +97,20 @@ func (d *DarwinInstaller) CheckPrerequisites(requiredDeps []string) ([]MissingDe
 					Status: "Not installed",
 				})
 			}
+		case "simplicity-commander":
+			if _, err := findCommander(d.runner, "darwin"); err != nil {
+				missing = append(missing, MissingDependency{
+					Name:   "simplicity-commander",
+					Status: "Not installed",
+				})
+			}
+		case "uniflash":
+			if _, err := findDSLite(d.runner, "darwin"); err != nil {
+				missing = append(missing, MissingDependency{
+					Name:   "uniflash",
+					Status: "Not installed (manual download required)",
+				})
+			}
 		}
 	}
 
@@ -90,13 +119,21 @@ func (d *DarwinInstaller) CheckPrerequisites(requiredDeps []string) ([]MissingDe
 
 // InstallPackageManager installs Homebrew if not present
 func (d *DarwinInstaller) InstallPackageManager() error {
+	if d.bundle != nil {
+		ui.PrintInfo("Homebrew is not needed to install from the offline bundle")
+		return nil
+	}
 	if d.commandExists("brew") {
 		ui.PrintSuccess("Homebrew already installed")
 		return nil
 	}
 
+	// The package does not install the Command Line Tools, which Homebrew needs
+	if err := d.runner.Run(Command{Path: "xcode-select", Args: []string{"-p"}, ReadOnly: true}); err != nil {
+		return fmt.Errorf("homebrew needs the Xcode Command Line Tools: run 'xcode-select --install', then run the installer again")
+	}
+
 	// Ensure we have sudo access upfront (single password prompt)
-	// The Homebrew script will use sudo internally when needed (e.g., for Xcode Command Line Tools)
 	if err := d.ensureSudoAccess(); err != nil {
 		return err
 	}
@@ -104,17 +141,38 @@ func (d *DarwinInstaller) InstallPackageManager() error {
 	ui.PrintInfo("Installing Homebrew...")
 	ui.PrintInfo("This may take a few minutes...")
 
-	// Run the official Homebrew installation script as regular user (not sudo)
-	// The script will internally use sudo when needed, using our cached credentials
-	// NONINTERACTIVE=1 suppresses the "running in noninteractive mode" warning
-	cmd := exec.Command("/bin/bash", "-c", `NONINTERACTIVE=1 /bin/bash -c "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)"`)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// Download the Homebrew release package, which is only installed once it
+	// matches its pinned checksum
+	tempDir := filepath.Join(os.TempDir(), "hubble-homebrew-install")
+	if err := d.runner.MkdirAll(tempDir); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer d.runner.RemoveAll(tempDir) // Clean up after installation
+
+	url := fmt.Sprintf(homebrewPackageURL, homebrewVersion, homebrewVersion)
+	pkgPath := filepath.Join(tempDir, path.Base(url))
+	if err := d.runner.Download(url, pkgPath); err != nil {
+		return fmt.Errorf("failed to download the Homebrew installer: %w", err)
+	}
 
-	if err := cmd.Run(); err != nil {
+	// The receipt that appears identifies the package, for uninstall
+	before := d.receipts()
+	cmd := Command{
+		Path:     "sudo",
+		Args:     []string{"installer", "-pkg", pkgPath, "-target", "/"},
+		Show:     true,
+		Installs: []string{"brew"},
+		Creates:  []string{homebrewBinary()},
+	}
+	if err := d.runner.Run(cmd); err != nil {
 		return fmt.Errorf("failed to install Homebrew: %w", err)
 	}
+	var receipt string
+	for _, id := range d.receipts() {
+		if !slices.Contains(before, id) {
+			receipt = id
+		}
+	}
 
 	// Add Homebrew to PATH for this process
 	if err := d.setupBrewPath(); err != nil {
@@ -127,11 +185,16 @@ func (d *DarwinInstaller) InstallPackageManager() error {
 	}
 
 	// Test brew with a simple command to ensure it's functional
-	testCmd := exec.Command("brew", "--version")
-	if err := testCmd.Run(); err != nil {
+	if err := d.runner.Run(Command{Path: "brew", Args: []string{"--version"}, ReadOnly: true}); err != nil {
 		return fmt.Errorf("homebrew installed but not functioning correctly: %w", err)
 	}
 
+	// Where Homebrew keeps itself, which is all uninstall has to remove
+	repository := homebrewRepository()
+	if out, err := d.runner.Output(Command{Path: "brew", Args: []string{"--repository"}, ReadOnly: true}); err == nil && strings.TrimSpace(string(out)) != "" {
+		repository = strings.TrimSpace(string(out))
+	}
+	d.changes.record(Change{Kind: ChangePackageManager, Name: "Homebrew", Path: repository, Package: receipt, Root: true})
 	ui.PrintSuccess("Homebrew installed successfully")
 	return nil
 }
@@ -139,12 +202,19 @@ func (d *DarwinInstaller) InstallPackageManager() error {
 // InstallDependencies installs the specified dependencies
 func (d *DarwinInstaller) InstallDependencies(deps []string) error {
 	// First ensure Homebrew is installed
-	if !d.commandExists("brew") {
+	if !d.commandExists("brew") && d.bundle == nil {
 		if err := d.InstallPackageManager(); err != nil {
 			return err
 		}
 	}
 
+	// The J-Link package installs system-wide; ask for the password before the parallel installs
+	if d.bundle != nil && slices.Contains(deps, "segger-jlink") && !d.commandExists("JLinkExe") {
+		if err := d.ensureSudoAccess(); err != nil {
+			return err
+		}
+	}
+
 	// Install dependencies in parallel for speed
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(deps))
@@ -160,6 +230,15 @@ func (d *DarwinInstaller) InstallDependencies(deps []string) error {
 					ui.PrintSuccess("uv already installed")
 					return
 				}
+				if d.bundle != nil {
+					ui.PrintInfo("Installing uv from the offline bundle...")
+					if err := installUVRelease(d.runner, "darwin", d.bundle, &d.changes); err != nil {
+						errChan <- fmt.Errorf("failed to install uv: %w", err)
+						return
+					}
+					ui.PrintSuccess("uv installed successfully")
+					return
+				}
 				ui.PrintInfo("Installing uv...")
 				if err := d.runBrewInstall("uv", false); err != nil {
 					errChan <- fmt.Errorf("failed to install uv: %w", err)
@@ -173,11 +252,38 @@ func (d *DarwinInstaller) InstallDependencies(deps []string) error {
 					return
 				}
 				ui.PrintInfo("Installing segger-jlink (this may take a few minutes)...")
+				if d.bundle != nil {
+					if err := d.installJLinkFromBundle(); err != nil {
+						errChan <- fmt.Errorf("failed to install segger-jlink: %w", err)
+						return
+					}
+					ui.PrintSuccess("segger-jlink installed successfully")
+					return
+				}
 				if err := d.runBrewInstall("segger-jlink", true); err != nil {
 					errChan <- fmt.Errorf("failed to install segger-jlink: %w", err)
 					return
 				}
 				ui.PrintSuccess("segger-jlink installed successfully")
+
+			case "simplicity-commander":
+				if _, err := findCommander(d.runner, "darwin"); err == nil {
+					ui.PrintSuccess("simplicity-commander already installed")
+					return
+				}
+				ui.PrintInfo("Installing Simplicity Commander from Silicon Labs...")
+				if err := installCommander(d.runner, "darwin", d.bundle, &d.changes); err != nil {
+					errChan <- fmt.Errorf("failed to install simplicity-commander: %w", err)
+					return
+				}
+				ui.PrintSuccess("simplicity-commander installed successfully")
+
+			case "uniflash":
+				if _, err := findDSLite(d.runner, "darwin"); err != nil {
+					errChan <- uniflashMissing()
+					return
+				}
+				ui.PrintSuccess("uniflash already installed")
 			}
 		}()
 	}
@@ -196,48 +302,78 @@ func (d *DarwinInstaller) InstallDependencies(deps []string) error {
 	return nil
 }
 
-// FlashBoard flashes the specified board using uvx (for J-Link boards)
-func (d *DarwinInstaller) FlashBoard(orgID, apiToken, board, deviceName string) (*FlashResult, error) {
-	ui.PrintInfo(fmt.Sprintf("Flashing board: %s", board))
-	ui.PrintInfo("This may take 10-15 seconds...")
+// installJLinkFromBundle installs SEGGER's J-Link package from the offline bundle
+func (d *DarwinInstaller) installJLinkFromBundle() error {
+	url := jlinkDownloadURL("darwin", runtime.GOARCH, "")
 
-	uvPath, err := exec.LookPath("uv")
-	if err != nil {
-		return nil, fmt.Errorf("uv not found in PATH: %w", err)
+	tempDir := filepath.Join(os.TempDir(), "hubble-jlink-install")
+	if err := d.runner.MkdirAll(tempDir); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
+	defer d.runner.RemoveAll(tempDir) // Clean up after installation
 
-	// Build the command with --refresh to prevent stale versions
-	args := []string{"tool", "run", "--refresh", "--from", "pyhubbledemo", "hubbledemo", "flash", board, "-o", orgID, "-t", apiToken}
-	if deviceName != "" {
-		args = append(args, "-n", deviceName)
+	pkgPath := filepath.Join(tempDir, path.Base(url))
+	if err := fetchInstaller(d.runner, d.bundle, url, pkgPath); err != nil {
+		return err
 	}
-	cmd := exec.Command(uvPath, args...)
 
-	cmd.Env = append(os.Environ(), "PYTHONWARNINGS=ignore")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// The receipts that appear identify what the package installed, for uninstall
+	before := d.receipts()
+	cmd := Command{Path: "sudo", Args: []string{"installer", "-pkg", pkgPath, "-target", "/"}, Show: true, Installs: []string{"JLinkExe"}}
+	if err := d.runner.Run(cmd); err != nil {
+		return fmt.Errorf("J-Link package installation failed: %w", err)
+	}
+	for _, id := range d.receipts() {
+		if !slices.Contains(before, id) {
+			d.changes.record(Change{Kind: ChangeReceipt, Name: "segger-jlink", Package: id, Root: true})
+		}
+	}
+	return nil
+}
 
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("flash command failed: %w", err)
+// receipts lists the IDs of the installer packages installed on this Mac
+func (d *DarwinInstaller) receipts() []string {
+	out, err := d.runner.Output(Command{Path: "pkgutil", Args: []string{"--pkgs"}, ReadOnly: true})
+	if err != nil {
+		return nil
 	}
+	return strings.Fields(string(out))
+}
 
-	resultDeviceName := deviceName
-	if resultDeviceName == "" {
-		resultDeviceName = "your-device"
+// FlashBoard flashes the specified board using uvx, through the vendor tool of its flash method
+func (d *DarwinInstaller) FlashBoard(req FlashRequest) (*FlashResult, error) {
+	ui.PrintInfo(fmt.Sprintf("Flashing board: %s", req.Board))
+	ui.PrintInfo("This may take 10-15 seconds...")
+
+	uvPath, err := d.runner.LookPath("uv")
+	if err != nil {
+		return nil, &DependencyMissingError{Dependencies: []string{"uv"}, Err: err}
 	}
 
-	ui.PrintSuccess(fmt.Sprintf("Board %s flashed successfully!", board))
-	return &FlashResult{DeviceName: resultDeviceName}, nil
+	switch req.FlashMethod {
+	case boards.FlashMethodCommander:
+		return flashWithCommander(d.runner, "darwin", uvPath, req)
+	case boards.FlashMethodUniflash:
+		return flashWithUniflash(d.runner, "darwin", uvPath, req)
+	}
+
+	// Run pyhubbledemo's flash command
+	if err := d.runner.Run(hubbledemoCommand(uvPath, req, "")); err != nil {
+		return nil, &FlashFailedError{Board: req.Board, Err: err}
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Board %s flashed successfully!", req.Board))
+	return newFlashResult(req, ""), nil
 }
 
 // GenerateHexFile generates a hex file for Uniflash boards (TI)
-func (d *DarwinInstaller) GenerateHexFile(orgID, apiToken, board, deviceName string) (*FlashResult, error) {
-	ui.PrintInfo(fmt.Sprintf("Generating hex file for board: %s", board))
+func (d *DarwinInstaller) GenerateHexFile(req FlashRequest) (*FlashResult, error) {
+	ui.PrintInfo(fmt.Sprintf("Generating hex file for board: %s", req.Board))
 	ui.PrintInfo("This may take a few seconds...")
 
-	uvPath, err := exec.LookPath("uv")
+	uvPath, err := d.runner.LookPath("uv")
 	if err != nil {
-		return nil, fmt.Errorf("uv not found in PATH: %w", err)
+		return nil, &DependencyMissingError{Dependencies: []string{"uv"}, Err: err}
 	}
 
 	// Determine hex file path in current working directory
@@ -247,81 +383,78 @@ func (d *DarwinInstaller) GenerateHexFile(orgID, apiToken, board, deviceName str
 	}
 
 	// Use device name for filename if provided, otherwise use board name
-	filename := board + ".hex"
-	if deviceName != "" {
-		filename = deviceName + ".hex"
+	filename := req.Board + ".hex"
+	if req.DeviceName != "" {
+		filename = req.DeviceName + ".hex"
 	}
 	hexFilePath := filepath.Join(currentDir, filename)
 
-	// Build the command with --refresh to prevent stale versions and -f for output file
-	args := []string{"tool", "run", "--refresh", "--from", "pyhubbledemo", "hubbledemo", "flash", board, "-o", orgID, "-t", apiToken, "-f", hexFilePath}
-	if deviceName != "" {
-		args = append(args, "-n", deviceName)
-	}
-	cmd := exec.Command(uvPath, args...)
-
-	cmd.Env = append(os.Environ(), "PYTHONWARNINGS=ignore")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("command failed: %w", err)
+	// Run pyhubbledemo with -f for output file
+	if err := d.runner.Run(hubbledemoCommand(uvPath, req, hexFilePath)); err != nil {
+		return nil, &FlashFailedError{Board: req.Board, Err: err}
 	}
 
 	// Verify the hex file was created at the expected location
-	if _, err := os.Stat(hexFilePath); err != nil {
+	if _, err := d.runner.Stat(hexFilePath); err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("hex file was not created at expected location: %s\n"+
+			return nil, &FlashFailedError{Board: req.Board, Err: fmt.Errorf("hex file was not created at expected location: %s\n"+
 				"The pyhubbledemo tool may not support the -f flag properly.\n"+
-				"Please check if a hex file was created in a temporary location", hexFilePath)
+				"Please check if a hex file was created in a temporary location", hexFilePath)}
 		}
 		return nil, fmt.Errorf("error checking hex file: %w", err)
 	}
 
-	return &FlashResult{HexFilePath: hexFilePath}, nil
+	return newFlashResult(req, hexFilePath), nil
 }
 
 // Helper functions
 
 // commandExists checks if a command is available in PATH
 func (d *DarwinInstaller) commandExists(cmd string) bool {
-	_, err := exec.LookPath(cmd)
+	_, err := d.runner.LookPath(cmd)
 	return err == nil
 }
 
+// homebrewBinary returns where the Homebrew installer puts brew on this architecture
+func homebrewBinary() string {
+	if runtime.GOARCH == "arm64" {
+		return "/opt/homebrew/bin/brew"
+	}
+	return "/usr/local/bin/brew"
+}
+
+// homebrewRepository returns where a default Homebrew installation keeps itself
+func homebrewRepository() string {
+	if runtime.GOARCH == "arm64" {
+		return "/opt/homebrew"
+	}
+	return "/usr/local/Homebrew"
+}
+
 // setupBrewPath adds Homebrew to PATH for the current process
 func (d *DarwinInstaller) setupBrewPath() error {
 	// Detect Homebrew installation path based on architecture
 	// Apple Silicon: /opt/homebrew
 	// Intel: /usr/local
 	var brewPath string
-	if _, err := os.Stat("/opt/homebrew/bin/brew"); err == nil {
+	if _, err := d.runner.Stat("/opt/homebrew/bin/brew"); err == nil {
 		brewPath = "/opt/homebrew/bin"
-	} else if _, err := os.Stat("/usr/local/bin/brew"); err == nil {
+	} else if _, err := d.runner.Stat("/usr/local/bin/brew"); err == nil {
 		brewPath = "/usr/local/bin"
 	} else {
 		return fmt.Errorf("brew not found in expected locations")
 	}
 
 	// Update PATH for this process
-	currentPath := os.Getenv("PATH")
-	if !strings.Contains(currentPath, brewPath) {
-		newPath := brewPath + ":" + currentPath
-		os.Setenv("PATH", newPath)
-	}
-
+	prependPath(d.runner, brewPath, ":")
 	return nil
 }
 
 // runBrewInstall runs a brew install command
 func (d *DarwinInstaller) runBrewInstall(pkg string, showOutput bool) error {
-	cmd := exec.Command("brew", "install", pkg)
-
-	// Show output if requested
-	if showOutput {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	if err := d.runner.Run(Command{Path: "brew", Args: []string{"install", pkg}, Show: showOutput, Installs: []string{executableFor(pkg)}}); err != nil {
+		return err
 	}
-
-	return cmd.Run()
+	d.changes.record(Change{Kind: ChangePackage, Name: pkg, Manager: "brew", Package: pkg})
+	return nil
 }
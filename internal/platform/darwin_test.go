@@ -0,0 +1,153 @@
+package platform_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+)
+
+func TestDarwinCheckPrerequisites(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed []string
+		files     []string
+		offline   bool
+		want      []string
+	}{
+		{
+			name: "nothing installed",
+			want: []string{"Homebrew", "segger-jlink", "simplicity-commander", "uniflash", "uv"},
+		},
+		{
+			name:      "everything installed",
+			installed: []string{"brew", "uv", "JLinkExe"},
+			files:     []string{macCommander, "/Applications/ti/uniflash_8.7.0/dslite.sh"},
+		},
+		{
+			name:      "Commander on PATH",
+			installed: []string{"brew", "uv", "JLinkExe", "commander", "dslite.sh"},
+		},
+		{
+			name:    "offline bundle without Homebrew",
+			offline: true,
+			want:    []string{"segger-jlink", "simplicity-commander", "uniflash", "uv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := platformtest.NewRunner(tt.installed...)
+			for _, path := range tt.files {
+				runner.AddFile(path)
+			}
+			var installer platform.Installer = platform.NewDarwinInstaller(runner)
+			if tt.offline {
+				var err error
+				if installer, err = platform.NewBundleInstaller("darwin", runner, emptyBundle(t, "darwin")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			missing, err := installer.CheckPrerequisites(allDeps)
+			if err != nil {
+				t.Fatalf("CheckPrerequisites() = %v", err)
+			}
+			if got := sortedNames(missing); !slices.Equal(got, tt.want) {
+				t.Errorf("CheckPrerequisites() reported %v missing, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDarwinInstallDependencies(t *testing.T) {
+	runner := platformtest.NewRunner()
+	installer := platform.NewDarwinInstaller(runner)
+	deps := []string{"uv", "segger-jlink", "simplicity-commander"}
+	if err := installer.InstallDependencies(deps); err != nil {
+		t.Fatalf("InstallDependencies() = %v", err)
+	}
+
+	for _, prefix := range []string{"sudo installer -pkg", "brew install uv", "brew install segger-jlink"} {
+		if !runner.Ran(prefix) {
+			t.Errorf("InstallDependencies() did not run %q: %v", prefix, runner.Calls())
+		}
+	}
+	if downloads := runner.Downloads(); !slices.ContainsFunc(downloads, func(url string) bool { return strings.HasSuffix(url, ".pkg") }) {
+		t.Errorf("downloaded %v, want the Homebrew package", downloads)
+	}
+	if missing, err := installer.CheckPrerequisites(deps); err != nil || len(missing) != 0 {
+		t.Errorf("CheckPrerequisites() after installing = %+v, %v", missing, err)
+	}
+}
+
+func TestDarwinInstallDependenciesFails(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed []string
+		responses []platformtest.Response
+		offline   bool
+		deps      []string
+		want      string
+	}{
+		{
+			name:      "Homebrew without the Command Line Tools",
+			responses: []platformtest.Response{{Match: "xcode-select -p", ExitCode: 2}},
+			deps:      []string{"uv"},
+			want:      "xcode-select --install",
+		},
+		{
+			name:      "brew install fails",
+			installed: []string{"brew"},
+			responses: []platformtest.Response{{Match: "brew install segger-jlink", ExitCode: 1}},
+			deps:      []string{"uv", "segger-jlink"},
+			want:      "failed to install segger-jlink",
+		},
+		{
+			name:      "Commander download fails",
+			installed: []string{"brew"},
+			deps:      []string{"simplicity-commander"},
+			want:      "failed to install simplicity-commander",
+		},
+		{
+			name:      "UniFlash not installed",
+			installed: []string{"brew"},
+			deps:      []string{"uniflash"},
+			want:      "UniFlash must be installed manually",
+		},
+		{
+			name:    "installer missing from the offline bundle",
+			offline: true,
+			deps:    []string{"uv"},
+			want:    "not in the offline bundle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := platformtest.NewRunner(tt.installed...).
+				FailDownload("https://www.silabs.com/documents/public/software/SimplicityCommander-Mac.zip", errDownload)
+			for _, resp := range tt.responses {
+				runner.On(resp)
+			}
+			var installer platform.Installer = platform.NewDarwinInstaller(runner)
+			if tt.offline {
+				var err error
+				if installer, err = platform.NewBundleInstaller("darwin", runner, emptyBundle(t, "darwin")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := installer.InstallDependencies(tt.deps)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("InstallDependencies() = %v, want an error saying %q", err, tt.want)
+			}
+			if tt.offline && len(runner.Downloads()) != 0 {
+				t.Errorf("offline install downloaded %v", runner.Downloads())
+			}
+			if runner.Ran("brew install") && !slices.Contains(tt.installed, "brew") {
+				t.Errorf("ran brew without Homebrew: %v", runner.Calls())
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package platform
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// DryRunner wraps a Runner so that nothing on the system changes. Read-only
+// commands, PATH lookups, file checks and environment reads go to the wrapped
+// Runner; every other action is printed instead of performed. The executables,
+// files and variables those actions would have produced are simulated, so the
+// installer follows the same code path as a real run.
+type DryRunner struct {
+	inner Runner
+
+	mu        sync.Mutex
+	installed map[string]bool   // Executables the skipped commands would install
+	created   map[string]bool   // Files the skipped actions would create
+	env       map[string]string // Variables that would have been set
+}
+
+// NewDryRunner creates a DryRunner that inspects the system through inner
+func NewDryRunner(inner Runner) *DryRunner {
+	return &DryRunner{
+		inner:     inner,
+		installed: make(map[string]bool),
+		created:   make(map[string]bool),
+		env:       make(map[string]string),
+	}
+}
+
+// Inner returns the Runner that the dry run inspects the system through
+func (d *DryRunner) Inner() Runner {
+	return d.inner
+}
+
+// Run runs read-only commands and prints all others
+func (d *DryRunner) Run(c Command) error {
+	if d.runs(c) {
+		return d.inner.Run(c)
+	}
+	d.skip(c)
+	return nil
+}
+
+// Output runs read-only commands and prints all others, which produce no output
+func (d *DryRunner) Output(c Command) ([]byte, error) {
+	if d.runs(c) {
+		return d.inner.Output(c)
+	}
+	d.skip(c)
+	return nil, nil
+}
+
+// LookPath finds executables on PATH, including those a skipped command would install
+func (d *DryRunner) LookPath(file string) (string, error) {
+	d.mu.Lock()
+	installed := d.installed[file]
+	d.mu.Unlock()
+	if installed {
+		return file, nil
+	}
+	return d.inner.LookPath(file)
+}
+
+// Getenv returns a variable, including values that would have been set
+func (d *DryRunner) Getenv(key string) string {
+	d.mu.Lock()
+	value, ok := d.env[key]
+	d.mu.Unlock()
+	if ok {
+		return value
+	}
+	return d.inner.Getenv(key)
+}
+
+// Setenv prints the change and remembers it without touching the environment
+func (d *DryRunner) Setenv(key, value string) error {
+	// PATH changes only ever prepend a directory; show just that part
+	if old := d.Getenv(key); old != "" && value != old && strings.HasSuffix(value, old) {
+		printDryRun(fmt.Sprintf("prepend %s to %s", strings.TrimSuffix(value, old), key))
+	} else {
+		printDryRun(fmt.Sprintf("set %s=%s", key, value))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.env[key] = value
+	return nil
+}
+
+// Stat returns information about a file, including files that would have been created
+func (d *DryRunner) Stat(path string) (fs.FileInfo, error) {
+	d.mu.Lock()
+	created := d.created[path]
+	d.mu.Unlock()
+	if created {
+		return plannedFile(filepath.Base(path)), nil
+	}
+	return d.inner.Stat(path)
+}
+
+// Glob finds matching files, including files that would have been created
+func (d *DryRunner) Glob(pattern string) ([]string, error) {
+	matches, err := d.inner.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for path := range d.created {
+		if ok, _ := filepath.Match(pattern, path); ok && !slices.Contains(matches, path) {
+			matches = append(matches, path)
+		}
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// WriteFile prints the file that would be written
+func (d *DryRunner) WriteFile(path string, data []byte) error {
+	printDryRun(fmt.Sprintf("write %s (%d bytes)", path, len(data)))
+	d.create(path)
+	return nil
+}
+
+// MkdirAll prints the directory that would be created
+func (d *DryRunner) MkdirAll(path string) error {
+	printDryRun("create directory " + path)
+	d.create(path)
+	return nil
+}
+
+// RemoveAll prints the path that would be removed
+func (d *DryRunner) RemoveAll(path string) error {
+	printDryRun("remove " + path)
+	return nil
+}
+
+// Download prints the download that would be made
+func (d *DryRunner) Download(url, destPath string) error {
+	printDryRun(fmt.Sprintf("download %s to %s (%s)", url, destPath, describePin(url)))
+	d.create(destPath)
+	return nil
+}
+
+// runs reports whether c may really run: it must be read-only, and its
+// executable must exist rather than be one a skipped command would install
+func (d *DryRunner) runs(c Command) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return c.ReadOnly && !d.installed[c.Path]
+}
+
+// skip prints a command and simulates its effects
+func (d *DryRunner) skip(c Command) {
+	printDryRun("run: " + c.String())
+	for _, path := range c.Creates {
+		printDryRun("create " + path)
+		d.create(path)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, name := range c.Installs {
+		d.installed[name] = true
+	}
+}
+
+// create records a path as existing
+func (d *DryRunner) create(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.created[path] = true
+}
+
+// printDryRun prints an action skipped by a dry run
+func printDryRun(action string) {
+	ui.PrintInfo("[dry run] would " + action)
+}
+
+// plannedFile is the fs.FileInfo of a file that a dry run would have created
+type plannedFile string
+
+func (f plannedFile) Name() string       { return string(f) }
+func (f plannedFile) Size() int64        { return 0 }
+func (f plannedFile) Mode() fs.FileMode  { return 0644 }
+func (f plannedFile) ModTime() time.Time { return time.Time{} }
+func (f plannedFile) IsDir() bool        { return false }
+func (f plannedFile) Sys() any           { return nil }
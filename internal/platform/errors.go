@@ -0,0 +1,109 @@
+package platform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The error types below classify why an installation stopped. Callers check them
+// with errors.As to decide what to tell the user and which exit code to return.
+
+// RebootRequiredError is returned when a system reboot is required
+type RebootRequiredError struct {
+	Message string
+}
+
+func (e *RebootRequiredError) Error() string {
+	return e.Message
+}
+
+// DependencyMissingError is returned when required tools are missing and could not be installed
+type DependencyMissingError struct {
+	Dependencies []string
+	Err          error // Why the dependencies could not be installed, if known
+}
+
+func (e *DependencyMissingError) Error() string {
+	msg := "missing dependencies: " + strings.Join(e.Dependencies, ", ")
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *DependencyMissingError) Unwrap() error {
+	return e.Err
+}
+
+// ProbeNotFoundError is returned when no J-Link probe, or no probe with the requested serial, is connected
+type ProbeNotFoundError struct {
+	Serial string // Requested serial number, empty if any probe would do
+}
+
+func (e *ProbeNotFoundError) Error() string {
+	if e.Serial == "" {
+		return "no J-Link probe detected"
+	}
+	return fmt.Sprintf("no connected J-Link probe has serial number %s", e.Serial)
+}
+
+// FlashFailedError is returned when the flashing tool fails to program a board or generate its hex file
+type FlashFailedError struct {
+	Board string
+	Err   error
+}
+
+func (e *FlashFailedError) Error() string {
+	return fmt.Sprintf("provisioning %s failed: %v", e.Board, e.Err)
+}
+
+func (e *FlashFailedError) Unwrap() error {
+	return e.Err
+}
+
+// NetworkError is returned when a download or API call cannot reach its server
+type NetworkError struct {
+	Op  string // What was being attempted, e.g. "download J-Link"
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("%s: network unreachable: %v", e.Op, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// CredentialInvalidError is returned when the Org ID or API token is malformed or rejected
+type CredentialInvalidError struct {
+	Err error
+}
+
+func (e *CredentialInvalidError) Error() string {
+	return fmt.Sprintf("invalid credentials: %v", e.Err)
+}
+
+func (e *CredentialInvalidError) Unwrap() error {
+	return e.Err
+}
+
+// UserCancelledError is returned when the user declines a step the run cannot continue without
+type UserCancelledError struct {
+	Action string
+}
+
+func (e *UserCancelledError) Error() string {
+	return e.Action + " cancelled"
+}
+
+// UnverifiedDownloadError is returned when a download does not match the checksum
+// pinned for it, or has none, and is refused rather than run
+type UnverifiedDownloadError struct {
+	URL    string
+	Reason string
+}
+
+func (e *UnverifiedDownloadError) Error() string {
+	return fmt.Sprintf("refusing to use %s: %s", e.URL, e.Reason)
+}
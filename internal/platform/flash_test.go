@@ -0,0 +1,133 @@
+package platform_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+)
+
+// hubbledemo is the command line prefix of pyhubbledemo run through the fake uv
+const hubbledemo = "/fake/bin/uv tool run"
+
+// installers creates each platform's installer on a runner, by platform
+var installers = []struct {
+	goos   string
+	new    func(r *platformtest.Runner) platform.Installer
+	dslite string // UniFlash's command-line flasher
+}{
+	{"darwin", func(r *platformtest.Runner) platform.Installer { return platform.NewDarwinInstaller(r) }, "dslite.sh"},
+	{"linux", func(r *platformtest.Runner) platform.Installer {
+		return platform.NewLinuxInstallerAt(r, "/nonexistent", rootUser)
+	}, "dslite.sh"},
+	{"windows", func(r *platformtest.Runner) platform.Installer { return platform.NewWindowsInstaller(r) }, "dslite.bat"},
+}
+
+func TestFlashBoard(t *testing.T) {
+	var (
+		missingErr *platform.DependencyMissingError
+		flashErr   *platform.FlashFailedError
+	)
+	tests := []struct {
+		name      string
+		installed []string // Besides uv, unless noUV
+		noUV      bool
+		fail      string // Command line prefix that exits with an error
+		req       platform.FlashRequest
+		wantRan   string
+		wantErr   any // Pointer to the error type expected, or nil for success
+	}{
+		{
+			name:    "J-Link",
+			req:     platform.FlashRequest{Board: "nrf52840dk", DeviceName: "bench-01"},
+			wantRan: hubbledemo + " --from pyhubbledemo hubbledemo flash nrf52840dk",
+		},
+		{
+			name:    "uv missing",
+			noUV:    true,
+			req:     platform.FlashRequest{Board: "nrf52840dk"},
+			wantErr: &missingErr,
+		},
+		{
+			name:    "pyhubbledemo fails",
+			fail:    hubbledemo,
+			req:     platform.FlashRequest{Board: "nrf52840dk"},
+			wantErr: &flashErr,
+		},
+		{
+			name:      "Simplicity Commander",
+			installed: []string{"commander"},
+			req:       platform.FlashRequest{Board: "xg24_ek2703a", FlashMethod: boards.FlashMethodCommander, Target: "EFR32MG24B210F1536IM48"},
+			wantRan:   "/fake/bin/commander flash",
+		},
+		{
+			name:    "Simplicity Commander missing",
+			req:     platform.FlashRequest{Board: "xg24_ek2703a", FlashMethod: boards.FlashMethodCommander, Target: "EFR32MG24B210F1536IM48"},
+			wantErr: &missingErr,
+		},
+		{
+			name:      "Simplicity Commander fails",
+			installed: []string{"commander"},
+			fail:      "/fake/bin/commander flash",
+			req:       platform.FlashRequest{Board: "xg24_ek2703a", FlashMethod: boards.FlashMethodCommander, Target: "EFR32MG24B210F1536IM48"},
+			wantErr:   &flashErr,
+		},
+		{
+			name:      "UniFlash",
+			installed: []string{"dslite"},
+			req:       platform.FlashRequest{Board: "lp_em_cc2340r5", FlashMethod: boards.FlashMethodUniflash, Target: "CC2340R5"},
+			wantRan:   "/fake/bin/dslite",
+		},
+		{
+			name:    "UniFlash missing",
+			req:     platform.FlashRequest{Board: "lp_em_cc2340r5", FlashMethod: boards.FlashMethodUniflash, Target: "CC2340R5"},
+			wantErr: &missingErr,
+		},
+		{
+			name:      "UniFlash fails",
+			installed: []string{"dslite"},
+			fail:      "/fake/bin/dslite",
+			req:       platform.FlashRequest{Board: "lp_em_cc2340r5", FlashMethod: boards.FlashMethodUniflash, Target: "CC2340R5"},
+			wantErr:   &flashErr,
+		},
+	}
+	for _, inst := range installers {
+		for _, tt := range tests {
+			t.Run(inst.goos+"/"+tt.name, func(t *testing.T) {
+				var installed []string
+				if !tt.noUV {
+					installed = append(installed, "uv")
+				}
+				for _, name := range tt.installed {
+					if name == "dslite" {
+						name = inst.dslite
+					}
+					installed = append(installed, name)
+				}
+				runner := platformtest.NewRunner(installed...)
+				if tt.fail != "" {
+					runner.On(platformtest.Response{Match: tt.fail, ExitCode: 1})
+				}
+
+				result, err := inst.new(runner).FlashBoard(tt.req)
+				if tt.wantErr != nil {
+					if !errors.As(err, tt.wantErr) {
+						t.Fatalf("FlashBoard() = %v, want a %T", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("FlashBoard() = %v", err)
+				}
+				if !runner.Ran(tt.wantRan) {
+					t.Errorf("FlashBoard() ran %v, want %q", runner.Calls(), tt.wantRan)
+				}
+				if want := tt.req.DeviceName; want != "" && result.DeviceName != want {
+					t.Errorf("FlashBoard() named the device %q, want %q", result.DeviceName, want)
+				}
+			})
+		}
+	}
+}
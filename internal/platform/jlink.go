@@ -0,0 +1,149 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// jlinkVersion is the J-Link Software and Documentation Pack release the installer downloads
+const jlinkVersion = "V794l" // Update this periodically
+
+// jlinkDownloadURL returns the URL of SEGGER's J-Link installer for goos and goarch.
+// On Linux, kind picks the package format: deb or rpm.
+func jlinkDownloadURL(goos, goarch, kind string) string {
+	switch goos {
+	case "windows":
+		return fmt.Sprintf("https://www.segger.com/downloads/jlink/JLink_Windows_%s.exe", jlinkVersion)
+	case "darwin":
+		return fmt.Sprintf("https://www.segger.com/downloads/jlink/JLink_MacOSX_%s_universal.pkg", jlinkVersion)
+	default:
+		arch := "x86_64"
+		if goarch == "arm64" {
+			arch = "arm64"
+		}
+		return fmt.Sprintf("https://www.segger.com/downloads/jlink/JLink_Linux_%s_%s.%s", jlinkVersion, arch, kind)
+	}
+}
+
+// Probe describes a J-Link debug probe attached to this machine
+type Probe struct {
+	Serial     string `json:"serial"`     // Probe serial number, used to select it when flashing
+	Product    string `json:"product"`    // Product name reported by the probe (e.g. "J-Link OB-nRF5340-NordicSemi")
+	Connection string `json:"connection"` // "USB" or "IP"
+}
+
+// emuListLine matches one probe line of JLinkExe's ShowEmuList output, e.g.
+//
+//	J-Link[0]: Connection: USB, Serial number: 683000001, ProductName: J-Link OB-nRF5340-NordicSemi
+var emuListLine = regexp.MustCompile(`J-Link\[\d+\]:\s*(.*)$`)
+
+// ParseEmuList extracts the probes from the output of JLinkExe's ShowEmuList command
+func ParseEmuList(output string) []Probe {
+	var probes []Probe
+	for _, line := range strings.Split(output, "\n") {
+		match := emuListLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		var probe Probe
+		for _, field := range strings.Split(match[1], ",") {
+			key, value, found := strings.Cut(field, ":")
+			if !found {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "serial number":
+				probe.Serial = strings.TrimLeft(value, "0")
+			case "productname":
+				probe.Product = value
+			case "connection":
+				probe.Connection = value
+			}
+		}
+
+		if probe.Serial != "" {
+			probes = append(probes, probe)
+		}
+	}
+	return probes
+}
+
+// ListProbes returns the J-Link probes currently connected, as reported by JLinkExe
+func ListProbes() ([]Probe, error) {
+	return ListProbesWith(ExecRunner{})
+}
+
+// ListProbesWith lists the connected J-Link probes, running JLinkExe through r
+func ListProbesWith(r Runner) ([]Probe, error) {
+	output, err := runJLinkScript(r, "ShowEmuList\nexit\n")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list J-Link probes: %w", err)
+	}
+	return ParseEmuList(string(output)), nil
+}
+
+// jlinkBanner matches the version JLinkExe prints on startup, e.g.
+// "SEGGER J-Link Commander V7.94e (Compiled Jan 15 2024 15:15:22)"
+var jlinkBanner = regexp.MustCompile(`J-Link Commander (V\d+\.\d+\w*)`)
+
+// JLinkVersion returns the version of the installed J-Link Commander, e.g. "V7.94e"
+func JLinkVersion(r Runner) (string, error) {
+	output, err := runJLinkScript(r, "exit\n")
+	if err != nil {
+		return "", fmt.Errorf("failed to run J-Link Commander: %w", err)
+	}
+	match := jlinkBanner.FindStringSubmatch(string(output))
+	if match == nil {
+		return "", fmt.Errorf("J-Link Commander did not report its version")
+	}
+	return match[1], nil
+}
+
+// runJLinkScript runs JLinkExe non-interactively, which makes it read its
+// commands from a script file, and returns what it printed
+func runJLinkScript(r Runner, script string) ([]byte, error) {
+	jlinkPath, err := findJLinkExe(r)
+	if err != nil {
+		return nil, err
+	}
+
+	scriptPath := filepath.Join(os.TempDir(), fmt.Sprintf("hubble-jlink-%d.jlink", os.Getpid()))
+	if err := r.WriteFile(scriptPath, []byte(script)); err != nil {
+		return nil, fmt.Errorf("failed to write J-Link command file: %w", err)
+	}
+	defer r.RemoveAll(scriptPath)
+
+	return r.Output(Command{Path: jlinkPath, Args: []string{"-NoGui", "1", "-CommandFile", scriptPath}, ReadOnly: true})
+}
+
+// findJLinkExe locates the J-Link Commander executable ("JLink.exe" on Windows)
+func findJLinkExe(r Runner) (string, error) {
+	for _, name := range []string{"JLinkExe", "JLink"} {
+		if path, err := r.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("J-Link Commander (JLinkExe) not found in PATH")
+}
+
+// hasJLink reports whether J-Link Commander is installed on goos, looking in
+// SEGGER's default install locations on Windows, which it leaves off PATH
+func hasJLink(r Runner, goos string) bool {
+	if _, err := findJLinkExe(r); err == nil {
+		return true
+	}
+	if goos != "windows" {
+		return false
+	}
+	for _, path := range windowsJLinkPaths {
+		if _, err := r.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package platform_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+)
+
+// readFixture returns a file from testdata
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseEmuList(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []platform.Probe
+	}{
+		{
+			fixture: "showemulist.txt",
+			want: []platform.Probe{
+				{Serial: "683000001", Product: "J-Link OB-SAM3U128-V2-NordicSemi", Connection: "USB"},
+				{Serial: "1050234567", Product: "J-Link OB-nRF5340-NordicSemi", Connection: "USB"},
+				{Serial: "801000123", Product: "J-Link PRO V4", Connection: "IP"},
+			},
+		},
+		{
+			fixture: "showemulist-windows.txt",
+			want:    []platform.Probe{{Serial: "440123456", Product: "J-Link OB", Connection: "USB"}},
+		},
+		{fixture: "showemulist-none.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			if got := platform.ParseEmuList(readFixture(t, tt.fixture)); !slices.Equal(got, tt.want) {
+				t.Errorf("ParseEmuList() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListProbesWith(t *testing.T) {
+	runner := platformtest.NewRunner("JLinkExe").
+		On(platformtest.Response{Match: "/fake/bin/JLinkExe -NoGui 1 -CommandFile", Output: readFixture(t, "showemulist.txt")})
+
+	probes, err := platform.ListProbesWith(runner)
+	if err != nil {
+		t.Fatalf("ListProbesWith() = %v", err)
+	}
+	if len(probes) != 3 {
+		t.Errorf("ListProbesWith() = %+v, want the three probes of the fixture", probes)
+	}
+}
+
+func TestListProbesWithoutJLink(t *testing.T) {
+	if _, err := platform.ListProbesWith(platformtest.NewRunner()); err == nil {
+		t.Fatal("ListProbesWith() succeeded without J-Link Commander")
+	}
+}
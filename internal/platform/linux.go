@@ -3,10 +3,14 @@ package platform
 import (
 	"fmt"
 	"os"
-	"os/exec"
+	"os/user"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/bundle"
 	"github.com/HubbleNetwork/hubble-install/internal/ui"
 )
 
@@ -22,13 +26,28 @@ const (
 
 // LinuxInstaller implements the Installer interface for Linux
 type LinuxInstaller struct {
+	runner     Runner
+	root       string         // Filesystem root that udev rules and groups are read from
+	account    Account        // User whose probe access is checked and set up
+	bundle     *bundle.Bundle // Offline bundle to install from instead of downloading, if any
 	pkgManager PackageManager
+	changes    changeLog // What this installer added to the system
 }
 
-// NewLinuxInstaller creates a new Linux installer
-func NewLinuxInstaller() *LinuxInstaller {
+// NewLinuxInstaller creates a new Linux installer that runs commands through runner
+func NewLinuxInstaller(runner Runner) *LinuxInstaller {
+	return NewLinuxInstallerAt(runner, "/", CurrentAccount(runner))
+}
+
+// NewLinuxInstallerAt creates a Linux installer that reads udev rules and groups
+// under root instead of "/", such as a fixture filesystem, and checks probe
+// access for user rather than the user running it
+func NewLinuxInstallerAt(runner Runner, root string, user Account) *LinuxInstaller {
 	return &LinuxInstaller{
-		pkgManager: detectPackageManager(),
+		runner:     runner,
+		root:       root,
+		account:    user,
+		pkgManager: detectPackageManager(runner),
 	}
 }
 
@@ -37,6 +56,11 @@ func (l *LinuxInstaller) Name() string {
 	return "Linux"
 }
 
+// TakeChanges returns what the installer added to the system since the last call
+func (l *LinuxInstaller) TakeChanges() []Change {
+	return l.changes.take()
+}
+
 // CheckPendingReboot checks if a system reboot is pending (not typically needed on Linux)
 func (l *LinuxInstaller) CheckPendingReboot() error {
 	// Linux doesn't typically require reboot checks for package installations
@@ -47,20 +71,14 @@ func (l *LinuxInstaller) CheckPendingReboot() error {
 // ensureSudoAccess validates sudo access upfront to avoid multiple password prompts
 func (l *LinuxInstaller) ensureSudoAccess() error {
 	// Check if we already have valid sudo credentials
-	checkCmd := exec.Command("sudo", "-n", "true")
-	if err := checkCmd.Run(); err == nil {
+	if err := l.runner.Run(Command{Path: "sudo", Args: []string{"-n", "true"}, ReadOnly: true}); err == nil {
 		// Already have valid sudo, no need to prompt
 		return nil
 	}
 
 	// Need to prompt for password
 	ui.PrintWarning("Administrator access required for installation")
-	cmd := exec.Command("sudo", "-v")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
+	if err := l.runner.Run(Command{Path: "sudo", Args: []string{"-v"}, Stdin: true, Show: true}); err != nil {
 		return fmt.Errorf("failed to obtain sudo access: %w", err)
 	}
 
@@ -87,6 +105,14 @@ func (l *LinuxInstaller) CheckPrerequisites(requiredDeps []string) ([]MissingDep
 				})
 			}
 		case "segger-jlink":
+			if !l.commandExists("JLinkExe") && l.bundle != nil {
+				// The offline bundle carries SEGGER's packages, so it can be installed
+				missing = append(missing, MissingDependency{
+					Name:   "segger-jlink",
+					Status: "Not installed",
+				})
+				continue
+			}
 			// Check for SEGGER J-Link (must be installed manually on Linux)
 			if !l.commandExists("JLinkExe") {
 				fmt.Println("") // blank line for readability
@@ -111,15 +137,131 @@ func (l *LinuxInstaller) CheckPrerequisites(requiredDeps []string) ([]MissingDep
 				fmt.Println("") // blank line
 				return nil, fmt.Errorf("J-Link must be installed before running this installer")
 			}
+		case "simplicity-commander":
+			if _, err := findCommander(l.runner, "linux"); err != nil {
+				missing = append(missing, MissingDependency{
+					Name:   "simplicity-commander",
+					Status: "Not installed",
+				})
+			}
+		case "uniflash":
+			if _, err := findDSLite(l.runner, "linux"); err != nil {
+				missing = append(missing, MissingDependency{
+					Name:   "uniflash",
+					Status: "Not installed (manual download required)",
+				})
+			}
+		}
+	}
+
+	// Debug probes are only usable without root when udev grants access to them
+	access, err := l.probeAccess(requiredDeps)
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Could not check debug probe permissions: %v", err))
+		return missing, nil
+	}
+	if len(access.MissingRules) > 0 {
+		names := make([]string, len(access.MissingRules))
+		for i, vendor := range access.MissingRules {
+			names[i] = vendor.Name
 		}
+		missing = append(missing, MissingDependency{
+			Name:   "udev-rules",
+			Status: fmt.Sprintf("No udev rules for %s probes", strings.Join(names, " and ")),
+		})
+	}
+	if len(access.MissingGroups) > 0 {
+		missing = append(missing, MissingDependency{
+			Name:   "probe-groups",
+			Status: fmt.Sprintf("Not a member of %s", strings.Join(access.MissingGroups, ", ")),
+		})
+	} else if len(access.StaleGroups) > 0 {
+		printReloginRequired(access.StaleGroups)
 	}
 
 	return missing, nil
 }
 
-// InstallPackageManager is not needed for Linux (uv and jlink use direct installers)
+// probeAccess checks the udev rules and groups needed by the probes of deps.
+// Root can open any device, so nothing is checked when running as root.
+func (l *LinuxInstaller) probeAccess(deps []string) (probeAccess, error) {
+	vendors := probeVendorsFor(deps)
+	if len(vendors) == 0 || l.account.UID == 0 {
+		return probeAccess{}, nil
+	}
+	return checkProbeAccess(l.root, l.account, vendors)
+}
+
+// setupProbeAccess installs missing udev rules and adds the user to the probe
+// groups, so that the probes of deps can be used without root
+func (l *LinuxInstaller) setupProbeAccess(deps []string) error {
+	access, err := l.probeAccess(deps)
+	if err != nil || access.ok() {
+		return nil
+	}
+
+	if err := l.ensureSudoAccess(); err != nil {
+		return err
+	}
+
+	if len(access.MissingRules) > 0 {
+		ui.PrintInfo("Installing udev rules for debug probes...")
+		tempPath := filepath.Join(os.TempDir(), filepath.Base(hubbleRulesFile))
+		if err := l.runner.WriteFile(tempPath, []byte(udevRules(probeVendorsFor(deps)))); err != nil {
+			return fmt.Errorf("failed to write udev rules: %w", err)
+		}
+		defer l.runner.RemoveAll(tempPath)
+
+		rulesPath := filepath.Join(l.root, hubbleRulesFile)
+		install := Command{Path: "sudo", Args: []string{"install", "-D", "-m", "0644", tempPath, rulesPath}, Show: true, Creates: []string{rulesPath}}
+		if err := l.runner.Run(install); err != nil {
+			return fmt.Errorf("failed to install udev rules: %w", err)
+		}
+		l.changes.record(Change{Kind: ChangeFiles, Name: "udev rules", Path: rulesPath, Root: true})
+		for _, args := range [][]string{{"udevadm", "control", "--reload-rules"}, {"udevadm", "trigger"}} {
+			if err := l.runner.Run(Command{Path: "sudo", Args: args, Show: true}); err != nil {
+				return fmt.Errorf("failed to reload udev rules: %w", err)
+			}
+		}
+		ui.PrintSuccess(fmt.Sprintf("udev rules installed to %s", rulesPath))
+		ui.PrintInfo("If your board is already plugged in, unplug it and plug it back in.")
+	}
+
+	if len(access.MissingGroups) > 0 {
+		user := l.account.Name
+		groups := strings.Join(access.MissingGroups, ",")
+		if err := l.runner.Run(Command{Path: "sudo", Args: []string{"usermod", "-aG", groups, user}, Show: true}); err != nil {
+			return fmt.Errorf("failed to add %s to %s: %w", user, groups, err)
+		}
+		for _, group := range access.MissingGroups {
+			l.changes.record(Change{Kind: ChangeGroup, Name: "probe access", Group: group, User: user, Root: true})
+		}
+		ui.PrintSuccess(fmt.Sprintf("Added %s to %s", user, strings.Join(access.MissingGroups, ", ")))
+		printReloginRequired(access.MissingGroups)
+	}
+
+	return nil
+}
+
+// CurrentAccount returns the user running the installer and the groups of this process
+func CurrentAccount(r Runner) Account {
+	name := r.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	gids, _ := os.Getgroups()
+	return Account{Name: name, UID: os.Geteuid(), ActiveGIDs: append(gids, os.Getgid())}
+}
+
+// printReloginRequired explains that group changes apply from the next login
+func printReloginRequired(groups []string) {
+	ui.PrintWarning(fmt.Sprintf("Your membership of %s takes effect the next time you log in.", strings.Join(groups, ", ")))
+	ui.PrintInfo("If flashing fails with a permissions error, log out and back in (or reboot), then run the installer again.")
+}
+
+// InstallPackageManager is not needed for Linux (uv and jlink use direct downloads)
 func (l *LinuxInstaller) InstallPackageManager() error {
-	// Both uv (astral.sh) and jlink (SEGGER) use their own installers
+	// Both uv (GitHub release) and jlink (SEGGER) are downloaded directly
 	// No package manager operations needed
 	return nil
 }
@@ -129,10 +271,10 @@ func (l *LinuxInstaller) InstallDependencies(deps []string) error {
 	for _, dep := range deps {
 		switch dep {
 		case "uv":
-			// Install uv (must be installed via astral.sh installer)
+			// Install the pinned uv release from GitHub, as the distributions do not package it
 			if !l.commandExists("uv") {
-				ui.PrintInfo("Installing uv from astral.sh...")
-				if err := l.installUV(); err != nil {
+				ui.PrintInfo("Installing uv...")
+				if err := installUVRelease(l.runner, "linux", l.bundle, &l.changes); err != nil {
 					return fmt.Errorf("failed to install uv: %w", err)
 				}
 				ui.PrintSuccess("uv installed successfully")
@@ -143,83 +285,114 @@ func (l *LinuxInstaller) InstallDependencies(deps []string) error {
 			// J-Link must be installed manually on Linux - verified in CheckPrerequisites
 			if l.commandExists("JLinkExe") {
 				ui.PrintSuccess("segger-jlink already installed")
+			} else if l.bundle != nil {
+				ui.PrintInfo("Installing segger-jlink from the offline bundle...")
+				if err := l.installJLinkFromBundle(); err != nil {
+					return fmt.Errorf("failed to install segger-jlink: %w", err)
+				}
+				ui.PrintSuccess("segger-jlink installed successfully")
 			}
+		case "simplicity-commander":
+			if _, err := findCommander(l.runner, "linux"); err == nil {
+				ui.PrintSuccess("simplicity-commander already installed")
+				continue
+			}
+			ui.PrintInfo("Installing Simplicity Commander from Silicon Labs...")
+			if err := installCommander(l.runner, "linux", l.bundle, &l.changes); err != nil {
+				return fmt.Errorf("failed to install simplicity-commander: %w", err)
+			}
+			ui.PrintSuccess("simplicity-commander installed successfully")
+		case "uniflash":
+			if _, err := findDSLite(l.runner, "linux"); err != nil {
+				return uniflashMissing()
+			}
+			ui.PrintSuccess("uniflash already installed")
 		}
 	}
 
-	return nil
+	return l.setupProbeAccess(deps)
 }
 
-// installUV installs uv using the official astral.sh installer
-func (l *LinuxInstaller) installUV() error {
-	// Download and run the uv installer script
-	cmd := exec.Command("sh", "-c", "curl -LsSf https://astral.sh/uv/install.sh | sh")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("uv installation failed: %w", err)
+// installJLinkFromBundle installs the SEGGER J-Link package in the offline bundle
+// with the system package manager
+func (l *LinuxInstaller) installJLinkFromBundle() error {
+	kind, manager, install := "deb", "dpkg", []string{"dpkg", "-i"}
+	switch l.pkgManager {
+	case PackageManagerDNF:
+		kind, manager, install = "rpm", "dnf", []string{"dnf", "install", "-y"}
+	case PackageManagerYUM:
+		kind, manager, install = "rpm", "yum", []string{"yum", "install", "-y"}
 	}
+	url := jlinkDownloadURL("linux", runtime.GOARCH, kind)
 
-	// Add uv to PATH for current process
-	// The installer puts it in ~/.cargo/bin
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+	tempDir := filepath.Join(os.TempDir(), "hubble-jlink-install")
+	if err := l.runner.MkdirAll(tempDir); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	cargoPath := filepath.Join(homeDir, ".cargo", "bin")
+	defer l.runner.RemoveAll(tempDir) // Clean up after installation
 
-	currentPath := os.Getenv("PATH")
-	if !strings.Contains(currentPath, cargoPath) {
-		os.Setenv("PATH", cargoPath+":"+currentPath)
+	pkgPath := filepath.Join(tempDir, path.Base(url))
+	if err := fetchInstaller(l.runner, l.bundle, url, pkgPath); err != nil {
+		return err
 	}
 
+	if err := l.ensureSudoAccess(); err != nil {
+		return err
+	}
+	cmd := Command{Path: "sudo", Args: append(install, pkgPath), Show: true, Installs: []string{"JLinkExe"}}
+	if err := l.runner.Run(cmd); err != nil {
+		return fmt.Errorf("J-Link package installation failed: %w", err)
+	}
+	l.changes.record(Change{Kind: ChangePackage, Name: "segger-jlink", Manager: manager, Package: l.packageName(kind, pkgPath), Root: true})
 	return nil
 }
 
-// FlashBoard flashes the specified board using uvx (for J-Link boards)
-func (l *LinuxInstaller) FlashBoard(orgID, apiToken, board, deviceName string) (*FlashResult, error) {
-	ui.PrintInfo(fmt.Sprintf("Flashing board: %s", board))
+// packageName reads the name a .deb or .rpm file installs its package under
+func (l *LinuxInstaller) packageName(kind, pkgPath string) string {
+	query := Command{Path: "dpkg-deb", Args: []string{"-f", pkgPath, "Package"}, ReadOnly: true}
+	if kind == "rpm" {
+		query = Command{Path: "rpm", Args: []string{"-qp", "--queryformat", "%{NAME}", pkgPath}, ReadOnly: true}
+	}
+	if out, err := l.runner.Output(query); err == nil && strings.TrimSpace(string(out)) != "" {
+		return strings.TrimSpace(string(out))
+	}
+	return "jlink" // SEGGER's package name in the .deb
+}
+
+// FlashBoard flashes the specified board using uvx, through the vendor tool of its flash method
+func (l *LinuxInstaller) FlashBoard(req FlashRequest) (*FlashResult, error) {
+	ui.PrintInfo(fmt.Sprintf("Flashing board: %s", req.Board))
 	ui.PrintInfo("This may take 10-15 seconds...")
 
-	uvPath, err := exec.LookPath("uv")
+	uvPath, err := l.runner.LookPath("uv")
 	if err != nil {
-		return nil, fmt.Errorf("uv not found in PATH: %w", err)
+		return nil, &DependencyMissingError{Dependencies: []string{"uv"}, Err: err}
 	}
 
-	// Build the command
-	args := []string{"tool", "run", "--from", "pyhubbledemo", "hubbledemo", "flash", board, "-o", orgID, "-t", apiToken}
-	if deviceName != "" {
-		args = append(args, "-n", deviceName)
+	switch req.FlashMethod {
+	case boards.FlashMethodCommander:
+		return flashWithCommander(l.runner, "linux", uvPath, req)
+	case boards.FlashMethodUniflash:
+		return flashWithUniflash(l.runner, "linux", uvPath, req)
 	}
-	cmd := exec.Command(uvPath, args...)
 
-	cmd.Env = append(os.Environ(), "PYTHONWARNINGS=ignore")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("flash command failed: %w", err)
-	}
-
-	resultDeviceName := deviceName
-	if resultDeviceName == "" {
-		resultDeviceName = "your-device"
+	// Run pyhubbledemo's flash command
+	if err := l.runner.Run(hubbledemoCommand(uvPath, req, "")); err != nil {
+		return nil, &FlashFailedError{Board: req.Board, Err: err}
 	}
 
-	ui.PrintSuccess(fmt.Sprintf("Board %s flashed successfully!", board))
-	return &FlashResult{DeviceName: resultDeviceName}, nil
+	ui.PrintSuccess(fmt.Sprintf("Board %s flashed successfully!", req.Board))
+	return newFlashResult(req, ""), nil
 }
 
 // GenerateHexFile generates a hex file for Uniflash boards (TI)
-func (l *LinuxInstaller) GenerateHexFile(orgID, apiToken, board, deviceName string) (*FlashResult, error) {
-	ui.PrintInfo(fmt.Sprintf("Generating hex file for board: %s", board))
+func (l *LinuxInstaller) GenerateHexFile(req FlashRequest) (*FlashResult, error) {
+	ui.PrintInfo(fmt.Sprintf("Generating hex file for board: %s", req.Board))
 	ui.PrintInfo("This may take a few seconds...")
 
-	uvPath, err := exec.LookPath("uv")
+	uvPath, err := l.runner.LookPath("uv")
 	if err != nil {
-		return nil, fmt.Errorf("uv not found in PATH: %w", err)
+		return nil, &DependencyMissingError{Dependencies: []string{"uv"}, Err: err}
 	}
 
 	// Determine hex file path in current working directory
@@ -229,88 +402,69 @@ func (l *LinuxInstaller) GenerateHexFile(orgID, apiToken, board, deviceName stri
 	}
 
 	// Use device name for filename if provided, otherwise use board name
-	filename := board + ".hex"
-	if deviceName != "" {
-		filename = deviceName + ".hex"
+	filename := req.Board + ".hex"
+	if req.DeviceName != "" {
+		filename = req.DeviceName + ".hex"
 	}
 	hexFilePath := filepath.Join(currentDir, filename)
 
-	// Build the command with -f for output file
-	args := []string{"tool", "run", "--from", "pyhubbledemo", "hubbledemo", "flash", board, "-o", orgID, "-t", apiToken, "-f", hexFilePath}
-	if deviceName != "" {
-		args = append(args, "-n", deviceName)
-	}
-	cmd := exec.Command(uvPath, args...)
-
-	cmd.Env = append(os.Environ(), "PYTHONWARNINGS=ignore")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("command failed: %w", err)
+	// Run pyhubbledemo with -f for output file
+	if err := l.runner.Run(hubbledemoCommand(uvPath, req, hexFilePath)); err != nil {
+		return nil, &FlashFailedError{Board: req.Board, Err: err}
 	}
 
 	// Verify the hex file was created at the expected location
-	if _, err := os.Stat(hexFilePath); err != nil {
+	if _, err := l.runner.Stat(hexFilePath); err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("hex file was not created at expected location: %s\n"+
+			return nil, &FlashFailedError{Board: req.Board, Err: fmt.Errorf("hex file was not created at expected location: %s\n"+
 				"The pyhubbledemo tool may not support the -f flag properly.\n"+
-				"Please check if a hex file was created in a temporary location", hexFilePath)
+				"Please check if a hex file was created in a temporary location", hexFilePath)}
 		}
 		return nil, fmt.Errorf("error checking hex file: %w", err)
 	}
 
-	return &FlashResult{HexFilePath: hexFilePath}, nil
+	return newFlashResult(req, hexFilePath), nil
 }
 
 // Helper functions
 
 // detectPackageManager detects which package manager is available
-func detectPackageManager() PackageManager {
-	if commandExistsGlobal("apt-get") {
-		return PackageManagerAPT
-	}
-	if commandExistsGlobal("dnf") {
-		return PackageManagerDNF
-	}
-	if commandExistsGlobal("yum") {
-		return PackageManagerYUM
+func detectPackageManager(runner Runner) PackageManager {
+	for _, pm := range []struct {
+		command string
+		manager PackageManager
+	}{
+		{"apt-get", PackageManagerAPT},
+		{"dnf", PackageManagerDNF},
+		{"yum", PackageManagerYUM},
+	} {
+		if _, err := runner.LookPath(pm.command); err == nil {
+			return pm.manager
+		}
 	}
 	return PackageManagerUnknown
 }
 
 // commandExists checks if a command is available in PATH
 func (l *LinuxInstaller) commandExists(cmd string) bool {
-	_, err := exec.LookPath(cmd)
-	return err == nil
-}
-
-// commandExistsGlobal checks if a command is available (global function for init)
-func commandExistsGlobal(cmd string) bool {
-	_, err := exec.LookPath(cmd)
+	_, err := l.runner.LookPath(cmd)
 	return err == nil
 }
 
 // installPackage installs a package using the detected package manager
 func (l *LinuxInstaller) installPackage(pkg string, showOutput bool) error {
-	var cmd *exec.Cmd
+	var manager string
 
 	switch l.pkgManager {
 	case PackageManagerAPT:
-		cmd = exec.Command("sudo", "apt-get", "install", "-y", pkg)
+		manager = "apt-get"
 	case PackageManagerDNF:
-		cmd = exec.Command("sudo", "dnf", "install", "-y", pkg)
+		manager = "dnf"
 	case PackageManagerYUM:
-		cmd = exec.Command("sudo", "yum", "install", "-y", pkg)
+		manager = "yum"
 	default:
 		return fmt.Errorf("unsupported package manager")
 	}
 
-	// Show output if requested
-	if showOutput {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-	}
-
-	return cmd.Run()
+	return l.runner.Run(Command{Path: "sudo", Args: []string{manager, "install", "-y", pkg}, Show: showOutput, Installs: []string{executableFor(pkg)}})
 }
@@ -0,0 +1,316 @@
+package platform_test
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/bundle"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+)
+
+// dev is the account probe access is checked for, with no groups active
+var dev = platform.Account{Name: "dev", UID: 1000, ActiveGIDs: []int{1000}}
+
+// fixtureRoot writes files, by path relative to the filesystem root, into a
+// temporary directory standing in for "/"
+func fixtureRoot(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// missingNames returns the names of missing dependencies
+func missingNames(missing []platform.MissingDependency) map[string]bool {
+	names := make(map[string]bool)
+	for _, m := range missing {
+		names[m.Name] = true
+	}
+	return names
+}
+
+// allDeps are the dependencies of every flash method
+var allDeps = []string{"uv", "segger-jlink", "simplicity-commander", "uniflash"}
+
+// emptyBundle returns an offline bundle for goos that holds no downloads
+func emptyBundle(t *testing.T, goos string) *bundle.Bundle {
+	t.Helper()
+	return &bundle.Bundle{Dir: t.TempDir(), Manifest: bundle.Manifest{Platform: goos + "/" + runtime.GOARCH}}
+}
+
+// sortedNames returns the names of missing dependencies in order
+func sortedNames(missing []platform.MissingDependency) []string {
+	return slices.Sorted(maps.Keys(missingNames(missing)))
+}
+
+// errDownload is the error of a download scripted to fail
+var errDownload = errors.New("connection refused")
+
+func TestLinuxProbeAccess(t *testing.T) {
+	const passwd = "dev:x:1000:1000::/home/dev:/bin/bash\n"
+	const seggerRules = `SUBSYSTEM=="usb", ATTR{idVendor}=="1366", MODE="0664", GROUP="plugdev"` + "\n"
+
+	tests := []struct {
+		name       string
+		user       platform.Account
+		files      map[string]string
+		wantRules  bool
+		wantGroups bool
+	}{
+		{
+			name:       "nothing set up",
+			user:       dev,
+			files:      map[string]string{"etc/group": "plugdev:x:46:\ndialout:x:20:\n", "etc/passwd": passwd},
+			wantRules:  true,
+			wantGroups: true,
+		},
+		{
+			name: "rules shipped by the distribution and groups active",
+			user: platform.Account{Name: "dev", UID: 1000, ActiveGIDs: []int{1000, 20, 46}},
+			files: map[string]string{
+				"etc/group":                           "plugdev:x:46:dev\ndialout:x:20:dev\n",
+				"etc/passwd":                          passwd,
+				"lib/udev/rules.d/99-jlink.rules":     seggerRules,
+				"etc/udev/rules.d/70-unrelated.rules": `ATTRS{idVendor}=="0451"` + "\n",
+			},
+		},
+		{
+			name: "groups granted but not active until the next login",
+			user: dev,
+			files: map[string]string{
+				"etc/group":                       "plugdev:x:46:dev\ndialout:x:20:dev\n",
+				"etc/passwd":                      passwd,
+				"etc/udev/rules.d/99-jlink.rules": seggerRules,
+			},
+		},
+		{
+			name: "primary group and no plugdev group",
+			user: dev,
+			files: map[string]string{
+				"etc/group":                       "dialout:x:20:\n",
+				"etc/passwd":                      "dev:x:1000:20::/home/dev:/bin/bash\n",
+				"etc/udev/rules.d/99-jlink.rules": seggerRules,
+			},
+		},
+		{
+			name:  "root",
+			user:  platform.Account{Name: "root", UID: 0},
+			files: map[string]string{"etc/group": "plugdev:x:46:\ndialout:x:20:\n", "etc/passwd": passwd},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := platformtest.NewRunner("apt-get", "uv", "JLinkExe")
+			installer := platform.NewLinuxInstallerAt(runner, fixtureRoot(t, tt.files), tt.user)
+			missing, err := installer.CheckPrerequisites([]string{"uv", "segger-jlink"})
+			if err != nil {
+				t.Fatalf("CheckPrerequisites() = %v", err)
+			}
+			names := missingNames(missing)
+			if names["udev-rules"] != tt.wantRules {
+				t.Errorf("udev rules reported missing: %v, want %v (%+v)", names["udev-rules"], tt.wantRules, missing)
+			}
+			if names["probe-groups"] != tt.wantGroups {
+				t.Errorf("probe groups reported missing: %v, want %v (%+v)", names["probe-groups"], tt.wantGroups, missing)
+			}
+		})
+	}
+}
+
+func TestLinuxSetupProbeAccess(t *testing.T) {
+	root := fixtureRoot(t, map[string]string{
+		"etc/group":  "plugdev:x:46:\ndialout:x:20:\n",
+		"etc/passwd": "dev:x:1000:1000::/home/dev:/bin/bash\n",
+	})
+	runner := platformtest.NewRunner("apt-get", "uv", "JLinkExe")
+	installer := platform.NewLinuxInstallerAt(runner, root, dev)
+	if err := installer.InstallDependencies([]string{"uv", "segger-jlink"}); err != nil {
+		t.Fatalf("InstallDependencies() = %v", err)
+	}
+
+	rulesPath := filepath.Join(root, "etc/udev/rules.d/99-hubble-probes.rules")
+	if _, err := runner.Stat(rulesPath); err != nil {
+		t.Errorf("udev rules were not installed to %s: %v", rulesPath, runner.Calls())
+	}
+	if !runner.Ran("sudo usermod -aG plugdev,dialout dev") {
+		t.Errorf("dev was not added to the probe groups: %v", runner.Calls())
+	}
+
+	changes := installer.TakeChanges()
+	kinds := make(map[string]int)
+	for _, c := range changes {
+		kinds[c.Kind]++
+	}
+	if kinds[platform.ChangeFiles] != 1 || kinds[platform.ChangeGroup] != 2 {
+		t.Errorf("recorded %v, want the rules file and both groups", changes)
+	}
+}
+
+func TestLinuxSetupProbeAccessAsRoot(t *testing.T) {
+	root := fixtureRoot(t, map[string]string{"etc/group": "plugdev:x:46:\n"})
+	runner := platformtest.NewRunner("apt-get", "uv", "JLinkExe")
+	installer := platform.NewLinuxInstallerAt(runner, root, platform.Account{Name: "root", UID: 0})
+	if err := installer.InstallDependencies([]string{"uv", "segger-jlink"}); err != nil {
+		t.Fatalf("InstallDependencies() = %v", err)
+	}
+	if runner.Ran("sudo") {
+		t.Errorf("InstallDependencies() ran %v as root, which needs no probe access", runner.Calls())
+	}
+}
+
+// rootUser is an account that needs no probe access set up
+var rootUser = platform.Account{Name: "root", UID: 0}
+
+func TestLinuxCheckPrerequisites(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed []string
+		files     []string
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:      "nothing installed but J-Link",
+			installed: []string{"apt-get", "JLinkExe"},
+			want:      []string{"simplicity-commander", "uniflash", "uv"},
+		},
+		{
+			name:      "everything installed",
+			installed: []string{"dnf", "uv", "JLinkExe", "commander"},
+			files:     []string{"/opt/ti/uniflash_8.7.0/dslite.sh"},
+		},
+		{
+			name:      "Commander in the install location",
+			installed: []string{"yum", "uv", "JLinkExe", "dslite.sh"},
+			files:     []string{"/home/dev/.local/share/hubble/commander/commander/commander"},
+		},
+		{
+			name:      "no supported package manager",
+			installed: []string{"uv", "JLinkExe"},
+			wantErr:   "unsupported Linux distribution",
+		},
+		{
+			name:      "J-Link not installed",
+			installed: []string{"apt-get", "uv"},
+			wantErr:   "J-Link must be installed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := platformtest.NewRunner(tt.installed...)
+			runner.Setenv("HOME", "/home/dev")
+			for _, path := range tt.files {
+				runner.AddFile(path)
+			}
+			installer := platform.NewLinuxInstallerAt(runner, t.TempDir(), rootUser)
+
+			missing, err := installer.CheckPrerequisites(allDeps)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CheckPrerequisites() = %+v, %v, want an error saying %q", missing, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckPrerequisites() = %v", err)
+			}
+			if got := sortedNames(missing); !slices.Equal(got, tt.want) {
+				t.Errorf("CheckPrerequisites() reported %v missing, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinuxCheckPrerequisitesOffline(t *testing.T) {
+	// The offline bundle carries SEGGER's packages, so a missing J-Link is installable
+	runner := platformtest.NewRunner("apt-get", "uv")
+	installer, err := platform.NewBundleInstaller("linux", runner, emptyBundle(t, "linux"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := installer.CheckPrerequisites([]string{"uv", "segger-jlink"})
+	if err != nil {
+		t.Fatalf("CheckPrerequisites() = %v", err)
+	}
+	if !missingNames(missing)["segger-jlink"] {
+		t.Errorf("CheckPrerequisites() reported %+v, want segger-jlink missing", missing)
+	}
+}
+
+func TestLinuxInstallDependencies(t *testing.T) {
+	runner := platformtest.NewRunner("apt-get")
+	runner.Setenv("HOME", "/home/dev")
+	installer := platform.NewLinuxInstallerAt(runner, t.TempDir(), rootUser)
+	deps := []string{"uv", "simplicity-commander"}
+	if err := installer.InstallDependencies(deps); err != nil {
+		t.Fatalf("InstallDependencies() = %v", err)
+	}
+
+	if !runner.Ran("tar -xzf") {
+		t.Errorf("uv was not unpacked: %v", runner.Calls())
+	}
+	if downloads := runner.Downloads(); len(downloads) != 2 {
+		t.Errorf("downloaded %v, want uv and Simplicity Commander", downloads)
+	}
+	if missing, err := installer.CheckPrerequisites(deps); err != nil || len(missing) != 0 {
+		t.Errorf("CheckPrerequisites() after installing = %+v, %v", missing, err)
+	}
+	if runner.Getenv("PATH") != "/home/dev/.local/bin:" {
+		t.Errorf("PATH is %q, want uv's directory on it", runner.Getenv("PATH"))
+	}
+}
+
+func TestLinuxInstallDependenciesFails(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []platformtest.Response
+		deps      []string
+		want      string
+	}{
+		{
+			name:      "uv does not unpack",
+			responses: []platformtest.Response{{Match: "tar", ExitCode: 2}},
+			deps:      []string{"uv"},
+			want:      "failed to unpack uv",
+		},
+		{
+			name: "Commander download fails",
+			deps: []string{"simplicity-commander"},
+			want: "failed to install simplicity-commander",
+		},
+		{
+			name: "UniFlash not installed",
+			deps: []string{"uniflash"},
+			want: "UniFlash must be installed manually",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := platformtest.NewRunner("apt-get").
+				FailDownload("https://www.silabs.com/documents/public/software/SimplicityCommander-Linux.zip", errDownload)
+			for _, resp := range tt.responses {
+				runner.On(resp)
+			}
+
+			err := platform.NewLinuxInstallerAt(runner, t.TempDir(), rootUser).InstallDependencies(tt.deps)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("InstallDependencies() = %v, want an error saying %q", err, tt.want)
+			}
+		})
+	}
+}
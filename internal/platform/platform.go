@@ -7,14 +7,66 @@ import (
 
 // MissingDependency represents a missing system dependency
 type MissingDependency struct {
-	Name   string
-	Status string
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Manual bool   `json:"manual,omitempty"` // The installer cannot continue until it is installed by hand
+}
+
+// FindMissing reports which of deps are missing on goos. Unlike CheckPrerequisites
+// it prints nothing, runs nothing and never fails, so diagnostics can call it for
+// any dependency.
+func FindMissing(r Runner, goos string, deps []string) []MissingDependency {
+	var missing []MissingDependency
+	for _, dep := range deps {
+		switch dep {
+		case "uv":
+			if _, err := r.LookPath("uv"); err != nil {
+				missing = append(missing, MissingDependency{Name: dep, Status: "Not installed"})
+			}
+		case "segger-jlink":
+			if hasJLink(r, goos) {
+				continue
+			}
+			if goos == "linux" {
+				// SEGGER's license keeps the installer from downloading it on Linux
+				missing = append(missing, MissingDependency{Name: dep, Status: "Not installed (download it from segger.com)", Manual: true})
+				continue
+			}
+			missing = append(missing, MissingDependency{Name: dep, Status: "Not installed"})
+		case "simplicity-commander":
+			if _, err := findCommander(r, goos); err != nil {
+				missing = append(missing, MissingDependency{Name: dep, Status: "Not installed"})
+			}
+		case "uniflash":
+			// Without UniFlash a hex file is generated instead, so it does not block
+			if _, err := findDSLite(r, goos); err != nil {
+				missing = append(missing, MissingDependency{Name: dep, Status: "Not installed (manual download required)"})
+			}
+		}
+	}
+	return missing
+}
+
+// FlashRequest describes a board to flash, or a hex file to generate
+type FlashRequest struct {
+	OrgID       string
+	APIToken    string
+	Board       string
+	FlashMethod string // Flash method of the board, from the board catalog
+	DeviceName  string
+	ProbeSerial string // Debug probe to use when several are attached; empty uses the default probe
+	Target      string // Device part number for the flashing tool, from the board catalog
+	ToolVersion string // Exact pyhubbledemo release to flash with, from ResolveToolVersion
+	DeviceID    string // ID of a device registered before flashing, if any
+	DeviceKey   string // Key of that device; when set the flashing tool does not register a new device
 }
 
 // FlashResult contains the result of a flash operation
 type FlashResult struct {
-	DeviceName  string // Device name (for J-Link flash)
-	HexFilePath string // Path to generated hex file (for Uniflash)
+	DeviceID    string `json:"device_id,omitempty"` // ID of the registered device, when known
+	DeviceName  string `json:"device_name"`         // Device name
+	HexFilePath string `json:"hex_file,omitempty"`  // Path to generated hex file (for Uniflash)
+	ToolVersion string `json:"tool_version"`        // pyhubbledemo release that produced the firmware
 }
 
 // Installer defines the interface for platform-specific installation
@@ -35,22 +87,91 @@ type Installer interface {
 	InstallDependencies(deps []string) error
 
 	// FlashBoard flashes the specified board with credentials and returns the result
-	FlashBoard(orgID, apiToken, board, deviceName string) (*FlashResult, error)
+	FlashBoard(req FlashRequest) (*FlashResult, error)
 
 	// GenerateHexFile generates a hex file for Uniflash boards and returns the path
-	GenerateHexFile(orgID, apiToken, board, deviceName string) (*FlashResult, error)
+	GenerateHexFile(req FlashRequest) (*FlashResult, error)
+
+	// TakeChanges returns what the installer added to the system since the last
+	// call, for the install manifest and rollback
+	TakeChanges() []Change
 }
 
 // GetInstaller returns the appropriate installer for the current platform
 func GetInstaller() (Installer, error) {
-	switch runtime.GOOS {
+	return NewInstaller(runtime.GOOS, ExecRunner{})
+}
+
+// NewInstaller returns the installer for the named operating system, running its
+// commands through runner
+func NewInstaller(goos string, runner Runner) (Installer, error) {
+	switch goos {
 	case "darwin":
-		return NewDarwinInstaller(), nil
+		return NewDarwinInstaller(runner), nil
 	case "linux":
-		return NewLinuxInstaller(), nil
+		return NewLinuxInstaller(runner), nil
 	case "windows":
-		return NewWindowsInstaller(), nil
+		return NewWindowsInstaller(runner), nil
 	default:
-		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+		return nil, fmt.Errorf("unsupported platform: %s", goos)
+	}
+}
+
+// hubbledemoArgs builds the uv arguments that run pyhubbledemo's flash command.
+// A non-empty hexFilePath writes a hex file instead of flashing the board. The
+// release is pinned, so uv's cache never serves a different version. --key and
+// --serial are only set on requests for releases that accept them (see
+// HubbledemoAccepts).
+func hubbledemoArgs(req FlashRequest, hexFilePath string) []string {
+	args := []string{"tool", "run", "--from", toolRequirement(req.ToolVersion), "hubbledemo", "flash", req.Board, "-o", req.OrgID, "-t", req.APIToken}
+	if hexFilePath != "" {
+		args = append(args, "-f", hexFilePath)
+	}
+	if req.DeviceKey != "" {
+		// The device is already registered and named; provision it with its key
+		args = append(args, "--key", req.DeviceKey)
+	} else if req.DeviceName != "" {
+		args = append(args, "-n", req.DeviceName)
+	}
+	if req.ProbeSerial != "" && hexFilePath == "" {
+		// The serial selects the J-Link probe pyhubbledemo flashes through
+		args = append(args, "--serial", req.ProbeSerial)
+	}
+	return args
+}
+
+// hubbledemoCommand builds the command that runs pyhubbledemo through uv
+func hubbledemoCommand(uvPath string, req FlashRequest, hexFilePath string) Command {
+	cmd := Command{
+		Path:   uvPath,
+		Args:   hubbledemoArgs(req, hexFilePath),
+		Env:    []string{"PYTHONWARNINGS=ignore"},
+		Show:   true,
+		Redact: []string{req.APIToken, req.DeviceKey},
+	}
+	if hexFilePath != "" {
+		cmd.Creates = []string{hexFilePath}
+	}
+	return cmd
+}
+
+// executableFor returns the command that a dependency package puts on PATH
+func executableFor(pkg string) string {
+	if pkg == "segger-jlink" {
+		return "JLinkExe"
+	}
+	return pkg
+}
+
+// newFlashResult builds the result for a completed request, falling back to the
+// device ID (or a placeholder) when the device was not given a name
+func newFlashResult(req FlashRequest, hexFilePath string) *FlashResult {
+	name := req.DeviceName
+	if name == "" {
+		name = req.DeviceID
+	}
+	if name == "" {
+		name = "your-device"
 	}
+	return &FlashResult{DeviceID: req.DeviceID, DeviceName: name, HexFilePath: hexFilePath, ToolVersion: req.ToolVersion}
 }
@@ -0,0 +1,288 @@
+// Package platformtest provides a scripted platform.Runner for exercising the
+// platform installers without running any real commands.
+package platformtest
+
+import (
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+)
+
+// Runner must satisfy the interface the installers depend on
+var _ platform.Runner = (*Runner)(nil)
+
+// Response scripts the outcome of commands whose command line starts with Match
+type Response struct {
+	Match    string   // Command line prefix, e.g. "brew install uv"
+	Output   string   // Standard output returned by Output
+	ExitCode int      // Non-zero makes the command fail with an *ExitError
+	Err      error    // Returned as-is when set, e.g. to simulate a missing executable
+	Installs []string // Executables that LookPath finds once the command succeeds
+	Creates  []string // Files that Stat finds once the command succeeds
+}
+
+// ExitError is returned for a scripted command that exits with a non-zero status
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the scripted exit status
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Runner is a platform.Runner that answers from a script instead of running
+// commands, and keeps files and environment variables in memory. Commands with
+// no matching Response succeed with no output. It is safe for concurrent use, as
+// DarwinInstaller installs packages in parallel.
+type Runner struct {
+	mu        sync.Mutex
+	responses []Response
+	paths     map[string]string
+	env       map[string]string
+	files     map[string]bool
+	contents  map[string][]byte // Data passed to WriteFile, by path
+	failures  map[string]error  // Download errors by URL
+	calls     []platform.Command
+	downloads []string
+	removed   []string
+}
+
+// NewRunner creates a Runner on which the given executables are already installed
+func NewRunner(installed ...string) *Runner {
+	r := &Runner{
+		paths:    make(map[string]string),
+		env:      make(map[string]string),
+		files:    make(map[string]bool),
+		contents: make(map[string][]byte),
+		failures: make(map[string]error),
+	}
+	for _, name := range installed {
+		r.paths[name] = "/fake/bin/" + name
+	}
+	return r
+}
+
+// On scripts the response for commands matching resp.Match. Later responses
+// take precedence over earlier ones with the same prefix.
+func (r *Runner) On(resp Response) *Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = append(r.responses, resp)
+	return r
+}
+
+// AddFile makes Stat find path
+func (r *Runner) AddFile(path string) *Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[path] = true
+	return r
+}
+
+// FailDownload makes downloads of url return err
+func (r *Runner) FailDownload(url string, err error) *Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[url] = err
+	return r
+}
+
+// Downloads returns the URLs downloaded so far, in order
+func (r *Runner) Downloads() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.downloads...)
+}
+
+// Removed returns the paths passed to RemoveAll, in order
+func (r *Runner) Removed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.removed...)
+}
+
+// Calls returns the command lines run so far, in order
+func (r *Runner) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lines := make([]string, len(r.calls))
+	for i, call := range r.calls {
+		lines[i] = call.String()
+	}
+	return lines
+}
+
+// Ran reports whether a command starting with prefix was run
+func (r *Runner) Ran(prefix string) bool {
+	for _, line := range r.Calls() {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run records the command and returns its scripted result
+func (r *Runner) Run(cmd platform.Command) error {
+	_, err := r.Output(cmd)
+	return err
+}
+
+// Output records the command and returns its scripted output and result
+func (r *Runner) Output(cmd platform.Command) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, cmd)
+
+	resp := r.match(cmd.String())
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	if resp.ExitCode != 0 {
+		return []byte(resp.Output), &ExitError{Code: resp.ExitCode}
+	}
+	for _, name := range append(resp.Installs, cmd.Installs...) {
+		r.paths[name] = "/fake/bin/" + name
+	}
+	for _, path := range append(resp.Creates, cmd.Creates...) {
+		r.files[path] = true
+	}
+	return []byte(resp.Output), nil
+}
+
+// match returns the most recently scripted response matching line, or an empty
+// response that succeeds
+func (r *Runner) match(line string) Response {
+	for i := len(r.responses) - 1; i >= 0; i-- {
+		if strings.HasPrefix(line, r.responses[i].Match) {
+			return r.responses[i]
+		}
+	}
+	return Response{}
+}
+
+// LookPath finds executables that were installed up front or by a scripted command
+func (r *Runner) LookPath(file string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if path, ok := r.paths[file]; ok {
+		return path, nil
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
+
+// Getenv returns a variable set with Setenv, or empty
+func (r *Runner) Getenv(key string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.env[key]
+}
+
+// Setenv sets a variable in the fake environment only
+func (r *Runner) Setenv(key, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.env[key] = value
+	return nil
+}
+
+// Stat finds files added with AddFile or created by a command
+func (r *Runner) Stat(path string) (fs.FileInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.files[path] {
+		return fileInfo(path), nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+}
+
+// Glob matches files added with AddFile or created by a command
+func (r *Runner) Glob(pattern string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matches []string
+	for path := range r.files {
+		ok, err := filepath.Match(pattern, path)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, path)
+		}
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// WriteFile records the file and its contents
+func (r *Runner) WriteFile(path string, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[path] = true
+	r.contents[path] = append([]byte(nil), data...)
+	return nil
+}
+
+// File returns the contents written to path with WriteFile
+func (r *Runner) File(path string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, ok := r.contents[path]
+	return data, ok
+}
+
+// MkdirAll records the directory as existing
+func (r *Runner) MkdirAll(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[path] = true
+	return nil
+}
+
+// RemoveAll forgets path and everything under it
+func (r *Runner) RemoveAll(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removed = append(r.removed, path)
+	for file := range r.files {
+		if file == path || strings.HasPrefix(file, path+"/") || strings.HasPrefix(file, path+`\`) {
+			delete(r.files, file)
+			delete(r.contents, file)
+		}
+	}
+	return nil
+}
+
+// Download records the URL and creates destPath, unless the download was scripted to fail
+func (r *Runner) Download(url, destPath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downloads = append(r.downloads, url)
+	if err := r.failures[url]; err != nil {
+		return err
+	}
+	r.files[destPath] = true
+	return nil
+}
+
+// fileInfo is the fs.FileInfo of a fake file
+type fileInfo string
+
+func (f fileInfo) Name() string       { return string(f) }
+func (f fileInfo) Size() int64        { return 0 }
+func (f fileInfo) Mode() fs.FileMode  { return 0644 }
+func (f fileInfo) ModTime() time.Time { return time.Time{} }
+func (f fileInfo) IsDir() bool        { return false }
+func (f fileInfo) Sys() any           { return nil }
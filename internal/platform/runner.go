@@ -0,0 +1,237 @@
+package platform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/download"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// Command describes an external program for a Runner to execute
+type Command struct {
+	Path  string   // Executable name or path
+	Args  []string // Arguments, not including the executable
+	Env   []string // Extra KEY=value pairs added to the process environment
+	Stdin bool     // Connect the terminal's stdin, for commands that may prompt
+	Show  bool     // Stream stdout and stderr to the terminal
+
+	ReadOnly bool     // Only inspects the system, so it also runs during a dry run
+	Installs []string // Executables the command puts on PATH
+	Creates  []string // Files or directories the command creates
+	Redact   []string // Secret argument values masked when the command is displayed
+}
+
+// String returns the command line, quoting arguments that contain spaces and
+// masking secrets
+func (c Command) String() string {
+	parts := make([]string, 0, len(c.Args)+1)
+	for _, part := range append([]string{c.Path}, c.Args...) {
+		for _, secret := range c.Redact {
+			if secret != "" {
+				part = strings.ReplaceAll(part, secret, "<redacted>")
+			}
+		}
+		if part == "" || strings.ContainsAny(part, " \t\n\"'") {
+			part = `"` + strings.ReplaceAll(part, `"`, `\"`) + `"`
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Runner executes external commands and reads the process environment on behalf of
+// an installer. Installers never call os/exec directly, so they can be exercised
+// against a scripted Runner without brew, choco, sudo or uv present.
+type Runner interface {
+	// Run runs the command and waits for it to finish
+	Run(cmd Command) error
+
+	// Output runs the command and returns its standard output
+	Output(cmd Command) ([]byte, error)
+
+	// LookPath searches PATH for an executable
+	LookPath(file string) (string, error)
+
+	// Getenv returns the value of an environment variable
+	Getenv(key string) string
+
+	// Setenv sets an environment variable for this process and the commands it runs
+	Setenv(key, value string) error
+
+	// Stat returns information about a file, like os.Stat
+	Stat(path string) (fs.FileInfo, error)
+
+	// Glob returns the files matching pattern, like filepath.Glob
+	Glob(pattern string) ([]string, error)
+
+	// WriteFile creates or replaces a file, like os.WriteFile
+	WriteFile(path string, data []byte) error
+
+	// MkdirAll creates a directory and any missing parents
+	MkdirAll(path string) error
+
+	// RemoveAll deletes a file or directory and anything it contains
+	RemoveAll(path string) error
+
+	// Download fetches url into destPath, and fails unless it matches the checksum
+	// pinned for url
+	Download(url, destPath string) error
+}
+
+// ExecRunner runs commands on the host with os/exec
+type ExecRunner struct{}
+
+// Run runs the command and waits for it to finish
+func (ExecRunner) Run(c Command) error {
+	return execCommand(c).Run()
+}
+
+// Output runs the command and returns its standard output. Its standard error
+// goes to the run log, and is kept in the *exec.ExitError of a failed command.
+func (ExecRunner) Output(c Command) ([]byte, error) {
+	cmd := execCommand(c)
+	var stdout bytes.Buffer
+	stderr := &cappedBuffer{max: maxStderr}
+	cmd.Stdout = &stdout
+	cmd.Stderr = tee(cmd.Stderr, stderr)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitErr.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+// maxStderr is how much of a command's standard error Output keeps for its error
+const maxStderr = 64 << 10
+
+// cappedBuffer keeps the first max bytes written to it and discards the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// LookPath searches PATH for an executable
+func (ExecRunner) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+// Getenv returns the value of an environment variable
+func (ExecRunner) Getenv(key string) string {
+	return os.Getenv(key)
+}
+
+// Setenv sets an environment variable for this process and the commands it runs
+func (ExecRunner) Setenv(key, value string) error {
+	return os.Setenv(key, value)
+}
+
+// Stat returns information about a file, like os.Stat
+func (ExecRunner) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+// Glob returns the files matching pattern, like filepath.Glob
+func (ExecRunner) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+// WriteFile creates or replaces a file, like os.WriteFile
+func (ExecRunner) WriteFile(path string, data []byte) error {
+	return os.WriteFile(path, data, 0644)
+}
+
+// MkdirAll creates a directory and any missing parents
+func (ExecRunner) MkdirAll(path string) error {
+	return os.MkdirAll(path, 0755)
+}
+
+// RemoveAll deletes a file or directory and anything it contains
+func (ExecRunner) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+// Download fetches url into destPath and checks it against its pinned checksum
+func (ExecRunner) Download(url, destPath string) error {
+	ui.PrintInfo(fmt.Sprintf("Downloading from %s...", url))
+
+	// Resumes and retries interrupted transfers, and honors proxies and HUBBLE_CA_BUNDLE
+	client, err := download.NewClient()
+	if err != nil {
+		return err
+	}
+	if err := fetchVerified(client, url, destPath, pinnedDownloads); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess("Download complete and verified")
+	return nil
+}
+
+// execCommand converts a Command to an *exec.Cmd. Its output is copied to the
+// run log, and also shown when c.Show is set.
+func execCommand(c Command) *exec.Cmd {
+	ui.Redact(c.Redact...)
+	ui.Logf("$ %s", c)
+
+	cmd := exec.Command(c.Path, c.Args...)
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	if c.Stdin {
+		cmd.Stdin = os.Stdin
+	}
+	if c.Show {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+	if log := ui.LogWriter(); log != nil {
+		cmd.Stdout = tee(cmd.Stdout, log)
+		cmd.Stderr = tee(cmd.Stderr, log)
+		// A process the command leaves running must not keep Wait copying its output forever
+		cmd.WaitDelay = 5 * time.Second
+	}
+	return cmd
+}
+
+// tee returns a writer that copies to w, if set, and to log
+func tee(w, log io.Writer) io.Writer {
+	if w == nil {
+		return log
+	}
+	return io.MultiWriter(w, log)
+}
+
+// exitCode returns the exit status of a command that ran and failed
+func exitCode(err error) (int, bool) {
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	return 0, false
+}
+
+// prependPath adds dir to the front of PATH unless it is already there
+func prependPath(r Runner, dir, separator string) {
+	current := r.Getenv("PATH")
+	if !strings.Contains(current, dir) {
+		r.Setenv("PATH", dir+separator+current)
+	}
+}
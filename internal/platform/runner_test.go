@@ -0,0 +1,56 @@
+package platform_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+func TestExecRunnerOutputKeepsStderr(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	logPath := filepath.Join(t.TempDir(), "run.log")
+	if err := ui.OpenLog(logPath); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ui.CloseLog)
+
+	secret := strings.Repeat("ab", 48)
+	cmd := platform.Command{
+		Path:   "sh",
+		Args:   []string{"-c", "echo out; echo \"failed for $0\" >&2; exit 3", secret},
+		Redact: []string{secret},
+	}
+	out, err := platform.ExecRunner{}.Output(cmd)
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("Output() = %v, want exit status 3", err)
+	}
+	if string(out) != "out\n" {
+		t.Errorf("Output() = %q, want the standard output", out)
+	}
+	if want := "failed for " + secret + "\n"; string(exitErr.Stderr) != want {
+		t.Errorf("ExitError.Stderr = %q, want %q", exitErr.Stderr, want)
+	}
+
+	ui.CloseLog()
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(log), "failed for <redacted>") {
+		t.Errorf("standard error missing from the log:\n%s", log)
+	}
+	if strings.Contains(string(log), secret) {
+		t.Errorf("log leaked the secret:\n%s", log)
+	}
+}
@@ -0,0 +1,135 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/download"
+)
+
+// DefaultToolVersion is the pyhubbledemo release boards are flashed with unless
+// --tool-version overrides it. Pinning it keeps provisioning reproducible: every
+// platform and every run of a release uses the same flashing tool.
+const DefaultToolVersion = "0.4.1"
+
+// LatestToolVersion asks for the newest pyhubbledemo release on PyPI
+const LatestToolVersion = "latest"
+
+// toolVersionPattern matches the PEP 440 release versions published to PyPI,
+// e.g. "0.4.1" or "0.5.0rc1"
+var toolVersionPattern = regexp.MustCompile(`^\d+(\.\d+){0,3}((a|b|rc)\d+)?(\.post\d+)?(\.dev\d+)?$`)
+
+// pypiProjectURL describes the pyhubbledemo project, including its newest release
+const pypiProjectURL = "https://pypi.org/pypi/pyhubbledemo/json"
+
+// ValidateToolVersion checks a --tool-version value
+func ValidateToolVersion(version string) error {
+	if version != LatestToolVersion && !toolVersionPattern.MatchString(version) {
+		return fmt.Errorf("invalid tool version %q: use a pyhubbledemo release such as %s, or %q", version, DefaultToolVersion, LatestToolVersion)
+	}
+	return nil
+}
+
+// ResolveToolVersion returns the exact pyhubbledemo release to flash with. A pinned
+// version is returned as-is; "latest" is looked up on PyPI once, so that every
+// board of a run uses the same release.
+func ResolveToolVersion(version string) (string, error) {
+	if err := ValidateToolVersion(version); err != nil {
+		return "", err
+	}
+	if version != LatestToolVersion {
+		return version, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pypiProjectURL, nil)
+	if err != nil {
+		return "", err
+	}
+	// Honors proxies and HUBBLE_CA_BUNDLE, as downloads do
+	client, err := download.NewHTTPClient(os.Getenv(download.CABundleEnv))
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", &NetworkError{Op: "look up the latest pyhubbledemo release", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to look up the latest pyhubbledemo release: bad status: %s", resp.Status)
+	}
+
+	var project struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(&project); err != nil {
+		return "", fmt.Errorf("failed to read the latest pyhubbledemo release: %w", err)
+	}
+	if !toolVersionPattern.MatchString(project.Info.Version) {
+		return "", fmt.Errorf("PyPI reported an unexpected pyhubbledemo version %q", project.Info.Version)
+	}
+	return project.Info.Version, nil
+}
+
+// toolRequirement returns the uv requirement that selects the request's pyhubbledemo release
+func toolRequirement(version string) string {
+	if version == "" {
+		return "pyhubbledemo"
+	}
+	return "pyhubbledemo==" + version
+}
+
+// hubbledemoHelp caches the help of pyhubbledemo's flash command, by release
+var (
+	hubbledemoHelpMu sync.Mutex
+	hubbledemoHelp   = make(map[string]string)
+)
+
+// HubbledemoAccepts reports whether the flash command of pyhubbledemo release
+// version lists option in its help. The installer relies on the options every
+// release has (-o, -t, -n and -f) and passes newer ones, such as --key and
+// --serial, only to releases that accept them.
+func HubbledemoAccepts(r Runner, version, option string) (bool, error) {
+	hubbledemoHelpMu.Lock()
+	defer hubbledemoHelpMu.Unlock()
+
+	help, ok := hubbledemoHelp[version]
+	if !ok {
+		uvPath, err := r.LookPath("uv")
+		if err != nil {
+			return false, &DependencyMissingError{Dependencies: []string{"uv"}, Err: err}
+		}
+		cmd := Command{
+			Path:     uvPath,
+			Args:     []string{"tool", "run", "--from", toolRequirement(version), "hubbledemo", "flash", "--help"},
+			Env:      []string{"PYTHONWARNINGS=ignore"},
+			ReadOnly: true,
+		}
+		out, err := r.Output(cmd)
+		if err != nil {
+			return false, fmt.Errorf("failed to read the options of pyhubbledemo %s: %w", version, err)
+		}
+		help = string(out)
+		hubbledemoHelp[version] = help
+	}
+	return helpListsOption(help, option), nil
+}
+
+// helpListsOption reports whether option appears as an option in help text, e.g.
+// "  --key TEXT  Device key" or "[--serial SERIAL]"
+func helpListsOption(help, option string) bool {
+	return regexp.MustCompile(`(^|[\s\[,])` + regexp.QuoteMeta(option) + `([\s=\],]|$)`).MatchString(help)
+}
@@ -0,0 +1,66 @@
+package platform_test
+
+import (
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+)
+
+// flashHelp is the help of a pyhubbledemo flash command with the newer options
+const flashHelp = `Usage: hubbledemo flash [OPTIONS] BOARD
+
+Options:
+  -o, --org-id TEXT   Organization ID
+  -t, --token TEXT    API token
+  -n, --name TEXT     Device name
+  -f, --file PATH     Write a hex file instead of flashing
+  --key TEXT          Base64 key of an already registered device
+  --serial TEXT       Serial number of the J-Link probe to use
+  --help              Show this message and exit.
+`
+
+func TestHubbledemoAcceptsParsesHelp(t *testing.T) {
+	tests := []struct {
+		version string
+		help    string
+		option  string
+		want    bool
+	}{
+		{"9.8.0", flashHelp, "--keyfile", false},
+		{"9.8.1", flashHelp, "--probe", false},
+		{"9.8.2", "usage: hubbledemo flash [-h] [--serial SERIAL] board", "--serial", true},
+		{"9.8.3", "  --key=KEY  device key", "--key", true},
+	}
+	for _, tt := range tests {
+		runner := platformtest.NewRunner("uv").On(platformtest.Response{Match: "/fake/bin/uv tool run", Output: tt.help})
+		if got, err := platform.HubbledemoAccepts(runner, tt.version, tt.option); got != tt.want || err != nil {
+			t.Errorf("HubbledemoAccepts(%q, %s) = %v, %v, want %v", tt.help, tt.option, got, err, tt.want)
+		}
+	}
+}
+
+func TestHubbledemoAccepts(t *testing.T) {
+	runner := platformtest.NewRunner("uv").
+		On(platformtest.Response{Match: "/fake/bin/uv tool run --from pyhubbledemo==9.9.1 hubbledemo flash --help", Output: flashHelp}).
+		On(platformtest.Response{Match: "/fake/bin/uv tool run --from pyhubbledemo==9.9.0 hubbledemo flash --help", Output: "Options:\n  -o TEXT\n  -t TEXT\n  -n TEXT\n"})
+
+	for _, option := range []string{"--key", "--serial"} {
+		if ok, err := platform.HubbledemoAccepts(runner, "9.9.1", option); !ok || err != nil {
+			t.Errorf("HubbledemoAccepts(9.9.1, %s) = %v, %v, want true", option, ok, err)
+		}
+		if ok, err := platform.HubbledemoAccepts(runner, "9.9.0", option); ok || err != nil {
+			t.Errorf("HubbledemoAccepts(9.9.0, %s) = %v, %v, want false", option, ok, err)
+		}
+	}
+	// Each release's help is read once
+	if calls := len(runner.Calls()); calls != 2 {
+		t.Errorf("ran %d commands, want one per release: %v", calls, runner.Calls())
+	}
+}
+
+func TestHubbledemoAcceptsWithoutUV(t *testing.T) {
+	if ok, err := platform.HubbledemoAccepts(platformtest.NewRunner(), "9.9.2", "--key"); ok || err == nil {
+		t.Errorf("HubbledemoAccepts() without uv = %v, %v, want an error", ok, err)
+	}
+}
@@ -0,0 +1,195 @@
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// probeVendor is a debug probe maker whose USB devices need udev rules on Linux
+type probeVendor struct {
+	Name     string
+	VendorID string
+}
+
+var (
+	seggerProbes = probeVendor{Name: "SEGGER J-Link", VendorID: "1366"}
+	tiProbes     = probeVendor{Name: "TI XDS110", VendorID: "0451"}
+)
+
+// probeVendorsFor returns the probes used to flash boards with the given dependencies
+func probeVendorsFor(deps []string) []probeVendor {
+	var vendors []probeVendor
+	if slices.Contains(deps, "segger-jlink") || slices.Contains(deps, "simplicity-commander") {
+		// Silicon Labs kits carry a J-Link OB as well
+		vendors = append(vendors, seggerProbes)
+	}
+	if slices.Contains(deps, "uniflash") {
+		vendors = append(vendors, tiProbes)
+	}
+	return vendors
+}
+
+// udevRulesDirs are where udev reads rules from, relative to the filesystem root
+var udevRulesDirs = []string{"etc/udev/rules.d", "lib/udev/rules.d", "usr/lib/udev/rules.d"}
+
+// hubbleRulesFile is the rules file the installer writes, relative to the filesystem root
+const hubbleRulesFile = "etc/udev/rules.d/99-hubble-probes.rules"
+
+// probeGroups are the groups that own probe device nodes: plugdev for the USB
+// device itself and dialout for its virtual serial port
+var probeGroups = []string{"plugdev", "dialout"}
+
+// probeAccess is the result of checking that debug probes are usable without root
+type probeAccess struct {
+	MissingRules  []probeVendor // Vendors that no udev rule grants access to
+	MissingGroups []string      // Groups the user must be added to
+	StaleGroups   []string      // Groups the user was added to that this login session does not have yet
+}
+
+// ok reports whether nothing needs to be installed or changed
+func (a probeAccess) ok() bool {
+	return len(a.MissingRules) == 0 && len(a.MissingGroups) == 0
+}
+
+// Account is the user whose probe access is checked
+type Account struct {
+	Name       string
+	UID        int   // Effective user ID; root can open any device
+	ActiveGIDs []int // Groups of the running process, which change only at the next login
+}
+
+// checkProbeAccess checks the udev rules and group membership that let user reach
+// the vendors' probes. root is "/" on a real system; pointing it at a fixture
+// filesystem makes the checks testable.
+func checkProbeAccess(root string, user Account, vendors []probeVendor) (probeAccess, error) {
+	var access probeAccess
+	for _, vendor := range vendors {
+		found, err := hasUdevRule(root, vendor.VendorID)
+		if err != nil {
+			return access, err
+		}
+		if !found {
+			access.MissingRules = append(access.MissingRules, vendor)
+		}
+	}
+	if len(vendors) == 0 {
+		return access, nil
+	}
+
+	groups, err := readGroups(filepath.Join(root, "etc", "group"))
+	if err != nil {
+		return access, err
+	}
+	primaryGID := primaryGroup(filepath.Join(root, "etc", "passwd"), user.Name)
+	for _, name := range probeGroups {
+		group, exists := groups[name]
+		if !exists {
+			// Distributions without the group (e.g. Fedora has no plugdev) grant access through uaccess
+			continue
+		}
+		if !slices.Contains(group.Members, user.Name) && group.GID != primaryGID {
+			access.MissingGroups = append(access.MissingGroups, name)
+		} else if !slices.Contains(user.ActiveGIDs, group.GID) {
+			access.StaleGroups = append(access.StaleGroups, name)
+		}
+	}
+	return access, nil
+}
+
+// udevVendorMatch finds the vendor ID a rule matches on, e.g. ATTRS{idVendor}=="1366"
+var udevVendorMatch = regexp.MustCompile(`ATTRS?\{idVendor\}\s*==\s*"([0-9A-Fa-f]{4})"`)
+
+// hasUdevRule reports whether any installed rules file matches the vendor ID
+func hasUdevRule(root, vendorID string) (bool, error) {
+	for _, dir := range udevRulesDirs {
+		files, err := filepath.Glob(filepath.Join(root, dir, "*.rules"))
+		if err != nil {
+			return false, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			for _, match := range udevVendorMatch.FindAllStringSubmatch(string(data), -1) {
+				if strings.EqualFold(match[1], vendorID) {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// group is an entry of /etc/group
+type group struct {
+	GID     int
+	Members []string
+}
+
+// readGroups parses an /etc/group file into groups by name
+func readGroups(path string) (map[string]group, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read groups: %w", err)
+	}
+	defer file.Close()
+
+	groups := make(map[string]group)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// name:password:GID:member,member
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) != 4 {
+			continue
+		}
+		gid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		var members []string
+		if fields[3] != "" {
+			members = strings.Split(fields[3], ",")
+		}
+		groups[fields[0]] = group{GID: gid, Members: members}
+	}
+	return groups, scanner.Err()
+}
+
+// primaryGroup returns the primary GID of user from an /etc/passwd file, or -1
+func primaryGroup(path, user string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return -1
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// name:password:UID:GID:comment:home:shell
+		fields := strings.Split(line, ":")
+		if len(fields) < 4 || fields[0] != user {
+			continue
+		}
+		if gid, err := strconv.Atoi(fields[3]); err == nil {
+			return gid
+		}
+	}
+	return -1
+}
+
+// udevRules returns the contents of the rules file that grants the vendors' probes
+// to the probe groups, and to whoever is logged in at the console
+func udevRules(vendors []probeVendor) string {
+	var b strings.Builder
+	b.WriteString("# Installed by hubble-install: debug probe access for non-root users\n")
+	for _, vendor := range vendors {
+		fmt.Fprintf(&b, "\n# %s\n", vendor.Name)
+		fmt.Fprintf(&b, "SUBSYSTEM==\"usb\", ATTR{idVendor}==\"%s\", MODE=\"0664\", GROUP=\"plugdev\", TAG+=\"uaccess\"\n", vendor.VendorID)
+		fmt.Fprintf(&b, "SUBSYSTEM==\"tty\", ATTRS{idVendor}==\"%s\", MODE=\"0664\", GROUP=\"dialout\", TAG+=\"uaccess\"\n", vendor.VendorID)
+	}
+	return b.String()
+}
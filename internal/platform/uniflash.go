@@ -0,0 +1,144 @@
+package platform
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// UniFlash is distributed by TI behind a license agreement, so it is never
+// downloaded by the installer; only an existing installation is used
+const uniflashDownloadPage = "https://www.ti.com/tool/UNIFLASH"
+
+// dsliteScript returns the name of UniFlash's command-line flasher on goos
+func dsliteScript(goos string) string {
+	if goos == "windows" {
+		return "dslite.bat"
+	}
+	return "dslite.sh"
+}
+
+// dslitePatterns lists the glob patterns of UniFlash's default install locations
+func dslitePatterns(r Runner, goos string) []string {
+	script := dsliteScript(goos)
+	switch goos {
+	case "windows":
+		return []string{filepath.Join(`C:\ti`, "uniflash_*", script)}
+	case "darwin":
+		return []string{filepath.Join("/Applications/ti", "uniflash_*", script)}
+	default:
+		return []string{
+			filepath.Join(r.Getenv("HOME"), "ti", "uniflash_*", script),
+			filepath.Join("/opt/ti", "uniflash_*", script),
+		}
+	}
+}
+
+// findDSLite locates UniFlash's dslite script, preferring PATH and then the newest
+// UniFlash release in the default install locations
+func findDSLite(r Runner, goos string) (string, error) {
+	if path, err := r.LookPath(dsliteScript(goos)); err == nil {
+		return path, nil
+	}
+	for _, pattern := range dslitePatterns(r, goos) {
+		matches, err := r.Glob(pattern)
+		if err != nil || len(matches) == 0 {
+			continue
+		}
+		// Install directories are named uniflash_<version>; the last sorts newest
+		return matches[len(matches)-1], nil
+	}
+	return "", fmt.Errorf("UniFlash (%s) not found on PATH or in the default install locations", dsliteScript(goos))
+}
+
+// uniflashMissing explains how to install UniFlash and returns the error to report
+func uniflashMissing() error {
+	ui.PrintError("TI UniFlash was not found")
+	ui.PrintInfo("It must be downloaded and installed manually from:")
+	ui.PrintInfo("  " + uniflashDownloadPage)
+	ui.PrintInfo("Without it, a hex file is generated for you to flash instead.")
+	return fmt.Errorf("UniFlash must be installed manually")
+}
+
+// xds110Config is a UniFlash target configuration (.ccxml) for a device behind
+// a LaunchPad's on-board XDS110 debug probe
+var xds110Config = template.Must(template.New("ccxml").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(
+	`<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<configurations XML_version="1.2" id="configurations_0">
+    <configuration XML_version="1.2" id="Texas Instruments XDS110 USB Debug Probe_0">
+        <instance XML_version="1.2" desc="Texas Instruments XDS110 USB Debug Probe_0" href="connections/TIXDS110_Connection.xml" id="Texas Instruments XDS110 USB Debug Probe_0" xml="TIXDS110_Connection.xml" xmlpath="connections"/>
+        <connection XML_version="1.2" id="Texas Instruments XDS110 USB Debug Probe_0">
+            <instance XML_version="1.2" href="drivers/tixds510cs_dap.xml" id="drivers" xml="tixds510cs_dap.xml" xmlpath="drivers"/>
+            <instance XML_version="1.2" href="drivers/tixds510cortexM0.xml" id="drivers" xml="tixds510cortexM0.xml" xmlpath="drivers"/>
+            <instance XML_version="1.2" href="drivers/tixds510sec_ap.xml" id="drivers" xml="tixds510sec_ap.xml" xmlpath="drivers"/>
+{{- if .Serial}}
+            <property Type="choicelist" Value="1" id="Debug Probe Selection">
+                <choice Name="Select by serial number" value="0">
+                    <property Type="stringfield" Value="{{xml .Serial}}" id="-- Enter the serial number"/>
+                </choice>
+            </property>
+{{- end}}
+            <platform XML_version="1.2" id="platform_0">
+                <instance XML_version="1.2" desc="{{xml .Target}}_0" href="devices/{{xml .Target}}.xml" id="{{xml .Target}}_0" xml="{{xml .Target}}.xml" xmlpath="devices"/>
+            </platform>
+        </connection>
+    </configuration>
+</configurations>
+`))
+
+// xmlEscape escapes s for use in an XML attribute
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// flashWithUniflash generates the board's hex file with pyhubbledemo, then programs
+// it with UniFlash's DSLite over the LaunchPad's XDS110 debug probe
+func flashWithUniflash(r Runner, goos, uvPath string, req FlashRequest) (*FlashResult, error) {
+	dslitePath, err := findDSLite(r, goos)
+	if err != nil {
+		return nil, &DependencyMissingError{Dependencies: []string{"uniflash"}, Err: err}
+	}
+	if req.Target == "" {
+		return nil, &FlashFailedError{Board: req.Board, Err: fmt.Errorf("the board catalog gives no target device for UniFlash")}
+	}
+
+	tempDir := filepath.Join(os.TempDir(), "hubble-uniflash-flash")
+	if err := r.MkdirAll(tempDir); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer r.RemoveAll(tempDir) // The hex file holds the device key; do not leave it behind
+
+	hexFilePath := filepath.Join(tempDir, req.Board+".hex")
+	if err := r.Run(hubbledemoCommand(uvPath, req, hexFilePath)); err != nil {
+		return nil, &FlashFailedError{Board: req.Board, Err: err}
+	}
+
+	var config bytes.Buffer
+	if err := xds110Config.Execute(&config, struct{ Target, Serial string }{req.Target, req.ProbeSerial}); err != nil {
+		return nil, fmt.Errorf("failed to build UniFlash configuration: %w", err)
+	}
+	configPath := filepath.Join(tempDir, req.Target+".ccxml")
+	if err := r.WriteFile(configPath, config.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to write UniFlash configuration: %w", err)
+	}
+
+	// -f flashes the image and -v verifies it against the target afterwards
+	cmd := Command{
+		Path: dslitePath,
+		Args: []string{"--mode", "flash", "--config=" + configPath, "-f", "-v", hexFilePath},
+		Show: true,
+	}
+	if err := r.Run(cmd); err != nil {
+		return nil, &FlashFailedError{Board: req.Board, Err: fmt.Errorf("UniFlash flash failed: %w", err)}
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Board %s flashed successfully!", req.Board))
+	return newFlashResult(req, ""), nil
+}
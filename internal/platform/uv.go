@@ -0,0 +1,113 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+
+	"github.com/HubbleNetwork/hubble-install/internal/bundle"
+)
+
+// uvVersion is the uv release installed where no package manager provides uv,
+// and put in offline bundles
+const uvVersion = "0.5.11"
+
+// uvDownloadURL is where astral publishes uv releases, by version, target triple and archive type
+const uvDownloadURL = "https://github.com/astral-sh/uv/releases/download/%s/uv-%s.%s"
+
+// uvReleaseURL returns the URL of the uv release archive for goos and goarch
+func uvReleaseURL(goos, goarch string) (string, error) {
+	arch := map[string]string{"amd64": "x86_64", "arm64": "aarch64"}[goarch]
+	if arch == "" {
+		return "", fmt.Errorf("offline bundles are not supported on %s", goarch)
+	}
+	switch goos {
+	case "windows":
+		return fmt.Sprintf(uvDownloadURL, uvVersion, arch+"-pc-windows-msvc", "zip"), nil
+	case "darwin":
+		return fmt.Sprintf(uvDownloadURL, uvVersion, arch+"-apple-darwin", "tar.gz"), nil
+	default:
+		return fmt.Sprintf(uvDownloadURL, uvVersion, arch+"-unknown-linux-gnu", "tar.gz"), nil
+	}
+}
+
+// UVExecutable returns the file name of the uv executable on goos
+func UVExecutable(goos string) string {
+	if goos == "windows" {
+		return "uv.exe"
+	}
+	return "uv"
+}
+
+// UVInstallDir returns where the uv release is installed: the directory uv's own
+// installer uses
+func UVInstallDir(r Runner, goos string) string {
+	if goos == "windows" {
+		return filepath.Join(r.Getenv("USERPROFILE"), ".local", "bin")
+	}
+	return filepath.Join(r.Getenv("HOME"), ".local", "bin")
+}
+
+// uvUnpackCommand builds the command that unpacks a uv release archive into dir.
+// The zip for Windows holds the executables at its root; the tarballs hold them
+// in a directory named after the target.
+func uvUnpackCommand(goos, archive, dir string) Command {
+	args := []string{"-xzf", archive, "-C", dir, "--strip-components=1"}
+	if goos == "windows" {
+		args = []string{"-xf", archive, "-C", dir}
+	}
+	return Command{Path: "tar", Args: args, Creates: []string{filepath.Join(dir, UVExecutable(goos))}}
+}
+
+// uvFiles lists the executables a uv release puts in dir
+func uvFiles(goos, dir string) []string {
+	names := []string{"uv", "uvx"}
+	if goos == "windows" {
+		names = []string{"uv.exe", "uvx.exe", "uvw.exe"}
+	}
+	files := make([]string, len(names))
+	for i, name := range names {
+		files[i] = filepath.Join(dir, name)
+	}
+	return files
+}
+
+// installUVRelease downloads the pinned uv release, or takes it from the offline
+// bundle b, unpacks it into the user's ~/.local/bin and puts it on PATH. The
+// executables it adds are recorded in changes.
+func installUVRelease(r Runner, goos string, b *bundle.Bundle, changes *changeLog) error {
+	url, err := uvReleaseURL(goos, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+
+	tempDir := filepath.Join(os.TempDir(), "hubble-uv-install")
+	if err := r.MkdirAll(tempDir); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer r.RemoveAll(tempDir) // Clean up after installation
+
+	archive := filepath.Join(tempDir, path.Base(url))
+	if err := fetchInstaller(r, b, url, archive); err != nil {
+		return err
+	}
+
+	binDir := UVInstallDir(r, goos)
+	if err := r.MkdirAll(binDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", binDir, err)
+	}
+	cmd := uvUnpackCommand(goos, archive, binDir)
+	cmd.Installs = []string{"uv"}
+	if err := changes.recordNewFiles(r, "uv", uvFiles(goos, binDir), func() error { return r.Run(cmd) }); err != nil {
+		return fmt.Errorf("failed to unpack uv: %w", err)
+	}
+
+	separator := ":"
+	if goos == "windows" {
+		separator = ";"
+	}
+	prependPath(r, binDir, separator)
+	return nil
+}
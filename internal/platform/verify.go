@@ -0,0 +1,145 @@
+package platform
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+
+	"github.com/HubbleNetwork/hubble-install/internal/download"
+)
+
+// downloadsJSON pins the content of every file the installer downloads
+//
+//go:embed downloads.json
+var downloadsJSON []byte
+
+// DownloadManifest is the layout of downloads.json
+type DownloadManifest struct {
+	Description string        `json:"description"`
+	Downloads   []DownloadPin `json:"downloads"`
+}
+
+// DownloadPin is the expected content of a file downloaded from URL
+type DownloadPin struct {
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`              // Lowercase hex SHA-256 of the file; empty until pinned
+	Signature string `json:"signature,omitempty"` // Base64 Ed25519 signature of the file by SigningKey, if signed
+}
+
+// DownloadPins are pins by URL
+type DownloadPins map[string]DownloadPin
+
+// sha256Pattern matches a lowercase hex SHA-256
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ParseDownloadPins reads a download manifest
+func ParseDownloadPins(data []byte) (DownloadPins, error) {
+	var manifest DownloadManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid download manifest: %w", err)
+	}
+	pins := make(DownloadPins, len(manifest.Downloads))
+	for _, pin := range manifest.Downloads {
+		if pin.SHA256 != "" && !sha256Pattern.MatchString(pin.SHA256) {
+			return nil, fmt.Errorf("invalid download manifest: %s: %q is not a SHA-256", pin.URL, pin.SHA256)
+		}
+		if _, dup := pins[pin.URL]; dup {
+			return nil, fmt.Errorf("invalid download manifest: %s is listed twice", pin.URL)
+		}
+		pins[pin.URL] = pin
+	}
+	return pins, nil
+}
+
+// pinnedDownloads are the pins embedded in the installer
+var pinnedDownloads = func() DownloadPins {
+	pins, err := ParseDownloadPins(downloadsJSON)
+	if err != nil {
+		panic(err)
+	}
+	return pins
+}()
+
+// SigningKey is the base64 Ed25519 public key that checks the signatures of signed
+// downloads. Release builds may set it with
+// -ldflags "-X github.com/HubbleNetwork/hubble-install/internal/platform.SigningKey=<key>".
+var SigningKey = ""
+
+// Verify checks the file at path, downloaded from url, against the pin for url.
+// A download without a pin is refused like one that does not match its pin.
+func (p DownloadPins) Verify(url, path string) error {
+	pin, ok := p[url]
+	if !ok || pin.SHA256 == "" {
+		return &UnverifiedDownloadError{URL: url, Reason: "no checksum is pinned for it in this build of the installer"}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read download: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != pin.SHA256 {
+		return &UnverifiedDownloadError{URL: url, Reason: fmt.Sprintf("its SHA-256 is %s, but %s is pinned; it was corrupted in transit or changed by the server", got, pin.SHA256)}
+	}
+
+	if pin.Signature != "" {
+		if err := verifySignature(data, pin.Signature); err != nil {
+			return &UnverifiedDownloadError{URL: url, Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// verifySignature checks a base64 Ed25519 signature of data against SigningKey
+func verifySignature(data []byte, signature string) error {
+	key, err := base64.StdEncoding.DecodeString(SigningKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("it is signed, but this build of the installer has no valid signing key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return fmt.Errorf("its signature is invalid")
+	}
+	return nil
+}
+
+// fetchVerified downloads url into destPath with d and checks it against pins.
+// A download that fails the check is deleted, so it can never be run.
+func fetchVerified(d *download.Client, url, destPath string, pins DownloadPins) error {
+	if err := d.Fetch(context.Background(), url, destPath); err != nil {
+		var status *download.StatusError
+		var pathErr *fs.PathError
+		if errors.As(err, &status) || errors.As(err, &pathErr) {
+			return err
+		}
+		return &NetworkError{Op: "download " + url, Err: err}
+	}
+
+	if err := pins.Verify(url, destPath); err != nil {
+		os.Remove(destPath)
+		return err
+	}
+	return nil
+}
+
+// describePin describes how a download would be checked, for dry runs
+func describePin(url string) string {
+	pin, ok := pinnedDownloads[url]
+	if !ok || pin.SHA256 == "" {
+		return "no checksum pinned, so a real run refuses it"
+	}
+	checks := "SHA-256 " + pin.SHA256[:12] + "…"
+	if pin.Signature != "" {
+		checks += " and signature"
+	}
+	return "checked against " + checks
+}
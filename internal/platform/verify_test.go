@@ -0,0 +1,113 @@
+package platform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/download"
+)
+
+// testDownloadClient returns a download client that gives up after one attempt
+func testDownloadClient() *download.Client {
+	return &download.Client{HTTPClient: http.DefaultClient, Attempts: 1, StallTimeout: download.DefaultStallTimeout}
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFetchVerified(t *testing.T) {
+	payload := []byte("#!/bin/sh\necho installing\n")
+	tampered := []byte("#!/bin/sh\necho pwned\n")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/good.sh", func(w http.ResponseWriter, r *http.Request) { w.Write(payload) })
+	mux.HandleFunc("/tampered.sh", func(w http.ResponseWriter, r *http.Request) { w.Write(tampered) })
+	mux.HandleFunc("/unpinned.sh", func(w http.ResponseWriter, r *http.Request) { w.Write(payload) })
+	mux.HandleFunc("/empty-pin.sh", func(w http.ResponseWriter, r *http.Request) { w.Write(payload) })
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	pins := DownloadPins{
+		server.URL + "/good.sh":      {URL: server.URL + "/good.sh", SHA256: sha256Hex(payload)},
+		server.URL + "/tampered.sh":  {URL: server.URL + "/tampered.sh", SHA256: sha256Hex(payload)},
+		server.URL + "/empty-pin.sh": {URL: server.URL + "/empty-pin.sh"},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		verified bool
+	}{
+		{"matching checksum", "/good.sh", true},
+		{"tampered payload", "/tampered.sh", false},
+		{"no pin", "/unpinned.sh", false},
+		{"empty pin", "/empty-pin.sh", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "install.sh")
+			err := fetchVerified(testDownloadClient(), server.URL+tt.path, dest, pins)
+
+			if tt.verified {
+				if err != nil {
+					t.Fatalf("fetchVerified() = %v, want nil", err)
+				}
+				if got, _ := os.ReadFile(dest); string(got) != string(payload) {
+					t.Errorf("downloaded %q, want %q", got, payload)
+				}
+				return
+			}
+
+			var unverified *UnverifiedDownloadError
+			if !errors.As(err, &unverified) {
+				t.Fatalf("fetchVerified() = %v, want an UnverifiedDownloadError", err)
+			}
+			if _, err := os.Stat(dest); !os.IsNotExist(err) {
+				t.Errorf("refused download was left at %s", dest)
+			}
+		})
+	}
+}
+
+func TestParseDownloadPinsRejectsInvalidChecksum(t *testing.T) {
+	data := []byte(`{"downloads": [{"url": "https://example.com/a", "sha256": "not-a-checksum"}]}`)
+	if _, err := ParseDownloadPins(data); err == nil {
+		t.Fatal("ParseDownloadPins() accepted an invalid SHA-256")
+	}
+}
+
+func TestEmbeddedDownloadsUseFixedURLs(t *testing.T) {
+	// A pin only holds for a URL whose content never changes
+	for url := range pinnedDownloads {
+		for _, moving := range []string{"/HEAD/", "/main/", "/master/", "/latest/", "/install.sh", "/install.ps1"} {
+			if strings.Contains(url, moving) {
+				t.Errorf("%s does not name a fixed release, so its checksum cannot stay pinned", url)
+			}
+		}
+	}
+}
+
+func TestEmbeddedDownloadsArePinned(t *testing.T) {
+	// Verify refuses an unpinned download, so an installer built with one cannot install it
+	var unpinned []string
+	for url, pin := range pinnedDownloads {
+		if pin.SHA256 == "" {
+			unpinned = append(unpinned, url)
+		}
+	}
+	if len(unpinned) > 0 {
+		slices.Sort(unpinned)
+		t.Errorf("no checksum is pinned for %d downloads, which every install would refuse; run 'make pin-downloads' on a machine with network access and commit downloads.json:\n  %s",
+			len(unpinned), strings.Join(unpinned, "\n  "))
+	}
+}
@@ -2,32 +2,34 @@ package platform
 
 import (
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/bundle"
 	"github.com/HubbleNetwork/hubble-install/internal/ui"
 )
 
-// WindowsInstaller implements the Installer interface for Windows
-type WindowsInstaller struct{}
+// chocolateyVersion is the Chocolatey release the installer sets up
+const chocolateyVersion = "2.4.1"
 
-// RebootRequiredError is returned when a system reboot is required
-type RebootRequiredError struct {
-	Message string
-}
+// chocolateyPackageURL is the package of a Chocolatey release, which, unlike
+// install.ps1, never changes once published, so its checksum can be pinned
+const chocolateyPackageURL = "https://community.chocolatey.org/api/v2/package/chocolatey/%s"
 
-func (e *RebootRequiredError) Error() string {
-	return e.Message
+// WindowsInstaller implements the Installer interface for Windows
+type WindowsInstaller struct {
+	runner  Runner
+	bundle  *bundle.Bundle // Offline bundle to install from instead of downloading, if any
+	changes changeLog      // What this installer added to the system
 }
 
-// NewWindowsInstaller creates a new Windows installer
-func NewWindowsInstaller() *WindowsInstaller {
-	return &WindowsInstaller{}
+// NewWindowsInstaller creates a new Windows installer that runs commands through runner
+func NewWindowsInstaller(runner Runner) *WindowsInstaller {
+	return &WindowsInstaller{runner: runner}
 }
 
 // Name returns the platform name
@@ -35,6 +37,11 @@ func (w *WindowsInstaller) Name() string {
 	return "Windows"
 }
 
+// TakeChanges returns what the installer added to the system since the last call
+func (w *WindowsInstaller) TakeChanges() []Change {
+	return w.changes.take()
+}
+
 // CheckPendingReboot checks if Windows has a pending reboot
 func (w *WindowsInstaller) CheckPendingReboot() error {
 	// Use PowerShell to check for pending reboot indicators
@@ -77,8 +84,7 @@ func (w *WindowsInstaller) CheckPendingReboot() error {
 		}
 	`
 
-	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", psScript)
-	output, err := cmd.Output()
+	output, err := w.runner.Output(Command{Path: "powershell", Args: []string{"-NoProfile", "-NonInteractive", "-Command", psScript}, ReadOnly: true})
 	if err != nil {
 		// If PowerShell fails, assume no reboot is pending
 		// This prevents blocking installation if PowerShell has issues
@@ -99,8 +105,7 @@ func (w *WindowsInstaller) CheckPendingReboot() error {
 // ensureAdminAccess checks if running with administrator privileges
 func (w *WindowsInstaller) ensureAdminAccess() error {
 	// Check if we have admin rights by trying to access a protected registry key
-	cmd := exec.Command("net", "session")
-	if err := cmd.Run(); err != nil {
+	if err := w.runner.Run(Command{Path: "net", Args: []string{"session"}, ReadOnly: true}); err != nil {
 		ui.PrintError("Administrator access required")
 		ui.PrintInfo("Please run this installer as Administrator:")
 		ui.PrintInfo("  Right-click the executable and select 'Run as administrator'")
@@ -113,8 +118,8 @@ func (w *WindowsInstaller) ensureAdminAccess() error {
 func (w *WindowsInstaller) CheckPrerequisites(requiredDeps []string) ([]MissingDependency, error) {
 	var missing []MissingDependency
 
-	// Check for Chocolatey (always required for installing other deps)
-	if !w.commandExists("choco") {
+	// Check for Chocolatey (required for installing other deps, except from an offline bundle)
+	if !w.commandExists("choco") && w.bundle == nil {
 		missing = append(missing, MissingDependency{
 			Name:   "Chocolatey",
 			Status: "Not installed",
@@ -131,48 +136,37 @@ func (w *WindowsInstaller) CheckPrerequisites(requiredDeps []string) ([]MissingD
 					Status: "Not installed",
 				})
 			}
+		case "segger-jlink":
+			if !hasJLink(w.runner, "windows") {
+				missing = append(missing, MissingDependency{
+					Name:   "segger-jlink",
+					Status: "Not installed",
+				})
+			}
+		case "simplicity-commander":
+			if _, err := findCommander(w.runner, "windows"); err != nil {
+				missing = append(missing, MissingDependency{
+					Name:   "simplicity-commander",
+					Status: "Not installed",
+				})
+			}
+		case "uniflash":
+			if _, err := findDSLite(w.runner, "windows"); err != nil {
+				missing = append(missing, MissingDependency{
+					Name:   "uniflash",
+					Status: "Not installed (manual download required)",
+				})
+			}
 		}
 	}
 
 	return missing, nil
 }
 
-// downloadFile downloads a file from a URL to a destination path with progress indication
-func (w *WindowsInstaller) downloadFile(url, destPath string) error {
-	ui.PrintInfo(fmt.Sprintf("Downloading from %s...", url))
-
-	// Create the file
-	out, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer out.Close()
-
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Minute,
-	}
-
-	// Get the data
-	resp, err := client.Get(url)
-	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check server response
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
-	}
-
-	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to save file: %w", err)
-	}
-
-	ui.PrintSuccess("Download complete")
-	return nil
+// windowsJLinkPaths are where SEGGER's installer puts J-Link Commander
+var windowsJLinkPaths = []string{
+	`C:\Program Files\SEGGER\JLink\JLink.exe`,
+	`C:\Program Files (x86)\SEGGER\JLink\JLink.exe`,
 }
 
 // installJLinkFromSEGGER downloads and installs J-Link from SEGGER's official installer
@@ -180,22 +174,19 @@ func (w *WindowsInstaller) installJLinkFromSEGGER() error {
 	ui.PrintInfo("Installing SEGGER J-Link from official installer...")
 	ui.PrintInfo("This may take a few minutes...")
 
-	// Use a recent stable version
-	// Format: https://www.segger.com/downloads/jlink/JLink_Windows_V794l.exe
-	jlinkVersion := "V794l" // Update this periodically
-	jlinkURL := fmt.Sprintf("https://www.segger.com/downloads/jlink/JLink_Windows_%s.exe", jlinkVersion)
+	jlinkURL := jlinkDownloadURL("windows", runtime.GOARCH, "")
 
 	// Create temp directory for download
 	tempDir := filepath.Join(os.TempDir(), "hubble-jlink-install")
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
+	if err := w.runner.MkdirAll(tempDir); err != nil {
 		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	defer os.RemoveAll(tempDir) // Clean up after installation
+	defer w.runner.RemoveAll(tempDir) // Clean up after installation
 
 	installerPath := filepath.Join(tempDir, "JLink_Installer.exe")
 
 	// Download the installer
-	if err := w.downloadFile(jlinkURL, installerPath); err != nil {
+	if err := fetchInstaller(w.runner, w.bundle, jlinkURL, installerPath); err != nil {
 		ui.PrintWarning("Failed to download J-Link installer automatically")
 		ui.PrintInfo("You can download it manually from: https://www.segger.com/downloads/jlink/")
 		return fmt.Errorf("download failed: %w", err)
@@ -208,15 +199,10 @@ func (w *WindowsInstaller) installJLinkFromSEGGER() error {
 	ui.PrintInfo("Accepting SEGGER license agreement automatically...")
 
 	// Method 1: NSIS-style with license acceptance
-	cmd := exec.Command(installerPath, "/S", "/ACCEPTLICENSE=yes")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
+	if err := w.runner.Run(Command{Path: installerPath, Args: []string{"/S", "/ACCEPTLICENSE=yes"}, Show: true}); err != nil {
 		// Method 1 failed, try Method 2: Alternative flags
 		ui.PrintWarning("First installation method failed, trying alternative...")
-		cmd = exec.Command(installerPath, "/q", "/norestart", "ACCEPTLICENSE=yes")
-		if err2 := cmd.Run(); err2 != nil {
+		if err2 := w.runner.Run(Command{Path: installerPath, Args: []string{"/q", "/norestart", "ACCEPTLICENSE=yes"}}); err2 != nil {
 			// Both methods failed
 			ui.PrintError("Silent installation failed")
 			ui.PrintInfo("The installer may require manual intervention")
@@ -229,32 +215,23 @@ func (w *WindowsInstaller) installJLinkFromSEGGER() error {
 	// NSIS installers can spawn child processes
 	ui.PrintInfo("Verifying installation...")
 
-	jlinkPaths := []string{
-		`C:\Program Files\SEGGER\JLink\JLink.exe`,
-		`C:\Program Files (x86)\SEGGER\JLink\JLink.exe`,
-	}
-
 	// Poll for up to 60 seconds for the installation to complete
 	maxWaitTime := 60 * time.Second
 	checkInterval := 2 * time.Second
 	elapsed := time.Duration(0)
-	installed := false
+	installed := ""
 
 	for elapsed < maxWaitTime {
-		for _, path := range jlinkPaths {
-			if _, err := os.Stat(path); err == nil {
-				installed = true
+		for _, path := range windowsJLinkPaths {
+			if _, err := w.runner.Stat(path); err == nil {
+				installed = path
 				// Add to PATH for current process
-				jlinkDir := filepath.Dir(path)
-				currentPath := os.Getenv("PATH")
-				if !strings.Contains(currentPath, jlinkDir) {
-					os.Setenv("PATH", jlinkDir+";"+currentPath)
-				}
+				prependPath(w.runner, filepath.Dir(path), ";")
 				break
 			}
 		}
 
-		if installed {
+		if installed != "" {
 			break
 		}
 
@@ -262,16 +239,28 @@ func (w *WindowsInstaller) installJLinkFromSEGGER() error {
 		elapsed += checkInterval
 	}
 
-	if !installed {
+	if installed == "" {
 		return fmt.Errorf("J-Link installation completed but JLink.exe not found in expected locations after %v", maxWaitTime)
 	}
 
+	// SEGGER's installer leaves its uninstaller next to JLink.exe
+	uninstaller := filepath.Join(filepath.Dir(installed), "Uninstall.exe")
+	if _, err := w.runner.Stat(uninstaller); err == nil {
+		w.changes.record(Change{Kind: ChangeUninstaller, Name: "segger-jlink", Path: uninstaller, Root: true})
+	} else {
+		ui.PrintWarning("SEGGER's uninstaller was not found; 'hubble-install uninstall' will not remove J-Link")
+	}
+
 	ui.PrintSuccess("SEGGER J-Link installed successfully")
 	return nil
 }
 
 // InstallPackageManager installs Chocolatey if not present
 func (w *WindowsInstaller) InstallPackageManager() error {
+	if w.bundle != nil {
+		ui.PrintInfo("Chocolatey is not needed to install from the offline bundle")
+		return nil
+	}
 	if w.commandExists("choco") {
 		ui.PrintSuccess("Chocolatey already installed")
 		return nil
@@ -285,16 +274,33 @@ func (w *WindowsInstaller) InstallPackageManager() error {
 	ui.PrintInfo("Installing Chocolatey...")
 	ui.PrintInfo("This may take a few minutes...")
 
-	// Run the official Chocolatey installation script
-	// Using PowerShell with execution policy bypass for the installation
-	installScript := `Set-ExecutionPolicy Bypass -Scope Process -Force; [System.Net.ServicePointManager]::SecurityProtocol = [System.Net.ServicePointManager]::SecurityProtocol -bor 3072; iex ((New-Object System.Net.WebClient).DownloadString('https://community.chocolatey.org/install.ps1'))`
+	// Download the Chocolatey release package, which is only installed once it
+	// matches its pinned checksum
+	tempDir := filepath.Join(os.TempDir(), "hubble-chocolatey-install")
+	if err := w.runner.MkdirAll(tempDir); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer w.runner.RemoveAll(tempDir) // Clean up after installation
 
-	cmd := exec.Command("powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-Command", installScript)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// A .nupkg is a zip archive; Expand-Archive only accepts the .zip extension
+	packagePath := filepath.Join(tempDir, "chocolatey.zip")
+	if err := w.runner.Download(fmt.Sprintf(chocolateyPackageURL, chocolateyVersion), packagePath); err != nil {
+		return fmt.Errorf("failed to download the Chocolatey installer: %w", err)
+	}
 
-	if err := cmd.Run(); err != nil {
+	// Unpack it and run its install script, as install.ps1 does after downloading it
+	packageDir := filepath.Join(tempDir, "chocolatey")
+	script := fmt.Sprintf("Expand-Archive -Path '%s' -DestinationPath '%s' -Force; & '%s'",
+		packagePath, packageDir, filepath.Join(packageDir, "tools", "chocolateyInstall.ps1"))
+	cmd := Command{
+		Path:     "powershell",
+		Args:     []string{"-NoProfile", "-ExecutionPolicy", "Bypass", "-Command", script},
+		Stdin:    true,
+		Show:     true,
+		Installs: []string{"choco"},
+		Creates:  []string{filepath.Join(w.chocolateyRoot(), "bin")},
+	}
+	if err := w.runner.Run(cmd); err != nil {
 		return fmt.Errorf("failed to install Chocolatey: %w", err)
 	}
 
@@ -309,11 +315,11 @@ func (w *WindowsInstaller) InstallPackageManager() error {
 	}
 
 	// Test choco with a simple command to ensure it's functional
-	testCmd := exec.Command("choco", "--version")
-	if err := testCmd.Run(); err != nil {
+	if err := w.runner.Run(Command{Path: "choco", Args: []string{"--version"}, ReadOnly: true}); err != nil {
 		return fmt.Errorf("chocolatey installed but not functioning correctly: %w", err)
 	}
 
+	w.changes.record(Change{Kind: ChangePackageManager, Name: "Chocolatey", Path: w.chocolateyRoot(), Root: true})
 	ui.PrintSuccess("Chocolatey installed successfully")
 	return nil
 }
@@ -321,7 +327,7 @@ func (w *WindowsInstaller) InstallPackageManager() error {
 // InstallDependencies installs the specified dependencies
 func (w *WindowsInstaller) InstallDependencies(deps []string) error {
 	// First ensure Chocolatey is installed
-	if !w.commandExists("choco") {
+	if !w.commandExists("choco") && w.bundle == nil {
 		if err := w.InstallPackageManager(); err != nil {
 			return err
 		}
@@ -339,6 +345,12 @@ func (w *WindowsInstaller) InstallDependencies(deps []string) error {
 			// Install uv via Chocolatey
 			if w.commandExists("uv") {
 				ui.PrintSuccess("uv already installed")
+			} else if w.bundle != nil {
+				ui.PrintInfo("Installing uv from the offline bundle...")
+				if err := installUVRelease(w.runner, "windows", w.bundle, &w.changes); err != nil {
+					return fmt.Errorf("failed to install uv: %w", err)
+				}
+				ui.PrintSuccess("uv installed successfully")
 			} else {
 				ui.PrintInfo("Installing uv...")
 				if err := w.runChocoInstall("uv", true); err != nil {
@@ -351,15 +363,38 @@ func (w *WindowsInstaller) InstallDependencies(deps []string) error {
 				ui.PrintSuccess("uv installed successfully")
 			}
 
+		case "segger-jlink":
+			if hasJLink(w.runner, "windows") {
+				ui.PrintSuccess("segger-jlink already installed")
+				continue
+			}
+			if err := w.installJLinkFromSEGGER(); err != nil {
+				return fmt.Errorf("failed to install segger-jlink: %w", err)
+			}
+		case "simplicity-commander":
+			if _, err := findCommander(w.runner, "windows"); err == nil {
+				ui.PrintSuccess("simplicity-commander already installed")
+				continue
+			}
+			ui.PrintInfo("Installing Simplicity Commander from Silicon Labs...")
+			if err := installCommander(w.runner, "windows", w.bundle, &w.changes); err != nil {
+				return fmt.Errorf("failed to install simplicity-commander: %w", err)
+			}
+			ui.PrintSuccess("simplicity-commander installed successfully")
+		case "uniflash":
+			if _, err := findDSLite(w.runner, "windows"); err != nil {
+				return uniflashMissing()
+			}
+			ui.PrintSuccess("uniflash already installed")
 		}
 	}
 
 	return nil
 }
 
-// FlashBoard flashes the specified board using uvx (for J-Link boards)
-func (w *WindowsInstaller) FlashBoard(orgID, apiToken, board, deviceName string) (*FlashResult, error) {
-	ui.PrintInfo(fmt.Sprintf("Flashing board: %s", board))
+// FlashBoard flashes the specified board using uvx, through the vendor tool of its flash method
+func (w *WindowsInstaller) FlashBoard(req FlashRequest) (*FlashResult, error) {
+	ui.PrintInfo(fmt.Sprintf("Flashing board: %s", req.Board))
 	ui.PrintInfo("This may take 10-15 seconds...")
 
 	// Try to find uv executable
@@ -379,21 +414,18 @@ func (w *WindowsInstaller) FlashBoard(orgID, apiToken, board, deviceName string)
 		fmt.Println()
 		ui.PrintInfo("If that doesn't work, try rebooting your computer and running again.")
 		fmt.Println()
-		return nil, fmt.Errorf("uv executable not found: %w", err)
+		return nil, &DependencyMissingError{Dependencies: []string{"uv"}, Err: err}
 	}
 
-	// Build the command with --refresh to prevent stale versions
-	args := []string{"tool", "run", "--refresh", "--from", "pyhubbledemo", "hubbledemo", "flash", board, "-o", orgID, "-t", apiToken}
-	if deviceName != "" {
-		args = append(args, "-n", deviceName)
+	switch req.FlashMethod {
+	case boards.FlashMethodCommander:
+		return flashWithCommander(w.runner, "windows", uvPath, req)
+	case boards.FlashMethodUniflash:
+		return flashWithUniflash(w.runner, "windows", uvPath, req)
 	}
-	cmd := exec.Command(uvPath, args...)
-
-	cmd.Env = append(os.Environ(), "PYTHONWARNINGS=ignore")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
 
-	if err := cmd.Run(); err != nil {
+	// Run pyhubbledemo's flash command
+	if err := w.runner.Run(hubbledemoCommand(uvPath, req, "")); err != nil {
 		// Check if this is a network-related error
 		errStr := err.Error()
 		if strings.Contains(errStr, "dns error") ||
@@ -420,22 +452,18 @@ func (w *WindowsInstaller) FlashBoard(orgID, apiToken, board, deviceName string)
 			ui.PrintInfo("  4. Temporarily disable antivirus/firewall and try again")
 			ui.PrintInfo("  5. Try again in a few minutes (GitHub may be temporarily unavailable)")
 			fmt.Println()
+			return nil, &NetworkError{Op: "download the flashing tool", Err: err}
 		}
-		return nil, fmt.Errorf("flash command failed: %w", err)
-	}
-
-	resultDeviceName := deviceName
-	if resultDeviceName == "" {
-		resultDeviceName = "your-device"
+		return nil, &FlashFailedError{Board: req.Board, Err: err}
 	}
 
-	ui.PrintSuccess(fmt.Sprintf("Board %s flashed successfully!", board))
-	return &FlashResult{DeviceName: resultDeviceName}, nil
+	ui.PrintSuccess(fmt.Sprintf("Board %s flashed successfully!", req.Board))
+	return newFlashResult(req, ""), nil
 }
 
 // GenerateHexFile generates a hex file for Uniflash boards (TI)
-func (w *WindowsInstaller) GenerateHexFile(orgID, apiToken, board, deviceName string) (*FlashResult, error) {
-	ui.PrintInfo(fmt.Sprintf("Generating hex file for board: %s", board))
+func (w *WindowsInstaller) GenerateHexFile(req FlashRequest) (*FlashResult, error) {
+	ui.PrintInfo(fmt.Sprintf("Generating hex file for board: %s", req.Board))
 	ui.PrintInfo("This may take a few seconds...")
 
 	// Try to find uv executable
@@ -455,7 +483,7 @@ func (w *WindowsInstaller) GenerateHexFile(orgID, apiToken, board, deviceName st
 		fmt.Println()
 		ui.PrintInfo("If that doesn't work, try rebooting your computer and running again.")
 		fmt.Println()
-		return nil, fmt.Errorf("uv executable not found: %w", err)
+		return nil, &DependencyMissingError{Dependencies: []string{"uv"}, Err: err}
 	}
 
 	// Determine hex file path in current working directory
@@ -465,24 +493,14 @@ func (w *WindowsInstaller) GenerateHexFile(orgID, apiToken, board, deviceName st
 	}
 
 	// Use device name for filename if provided, otherwise use board name
-	filename := board + ".hex"
-	if deviceName != "" {
-		filename = deviceName + ".hex"
+	filename := req.Board + ".hex"
+	if req.DeviceName != "" {
+		filename = req.DeviceName + ".hex"
 	}
 	hexFilePath := filepath.Join(currentDir, filename)
 
-	// Build the command with --refresh to prevent stale versions and -f for output file
-	args := []string{"tool", "run", "--refresh", "--from", "pyhubbledemo", "hubbledemo", "flash", board, "-o", orgID, "-t", apiToken, "-f", hexFilePath}
-	if deviceName != "" {
-		args = append(args, "-n", deviceName)
-	}
-	cmd := exec.Command(uvPath, args...)
-
-	cmd.Env = append(os.Environ(), "PYTHONWARNINGS=ignore")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
+	// Run pyhubbledemo with -f for output file
+	if err := w.runner.Run(hubbledemoCommand(uvPath, req, hexFilePath)); err != nil {
 		// Check if this is a network-related error
 		errStr := err.Error()
 		if strings.Contains(errStr, "dns error") ||
@@ -510,117 +528,100 @@ func (w *WindowsInstaller) GenerateHexFile(orgID, apiToken, board, deviceName st
 			ui.PrintInfo("  5. Try again in a few minutes (GitHub may be temporarily unavailable)")
 			fmt.Println()
 		}
-		return nil, fmt.Errorf("command failed: %w", err)
+		return nil, &FlashFailedError{Board: req.Board, Err: err}
 	}
 
 	// Verify the hex file was created at the expected location
-	if _, err := os.Stat(hexFilePath); err != nil {
+	if _, err := w.runner.Stat(hexFilePath); err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("hex file was not created at expected location: %s\n"+
+			return nil, &FlashFailedError{Board: req.Board, Err: fmt.Errorf("hex file was not created at expected location: %s\n"+
 				"The pyhubbledemo tool may not support the -f flag properly.\n"+
-				"Please check if a hex file was created in a temporary location", hexFilePath)
+				"Please check if a hex file was created in a temporary location", hexFilePath)}
 		}
 		return nil, fmt.Errorf("error checking hex file: %w", err)
 	}
 
-	return &FlashResult{HexFilePath: hexFilePath}, nil
+	return newFlashResult(req, hexFilePath), nil
 }
 
 // Helper functions
 
 // commandExists checks if a command is available in PATH
 func (w *WindowsInstaller) commandExists(cmd string) bool {
-	_, err := exec.LookPath(cmd)
+	_, err := w.runner.LookPath(cmd)
 	return err == nil
 }
 
+// chocolateyRoot returns the Chocolatey install directory
+func (w *WindowsInstaller) chocolateyRoot() string {
+	if root := w.runner.Getenv("ChocolateyInstall"); root != "" {
+		return root
+	}
+	// Fall back to default location
+	return `C:\ProgramData\chocolatey`
+}
+
 // setupChocoPath adds Chocolatey to PATH for the current process
 func (w *WindowsInstaller) setupChocoPath() error {
-	// Get Chocolatey install path from environment variable
-	chocoInstall := os.Getenv("ChocolateyInstall")
-	if chocoInstall == "" {
-		// Fall back to default location
-		chocoInstall = `C:\ProgramData\chocolatey`
-	}
+	chocoInstall := w.chocolateyRoot()
 
 	chocoPath := filepath.Join(chocoInstall, "bin")
 
-	if _, err := os.Stat(chocoPath); os.IsNotExist(err) {
+	if _, err := w.runner.Stat(chocoPath); os.IsNotExist(err) {
 		return fmt.Errorf("choco not found in expected location: %s", chocoPath)
 	}
 
 	// Update PATH for this process
-	currentPath := os.Getenv("PATH")
-	if !strings.Contains(currentPath, chocoPath) {
-		newPath := chocoPath + ";" + currentPath
-		os.Setenv("PATH", newPath)
-	}
-
+	prependPath(w.runner, chocoPath, ";")
 	return nil
 }
 
 // runChocoInstall runs a choco install command using the full path to choco.exe
 func (w *WindowsInstaller) runChocoInstall(pkg string, showOutput bool) error {
-	// Get Chocolatey install path from environment variable
-	chocoInstall := os.Getenv("ChocolateyInstall")
-	if chocoInstall == "" {
-		chocoInstall = `C:\ProgramData\chocolatey`
-	}
+	chocoInstall := w.chocolateyRoot()
 
 	// Use full path to avoid PATH lookup issues after fresh Chocolatey install
 	chocoExe := filepath.Join(chocoInstall, "bin", "choco.exe")
 
-	cmd := exec.Command(chocoExe, "install", pkg, "-y")
-
-	// Show output if requested
-	if showOutput {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-	}
-
-	err := cmd.Run()
+	err := w.runner.Run(Command{Path: chocoExe, Args: []string{"install", pkg, "-y"}, Show: showOutput, Installs: []string{executableFor(pkg)}})
 	if err != nil {
 		// Exit code 3010 means "success, but reboot required"
 		// This is a special case that requires user action
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			if exitErr.ExitCode() == 3010 {
-				return &RebootRequiredError{
-					Message: fmt.Sprintf("installation of %s requires a system reboot", pkg),
-				}
+		if code, ok := exitCode(err); ok && code == 3010 {
+			w.changes.record(Change{Kind: ChangePackage, Name: pkg, Manager: "choco", Package: pkg, Root: true})
+			return &RebootRequiredError{
+				Message: fmt.Sprintf("installation of %s requires a system reboot", pkg),
 			}
 		}
 		return err
 	}
 
+	w.changes.record(Change{Kind: ChangePackage, Name: pkg, Manager: "choco", Package: pkg, Root: true})
 	return nil
 }
 
 // findUVPath attempts to locate the uv executable using multiple methods
 func (w *WindowsInstaller) findUVPath() (string, error) {
 	// Method 1: Try standard PATH lookup
-	if uvPath, err := exec.LookPath("uv"); err == nil {
+	if uvPath, err := w.runner.LookPath("uv"); err == nil {
 		return uvPath, nil
 	}
 
 	// Method 2: Check Chocolatey bin directory (where shims are)
-	chocoInstall := os.Getenv("ChocolateyInstall")
-	if chocoInstall == "" {
-		chocoInstall = `C:\ProgramData\chocolatey`
-	}
+	chocoInstall := w.chocolateyRoot()
 
 	chocoBin := filepath.Join(chocoInstall, "bin", "uv.exe")
-	if _, err := os.Stat(chocoBin); err == nil {
+	if _, err := w.runner.Stat(chocoBin); err == nil {
 		return chocoBin, nil
 	}
 
 	// Method 3: Search Chocolatey lib directory for uv installation
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
-		fmt.Sprintf(`$uvLib = Get-ChildItem -Path "%s\lib" -Filter "uv*" -Directory | Select-Object -First 1; if ($uvLib) { $uvExe = Get-ChildItem -Path $uvLib.FullName -Filter "uv.exe" -Recurse | Select-Object -First 1; if ($uvExe) { Write-Output $uvExe.FullName } }`, chocoInstall))
-	output, err := cmd.Output()
+	output, err := w.runner.Output(Command{Path: "powershell", Args: []string{"-NoProfile", "-Command",
+		fmt.Sprintf(`$uvLib = Get-ChildItem -Path "%s\lib" -Filter "uv*" -Directory | Select-Object -First 1; if ($uvLib) { $uvExe = Get-ChildItem -Path $uvLib.FullName -Filter "uv.exe" -Recurse | Select-Object -First 1; if ($uvExe) { Write-Output $uvExe.FullName } }`, chocoInstall)}, ReadOnly: true})
 	if err == nil && len(output) > 0 {
 		uvPath := strings.TrimSpace(string(output))
 		if uvPath != "" {
-			if _, err := os.Stat(uvPath); err == nil {
+			if _, err := w.runner.Stat(uvPath); err == nil {
 				return uvPath, nil
 			}
 		}
@@ -628,12 +629,12 @@ func (w *WindowsInstaller) findUVPath() (string, error) {
 
 	// Method 4: Check common installation locations
 	commonPaths := []string{
-		filepath.Join(os.Getenv("LOCALAPPDATA"), "Programs", "uv", "uv.exe"),
-		filepath.Join(os.Getenv("USERPROFILE"), ".local", "bin", "uv.exe"),
+		filepath.Join(w.runner.Getenv("LOCALAPPDATA"), "Programs", "uv", "uv.exe"),
+		filepath.Join(w.runner.Getenv("USERPROFILE"), ".local", "bin", "uv.exe"),
 	}
 
 	for _, path := range commonPaths {
-		if _, err := os.Stat(path); err == nil {
+		if _, err := w.runner.Stat(path); err == nil {
 			return path, nil
 		}
 	}
@@ -643,37 +644,24 @@ func (w *WindowsInstaller) findUVPath() (string, error) {
 
 // setupUVPath adds uv to PATH for the current process after Chocolatey installation
 func (w *WindowsInstaller) setupUVPath() error {
-	// Get Chocolatey install path from environment variable
-	chocoInstall := os.Getenv("ChocolateyInstall")
-	if chocoInstall == "" {
-		// Fall back to default location
-		chocoInstall = `C:\ProgramData\chocolatey`
-	}
+	chocoInstall := w.chocolateyRoot()
 
 	// Find uv tools directory using PowerShell
 	// Get-ChildItem -Path "$env:ChocolateyInstall\lib" | Where-Object Name -Like "uv*"
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
-		fmt.Sprintf(`(Get-ChildItem -Path "%s\lib" | Where-Object Name -Like "uv*" | Select-Object -First 1).FullName`, chocoInstall))
-	output, err := cmd.Output()
+	output, err := w.runner.Output(Command{Path: "powershell", Args: []string{"-NoProfile", "-Command",
+		fmt.Sprintf(`(Get-ChildItem -Path "%s\lib" | Where-Object Name -Like "uv*" | Select-Object -First 1).FullName`, chocoInstall)}, ReadOnly: true})
 	if err == nil && len(output) > 0 {
 		uvLibPath := strings.TrimSpace(string(output))
 		if uvLibPath != "" {
 			uvToolsPath := filepath.Join(uvLibPath, "tools")
-			if _, err := os.Stat(uvToolsPath); err == nil {
-				currentPath := os.Getenv("PATH")
-				if !strings.Contains(currentPath, uvToolsPath) {
-					os.Setenv("PATH", uvToolsPath+";"+currentPath)
-				}
+			if _, err := w.runner.Stat(uvToolsPath); err == nil {
+				prependPath(w.runner, uvToolsPath, ";")
 			}
 		}
 	}
 
 	// Also ensure Chocolatey bin is in PATH (where shims live)
-	chocoBin := filepath.Join(chocoInstall, "bin")
-	currentPath := os.Getenv("PATH")
-	if !strings.Contains(currentPath, chocoBin) {
-		os.Setenv("PATH", chocoBin+";"+currentPath)
-	}
+	prependPath(w.runner, filepath.Join(chocoInstall, "bin"), ";")
 
 	return nil
 }
@@ -0,0 +1,172 @@
+package platform_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+)
+
+// SEGGER's installer puts J-Link Commander here, off PATH
+const windowsJLink = `C:\Program Files\SEGGER\JLink\JLink.exe`
+
+var (
+	// chocoBin is Chocolatey's bin directory in its default location
+	chocoBin = filepath.Join(`C:\ProgramData\chocolatey`, "bin")
+
+	// jlinkInstaller is where the SEGGER installer is downloaded to
+	jlinkInstaller = filepath.Join(os.TempDir(), "hubble-jlink-install", "JLink_Installer.exe")
+)
+
+func TestWindowsCheckPrerequisites(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed []string
+		files     []string
+		offline   bool
+		want      []string
+	}{
+		{
+			name: "nothing installed",
+			want: []string{"Chocolatey", "segger-jlink", "simplicity-commander", "uniflash", "uv"},
+		},
+		{
+			name:      "everything installed, J-Link off PATH",
+			installed: []string{"choco", "uv", "commander", "dslite.bat"},
+			files:     []string{windowsJLink},
+		},
+		{
+			name:    "offline bundle without Chocolatey",
+			offline: true,
+			want:    []string{"segger-jlink", "simplicity-commander", "uniflash", "uv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := platformtest.NewRunner(tt.installed...)
+			for _, path := range tt.files {
+				runner.AddFile(path)
+			}
+			var installer platform.Installer = platform.NewWindowsInstaller(runner)
+			if tt.offline {
+				var err error
+				if installer, err = platform.NewBundleInstaller("windows", runner, emptyBundle(t, "windows")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			missing, err := installer.CheckPrerequisites(allDeps)
+			if err != nil {
+				t.Fatalf("CheckPrerequisites() = %v", err)
+			}
+			if got := sortedNames(missing); !slices.Equal(got, tt.want) {
+				t.Errorf("CheckPrerequisites() reported %v missing, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowsInstallDependencies(t *testing.T) {
+	runner := platformtest.NewRunner().
+		On(platformtest.Response{Match: jlinkInstaller + " /S", Creates: []string{windowsJLink}})
+	installer := platform.NewWindowsInstaller(runner)
+	deps := []string{"uv", "segger-jlink", "simplicity-commander"}
+	if err := installer.InstallDependencies(deps); err != nil {
+		t.Fatalf("InstallDependencies() = %v", err)
+	}
+
+	for _, prefix := range []string{"powershell -NoProfile -ExecutionPolicy Bypass", filepath.Join(chocoBin, "choco.exe") + " install uv -y", jlinkInstaller + " /S /ACCEPTLICENSE=yes"} {
+		if !runner.Ran(prefix) {
+			t.Errorf("InstallDependencies() did not run %q: %v", prefix, runner.Calls())
+		}
+	}
+	if missing, err := installer.CheckPrerequisites(deps); err != nil || len(missing) != 0 {
+		t.Errorf("CheckPrerequisites() after installing = %+v, %v", missing, err)
+	}
+}
+
+func TestWindowsInstallDependenciesFails(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []platformtest.Response
+		deps      []string
+		want      string
+		wantErr   any // Pointer to the error type expected, if any
+	}{
+		{
+			name:      "not an administrator",
+			responses: []platformtest.Response{{Match: "net session", ExitCode: 2}},
+			deps:      []string{"uv"},
+			want:      "administrator privileges required",
+		},
+		{
+			name:      "uv needs a reboot",
+			responses: []platformtest.Response{{Match: filepath.Join(chocoBin, "choco.exe") + " install uv", ExitCode: 3010}},
+			deps:      []string{"uv"},
+			want:      "requires a system reboot",
+			wantErr:   new(*platform.RebootRequiredError),
+		},
+		{
+			name:      "J-Link installer fails",
+			responses: []platformtest.Response{{Match: jlinkInstaller, ExitCode: 1}},
+			deps:      []string{"segger-jlink"},
+			want:      "installer failed with both methods",
+		},
+		{
+			name: "Commander download fails",
+			deps: []string{"simplicity-commander"},
+			want: "failed to install simplicity-commander",
+		},
+		{
+			name: "UniFlash not installed",
+			deps: []string{"uniflash"},
+			want: "UniFlash must be installed manually",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := platformtest.NewRunner("choco").
+				FailDownload("https://www.silabs.com/documents/public/software/SimplicityCommander-Windows.zip", errDownload)
+			for _, resp := range tt.responses {
+				runner.On(resp)
+			}
+
+			err := platform.NewWindowsInstaller(runner).InstallDependencies(tt.deps)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("InstallDependencies() = %v, want an error saying %q", err, tt.want)
+			}
+			if tt.wantErr != nil && !errors.As(err, tt.wantErr) {
+				t.Errorf("InstallDependencies() = %v, want a %T", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWindowsFlashBoard(t *testing.T) {
+	req := platform.FlashRequest{Board: "nrf52840dk"}
+
+	t.Run("uv from Chocolatey off PATH", func(t *testing.T) {
+		uv := filepath.Join(chocoBin, "uv.exe")
+		runner := platformtest.NewRunner().AddFile(uv)
+		if _, err := platform.NewWindowsInstaller(runner).FlashBoard(req); err != nil {
+			t.Fatalf("FlashBoard() = %v", err)
+		}
+		if !runner.Ran(uv + " tool run") {
+			t.Errorf("FlashBoard() ran %v, want pyhubbledemo through %s", runner.Calls(), uv)
+		}
+	})
+
+	t.Run("network error", func(t *testing.T) {
+		runner := platformtest.NewRunner("uv").
+			On(platformtest.Response{Match: hubbledemo, Err: errors.New("error: Failed to fetch: dns error: failed to lookup address")})
+		var networkErr *platform.NetworkError
+		if _, err := platform.NewWindowsInstaller(runner).FlashBoard(req); !errors.As(err, &networkErr) {
+			t.Errorf("FlashBoard() = %v, want a NetworkError", err)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+// Package redact masks credentials in text that leaves the installer's control:
+// the run log and support bundles
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Mask replaces every redacted value
+const Mask = "<redacted>"
+
+// longHex matches hex strings of 32 characters or more: the shape of a Hubble
+// API token (96 hex characters, though shorter ones are accepted), so that
+// tokens the Redactor was never told about are masked too. Checksums and commit
+// hashes are masked with them; a false positive costs less than a leaked token.
+var longHex = regexp.MustCompile(`\b[0-9A-Fa-f]{32,}\b`)
+
+// labeledOrgID matches an Org ID where it is labeled as one, such as
+// "org_id": "…" in JSON or -o … on pyhubbledemo's command line, keeping the label
+var labeledOrgID = regexp.MustCompile(`(?i)((?:\borg[ _-]?id|\s-o)["':=\s]+)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// Redactor masks known secrets, labeled Org IDs and anything shaped like an API
+// token in text. It is safe for concurrent use.
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// New creates a Redactor that masks the given secrets
+func New(secrets ...string) *Redactor {
+	r := &Redactor{}
+	r.Add(secrets...)
+	return r
+}
+
+// Add registers more secrets to mask. Empty values are ignored.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, secret := range secrets {
+		if secret != "" && !slices.Contains(r.secrets, secret) {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	// Longer secrets first, so one that contains another is masked whole
+	slices.SortFunc(r.secrets, func(a, b string) int { return len(b) - len(a) })
+}
+
+// String returns s with every secret masked
+func (r *Redactor) String(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Mask)
+	}
+	s = labeledOrgID.ReplaceAllString(s, "${1}"+Mask)
+	return longHex.ReplaceAllString(s, Mask)
+}
+
+// Bytes returns data with every secret masked
+func (r *Redactor) Bytes(data []byte) []byte {
+	return []byte(r.String(string(data)))
+}
+
+// JSON masks the string values of the named fields anywhere in a JSON document,
+// then masks the secrets r knows in what remains
+func (r *Redactor) JSON(data []byte, fields ...string) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	out, err := Marshal(maskFields(doc, fields))
+	if err != nil {
+		return nil, err
+	}
+	return r.Bytes(out), nil
+}
+
+// Marshal encodes v as indented JSON, leaving Mask readable rather than escaping
+// its angle brackets
+func Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// maskFields replaces the non-empty string values of fields in v
+func maskFields(v any, fields []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if s, ok := value.(string); ok && s != "" && slices.Contains(fields, key) {
+				v[key] = Mask
+				continue
+			}
+			v[key] = maskFields(value, fields)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = maskFields(value, fields)
+		}
+	}
+	return v
+}
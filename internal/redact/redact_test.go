@@ -0,0 +1,67 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	token   = "eb31d24113fadb77c6d89d65a8007c0eed3595e2255aaf1d7d81783900ab33be4332457a27861f67cc78fe930ea52941"
+	orgID   = "0f61efd0-24a7-4a2e-ae0f-8549d14ed901"
+	short32 = "0123456789abcdef0123456789ABCDEF"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name  string
+		known []string
+		in    string
+		want  string
+	}{
+		{"known secret", []string{"hunter2-secret"}, "password hunter2-secret used", "password " + Mask + " used"},
+		{"longer secret first", []string{"abc", "abcdef"}, "key=abcdef", "key=" + Mask},
+		{"96 hex token", nil, "-t " + token + " -n dev", "-t " + Mask + " -n dev"},
+		{"32 hex token", nil, "token " + short32, "token " + Mask},
+		{"64 hex", nil, "sha256 " + strings.Repeat("ab", 32), "sha256 " + Mask},
+		{"short hex kept", nil, "commit 9580da2", "commit 9580da2"},
+		{"31 hex kept", nil, "id " + short32[:31], "id " + short32[:31]},
+		{"labeled org ID", nil, `"org_id": "` + orgID + `"`, `"org_id": "` + Mask + `"`},
+		{"org ID flag", nil, "hubbledemo flash nrf52840dk -o " + orgID, "hubbledemo flash nrf52840dk -o " + Mask},
+		{"unlabeled UUID kept", nil, "request " + orgID, "request " + orgID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.known...).String(tt.in); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddIgnoresEmpty(t *testing.T) {
+	r := New("")
+	r.Add("", "")
+	if got := r.String("nothing to hide"); got != "nothing to hide" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data := []byte(`{"profiles": {"default": {"org_id": "` + orgID + `", "api_token": "plain", "token_store": "keyring"}}, "note": "uses plain"}`)
+	out, err := New("plain").JSON(data, "api_token", "org_id")
+	if err != nil {
+		t.Fatalf("JSON() = %v", err)
+	}
+	got := string(out)
+	for _, leaked := range []string{orgID, "plain"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("JSON() leaked %q: %s", leaked, got)
+		}
+	}
+	if !strings.Contains(got, `"token_store": "keyring"`) || !strings.Contains(got, Mask) {
+		t.Errorf("JSON() = %s", got)
+	}
+	if _, err := New().JSON([]byte("not json"), "api_token"); err == nil {
+		t.Error("JSON() accepted invalid JSON")
+	}
+}
@@ -0,0 +1,240 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// PassphraseEnv supplies the encrypted file passphrase without prompting
+const PassphraseEnv = "HUBBLE_SECRETS_PASSPHRASE"
+
+const (
+	fileFormatVersion = 1
+	pbkdf2Iterations  = 600000
+	keyLength         = 32 // AES-256
+)
+
+// File stores secrets in a file encrypted with AES-256-GCM under a key derived
+// from a passphrase. It is the fallback when no OS keyring is available.
+type File struct {
+	// Path is the encrypted secrets file
+	Path string
+
+	// Passphrase returns the passphrase used to derive the encryption key
+	Passphrase func() (string, error)
+
+	key []byte // Derived key, cached after the first successful use
+}
+
+// encryptedFile is the on-disk format of the secrets file
+type encryptedFile struct {
+	Version int               `json:"version"`
+	Salt    []byte            `json:"salt"`
+	Check   []byte            `json:"check"`   // Encrypted known value, used to detect a wrong passphrase
+	Secrets map[string][]byte `json:"secrets"` // Account -> nonce || ciphertext
+}
+
+// passphraseCheck is encrypted into every file so a wrong passphrase is reported clearly
+const passphraseCheck = "hubble-install"
+
+// DefaultFile returns the encrypted file backend in the user config directory,
+// reading the passphrase from HUBBLE_SECRETS_PASSPHRASE or prompting for it
+func DefaultFile() *File {
+	path := "secrets.enc"
+	if configDir, err := os.UserConfigDir(); err == nil {
+		path = filepath.Join(configDir, "hubble", "secrets.enc")
+	}
+	return &File{Path: path, Passphrase: promptPassphrase}
+}
+
+// promptPassphrase reads the passphrase from the environment or the terminal
+func promptPassphrase() (string, error) {
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	passphrase := strings.TrimSpace(ui.PromptPassword("Enter the passphrase for your encrypted Hubble credentials (hidden)"))
+	if passphrase == "" {
+		return "", errors.New("passphrase cannot be empty")
+	}
+	return passphrase, nil
+}
+
+func (f *File) Name() string {
+	return BackendFile
+}
+
+// Available always reports true: the file backend only needs the filesystem
+func (f *File) Available() bool {
+	return true
+}
+
+func (f *File) Get(account string) (string, error) {
+	data, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	sealed, ok := data.Secrets[account]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	gcm, err := f.cipher(data)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(gcm, sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret for %s: %w", account, err)
+	}
+	return string(plaintext), nil
+}
+
+func (f *File) Set(account, secret string) error {
+	data, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	gcm, err := f.cipher(data)
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(gcm, []byte(secret))
+	if err != nil {
+		return err
+	}
+	data.Secrets[account] = sealed
+
+	return f.save(data)
+}
+
+func (f *File) Delete(account string) error {
+	data, err := f.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := data.Secrets[account]; !ok {
+		return nil
+	}
+	// Only the passphrase holder may remove secrets, even though removing needs no key
+	if _, err := f.cipher(data); err != nil {
+		return err
+	}
+	delete(data.Secrets, account)
+	return f.save(data)
+}
+
+// load reads the secrets file, returning an empty file with a fresh salt if it does not exist
+func (f *File) load() (*encryptedFile, error) {
+	raw, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		return &encryptedFile{Version: fileFormatVersion, Salt: salt, Secrets: make(map[string][]byte)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	var data encryptedFile
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("secrets file %s is corrupt: %w", f.Path, err)
+	}
+	if data.Version != fileFormatVersion {
+		return nil, fmt.Errorf("secrets file %s has unsupported version %d", f.Path, data.Version)
+	}
+	if data.Secrets == nil {
+		data.Secrets = make(map[string][]byte)
+	}
+	return &data, nil
+}
+
+// save writes the secrets file, readable only by the current user
+func (f *File) save(data *encryptedFile) error {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	if err := os.Rename(tmp, f.Path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	return nil
+}
+
+// cipher derives the key for data's salt and verifies it against the stored check value.
+// A file without a check value (new file) records one for the key.
+func (f *File) cipher(data *encryptedFile) (cipher.AEAD, error) {
+	if f.key == nil {
+		passphrase, err := f.Passphrase()
+		if err != nil {
+			return nil, err
+		}
+		key, err := pbkdf2.Key(sha256.New, passphrase, data.Salt, pbkdf2Iterations, keyLength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		f.key = key
+	}
+
+	block, err := aes.NewCipher(f.key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Check == nil {
+		data.Check, err = seal(gcm, []byte(passphraseCheck))
+		if err != nil {
+			return nil, err
+		}
+		return gcm, nil
+	}
+	if plaintext, err := open(gcm, data.Check); err != nil || string(plaintext) != passphraseCheck {
+		f.key = nil
+		return nil, errors.New("incorrect passphrase for encrypted credentials")
+	}
+	return gcm, nil
+}
+
+// seal encrypts plaintext with a random nonce, returning nonce || ciphertext
+func seal(gcm cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts the output of seal
+func open(gcm cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
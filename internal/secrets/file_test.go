@@ -0,0 +1,160 @@
+package secrets_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/secrets"
+)
+
+// fileBackend returns an encrypted file backend in a temporary directory
+func fileBackend(t *testing.T, passphrase string) *secrets.File {
+	t.Helper()
+	return &secrets.File{
+		Path:       filepath.Join(t.TempDir(), "hubble", "secrets.enc"),
+		Passphrase: func() (string, error) { return passphrase, nil },
+	}
+}
+
+// reopen returns a backend for the same file with another passphrase, as a later run would
+func reopen(f *secrets.File, passphrase string) *secrets.File {
+	return &secrets.File{Path: f.Path, Passphrase: func() (string, error) { return passphrase, nil }}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	f := fileBackend(t, "correct horse")
+	if err := f.Set("work", "token-1"); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if err := f.Set("home", "token-2"); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+
+	raw, err := os.ReadFile(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("token-1")) {
+		t.Errorf("secrets file holds the token in plain text:\n%s", raw)
+	}
+
+	again := reopen(f, "correct horse")
+	for account, want := range map[string]string{"work": "token-1", "home": "token-2"} {
+		if got, err := again.Get(account); err != nil || got != want {
+			t.Errorf("Get(%q) = %q, %v, want %q", account, got, err, want)
+		}
+	}
+	if _, err := again.Get("other"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("Get() of a missing account = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileWrongPassphrase(t *testing.T) {
+	f := fileBackend(t, "correct horse")
+	if err := f.Set("work", "token-1"); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	before, err := os.ReadFile(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := reopen(f, "battery staple")
+	if got, err := wrong.Get("work"); err == nil || !strings.Contains(err.Error(), "incorrect passphrase") {
+		t.Errorf("Get() with a wrong passphrase = %q, %v, want an incorrect passphrase error", got, err)
+	}
+	if err := wrong.Set("home", "token-2"); err == nil {
+		t.Errorf("Set() with a wrong passphrase succeeded")
+	}
+	if err := wrong.Delete("work"); err == nil {
+		t.Errorf("Delete() with a wrong passphrase succeeded")
+	}
+
+	after, err := os.ReadFile(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("a wrong passphrase rewrote the secrets file")
+	}
+	if got, err := reopen(f, "correct horse").Get("work"); err != nil || got != "token-1" {
+		t.Errorf("Get() after a wrong passphrase = %q, %v, want the saved token", got, err)
+	}
+}
+
+func TestFileUnsupportedVersion(t *testing.T) {
+	f := fileBackend(t, "correct horse")
+	if err := f.Set("work", "token-1"); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	raw, err := os.ReadFile(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	data["version"] = 2
+	if raw, err = json.Marshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(f.Path, raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reopen(f, "correct horse").Get("work"); err == nil || !strings.Contains(err.Error(), "unsupported version 2") {
+		t.Errorf("Get() = %v, want an unsupported version error", err)
+	}
+}
+
+func TestFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows does not use Unix file modes")
+	}
+	f := fileBackend(t, "correct horse")
+	if err := f.Set("work", "token-1"); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("secrets file mode = %o, want 600", mode)
+	}
+	if info, err := os.Stat(filepath.Dir(f.Path)); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("secrets directory = %v, %v, want mode 700", info.Mode().Perm(), err)
+	}
+}
+
+func TestFileDelete(t *testing.T) {
+	f := fileBackend(t, "correct horse")
+	for account, secret := range map[string]string{"work": "token-1", "home": "token-2"} {
+		if err := f.Set(account, secret); err != nil {
+			t.Fatalf("Set() = %v", err)
+		}
+	}
+
+	if err := f.Delete("work"); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	if err := f.Delete("work"); err != nil {
+		t.Errorf("Delete() of a deleted account = %v, want nil", err)
+	}
+
+	again := reopen(f, "correct horse")
+	if _, err := again.Get("work"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("Get() of a deleted account = %v, want ErrNotFound", err)
+	}
+	if got, err := again.Get("home"); err != nil || got != "token-2" {
+		t.Errorf("Get() of the other account = %q, %v, want token-2", got, err)
+	}
+}
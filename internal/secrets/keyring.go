@@ -0,0 +1,117 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// secretService stores secrets in the freedesktop Secret Service (GNOME Keyring,
+// KWallet) through libsecret's secret-tool command
+type secretService struct{}
+
+func (s *secretService) Name() string {
+	return BackendKeyring
+}
+
+// Available requires secret-tool and a D-Bus session to reach the keyring daemon
+func (s *secretService) Available() bool {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return false
+	}
+	return os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+}
+
+func (s *secretService) Get(account string) (string, error) {
+	cmd := exec.Command("secret-tool", "lookup", "service", Service, "account", account)
+	output, err := cmd.Output()
+	if err != nil {
+		// secret-tool exits 1 with no output when nothing matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) == 0 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("secret-tool lookup failed: %w", err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+func (s *secretService) Set(account, secret string) error {
+	// The secret is passed on stdin so it never appears in the process list
+	cmd := exec.Command("secret-tool", "store", "--label", fmt.Sprintf("Hubble API token (%s)", account),
+		"service", Service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool store failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (s *secretService) Delete(account string) error {
+	cmd := exec.Command("secret-tool", "clear", "service", Service, "account", account)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool clear failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// keychain stores secrets in the macOS login keychain through the security command
+type keychain struct{}
+
+func (k *keychain) Name() string {
+	return BackendKeyring
+}
+
+func (k *keychain) Available() bool {
+	_, err := exec.LookPath("security")
+	return err == nil
+}
+
+func (k *keychain) Get(account string) (string, error) {
+	cmd := exec.Command("security", "find-generic-password", "-s", Service, "-a", account, "-w")
+	output, err := cmd.Output()
+	if err != nil {
+		// Exit code 44 is errSecItemNotFound
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("keychain lookup failed: %w", err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+func (k *keychain) Set(account, secret string) error {
+	// Run security in interactive mode and send the command on stdin so the
+	// secret never appears in the process list
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		quoteSecurityArg(Service), quoteSecurityArg(account), quoteSecurityArg(secret))
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(command)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("keychain store failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (k *keychain) Delete(account string) error {
+	cmd := exec.Command("security", "delete-generic-password", "-s", Service, "-a", account)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return nil
+		}
+		return fmt.Errorf("keychain delete failed: %w", err)
+	}
+	return nil
+}
+
+// quoteSecurityArg quotes a value for the security command's interactive mode
+func quoteSecurityArg(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// Service is the name secrets are filed under in the OS keyring
+const Service = "hubble-install"
+
+// Backend names, as recorded alongside saved profiles
+const (
+	BackendKeyring = "keyring" // OS keyring (Secret Service, Keychain, Credential Manager)
+	BackendFile    = "file"    // Passphrase-encrypted file in the user config directory
+)
+
+var (
+	// ErrNotFound is returned when no secret is stored for an account
+	ErrNotFound = errors.New("secret not found")
+
+	// ErrUnavailable is returned when a backend cannot be used on this system
+	ErrUnavailable = errors.New("secret backend unavailable")
+)
+
+// Backend stores secrets by account name
+type Backend interface {
+	// Name returns the backend name recorded with profiles (e.g. "keyring")
+	Name() string
+
+	// Available reports whether the backend can be used on this system
+	Available() bool
+
+	// Get returns the secret stored for account, or ErrNotFound
+	Get(account string) (string, error)
+
+	// Set stores secret for account, replacing any existing value
+	Set(account, secret string) error
+
+	// Delete removes the secret for account; deleting a missing secret is not an error
+	Delete(account string) error
+}
+
+// Keyring returns the OS keyring backend for the current platform
+func Keyring() Backend {
+	switch runtime.GOOS {
+	case "linux":
+		return &secretService{}
+	case "darwin":
+		return &keychain{}
+	case "windows":
+		return &credentialManager{}
+	default:
+		return unavailable{}
+	}
+}
+
+// Default returns the OS keyring if it is usable, otherwise the encrypted file backend
+func Default() Backend {
+	if keyring := Keyring(); keyring.Available() {
+		return keyring
+	}
+	return DefaultFile()
+}
+
+// ByName returns the backend recorded under name
+func ByName(name string) (Backend, error) {
+	switch name {
+	case BackendKeyring:
+		return Keyring(), nil
+	case BackendFile:
+		return DefaultFile(), nil
+	default:
+		return nil, fmt.Errorf("unknown secret backend: %s", name)
+	}
+}
+
+// unavailable is the keyring backend for platforms without one
+type unavailable struct{}
+
+func (unavailable) Name() string               { return BackendKeyring }
+func (unavailable) Available() bool            { return false }
+func (unavailable) Get(string) (string, error) { return "", ErrUnavailable }
+func (unavailable) Set(string, string) error   { return ErrUnavailable }
+func (unavailable) Delete(string) error        { return ErrUnavailable }
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package secrets
+
+// credentialManager is only implemented on Windows
+type credentialManager struct{ unavailable }
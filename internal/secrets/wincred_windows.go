@@ -0,0 +1,116 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32       = windows.NewLazySystemDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+// credentialW mirrors the Win32 CREDENTIALW structure
+type credentialW struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credentialManager stores secrets as generic credentials in the Windows Credential Manager
+type credentialManager struct{}
+
+func (c *credentialManager) Name() string {
+	return BackendKeyring
+}
+
+func (c *credentialManager) Available() bool {
+	return procCredReadW.Find() == nil
+}
+
+func (c *credentialManager) Get(account string) (string, error) {
+	target, err := windows.UTF16PtrFromString(credentialTarget(account))
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credentialW
+	ret, _, callErr := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		if errors.Is(callErr, windows.ERROR_NOT_FOUND) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("CredRead failed: %w", callErr)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	return string(blob), nil
+}
+
+func (c *credentialManager) Set(account, secret string) error {
+	target, err := windows.UTF16PtrFromString(credentialTarget(account))
+	if err != nil {
+		return err
+	}
+	userName, err := windows.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(secret)
+	cred := credentialW{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+
+	ret, _, callErr := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if ret == 0 {
+		return fmt.Errorf("CredWrite failed: %w", callErr)
+	}
+	return nil
+}
+
+func (c *credentialManager) Delete(account string) error {
+	target, err := windows.UTF16PtrFromString(credentialTarget(account))
+	if err != nil {
+		return err
+	}
+
+	ret, _, callErr := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if ret == 0 && !errors.Is(callErr, windows.ERROR_NOT_FOUND) {
+		return fmt.Errorf("CredDelete failed: %w", callErr)
+	}
+	return nil
+}
+
+// credentialTarget is the name the credential is listed under in Credential Manager
+func credentialTarget(account string) string {
+	return Service + ":" + account
+}
@@ -0,0 +1,89 @@
+// Package support packs what is needed to investigate a failed run into one zip
+// archive for a support ticket, with credentials masked
+package support
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/redact"
+)
+
+// CredentialFields are the JSON fields of the installer's config files that hold
+// credentials: the saved profiles and the state of an interrupted run
+var CredentialFields = []string{"api_token", "org_id"}
+
+// Archive is a support bundle being written. Everything added to it passes
+// through its Redactor first.
+type Archive struct {
+	file     *os.File
+	zw       *zip.Writer
+	redactor *redact.Redactor
+	names    []string
+}
+
+// Create starts a support bundle at path that masks what r knows
+func Create(path string, r *redact.Redactor) (*Archive, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create support bundle: %w", err)
+	}
+	return &Archive{file: file, zw: zip.NewWriter(file), redactor: r}, nil
+}
+
+// Add writes a file into the bundle with secrets masked
+func (a *Archive) Add(name string, data []byte) error {
+	w, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to the support bundle: %w", name, err)
+	}
+	if _, err := w.Write(a.redactor.Bytes(data)); err != nil {
+		return fmt.Errorf("failed to add %s to the support bundle: %w", name, err)
+	}
+	a.names = append(a.names, name)
+	return nil
+}
+
+// AddJSON writes v into the bundle as indented JSON
+func (a *Archive) AddJSON(name string, v any) error {
+	data, err := redact.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return a.Add(name, data)
+}
+
+// AddConfig writes an installer config file into the bundle with its
+// CredentialFields masked. A file that is not valid JSON is left out, as it
+// cannot be redacted reliably.
+func (a *Archive) AddConfig(name string, data []byte) error {
+	redacted, err := a.redactor.JSON(data, CredentialFields...)
+	if err != nil {
+		return fmt.Errorf("%s left out of the support bundle: it is not valid JSON: %w", name, err)
+	}
+	return a.Add(name, redacted)
+}
+
+// Names returns the files added so far, in order
+func (a *Archive) Names() []string {
+	return a.names
+}
+
+// Close finishes the bundle
+func (a *Archive) Close() error {
+	if err := a.zw.Close(); err != nil {
+		a.file.Close()
+		return fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	if err := a.file.Close(); err != nil {
+		return fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	return nil
+}
+
+// Path returns the file the bundle is written to
+func (a *Archive) Path() string {
+	return a.file.Name()
+}
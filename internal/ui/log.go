@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/redact"
+)
+
+// runLog is the log of this run, once OpenLog has been called. It receives
+// every event and the output of every command the installer runs, with
+// credentials masked, so a failure can be investigated after the terminal is gone.
+var runLog *logFile
+
+// logFile writes whole lines to a file, masking secrets in each
+type logFile struct {
+	mu       sync.Mutex
+	file     *os.File
+	redactor *redact.Redactor
+	partial  []byte // Output written since the last newline
+}
+
+// maxPartialLine is how much of a line without a newline is held back before it
+// is written anyway, e.g. a progress bar redrawn with carriage returns
+const maxPartialLine = 64 << 10
+
+// OpenLog starts logging this run to a new file at path
+func OpenLog(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+	runLog = &logFile{file: file, redactor: redact.New()}
+	return nil
+}
+
+// CloseLog finishes the log of this run
+func CloseLog() {
+	if runLog == nil {
+		return
+	}
+	runLog.mu.Lock()
+	defer runLog.mu.Unlock()
+	runLog.flush()
+	runLog.file.Close()
+	runLog = nil
+}
+
+// LogPath returns the file this run is logged to, or "" when it is not logged
+func LogPath() string {
+	if runLog == nil {
+		return ""
+	}
+	return runLog.file.Name()
+}
+
+// Redact masks secrets, such as the API token, wherever they would appear in the log
+func Redact(secrets ...string) {
+	if runLog != nil {
+		runLog.redactor.Add(secrets...)
+	}
+}
+
+// LogWriter returns a writer that copies command output into the log, or nil
+// when the run is not logged
+func LogWriter() io.Writer {
+	if runLog == nil {
+		return nil
+	}
+	return runLog
+}
+
+// Logf adds a line to the log without showing it
+func Logf(format string, args ...any) {
+	if runLog != nil {
+		fmt.Fprintf(runLog, "%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+	}
+}
+
+// logEvent adds an event to the log as one line of text
+func logEvent(event Event) {
+	if runLog == nil {
+		return
+	}
+	var text string
+	switch event.Type {
+	case EventStep:
+		text = fmt.Sprintf("step %s %s %s", event.Step, event.Status, event.Title)
+		if event.Error != "" {
+			text += ": " + event.Error
+		}
+	case EventMessage:
+		text = fmt.Sprintf("%-7s %s", event.Level, event.Message)
+	case EventResult:
+		result, _ := json.Marshal(event.Result)
+		text = "result " + string(result)
+	}
+	fmt.Fprintf(runLog, "%s %s\n", event.Time.Format(time.RFC3339), text)
+}
+
+// Write adds output to the log, masking secrets line by line so that one split
+// across two writes is still found
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.partial = append(l.partial, p...)
+	if i := bytes.LastIndexByte(l.partial, '\n'); i >= 0 {
+		lines := l.partial[:i+1]
+		if _, err := l.file.Write(l.redactor.Bytes(lines)); err != nil {
+			return 0, err
+		}
+		l.partial = append(l.partial[:0], l.partial[i+1:]...)
+	}
+	if len(l.partial) > maxPartialLine {
+		l.flush()
+	}
+	return len(p), nil
+}
+
+// flush writes out a pending partial line
+func (l *logFile) flush() {
+	if len(l.partial) > 0 {
+		l.file.Write(l.redactor.Bytes(append(l.partial, '\n')))
+		l.partial = l.partial[:0]
+	}
+}
@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogRedactsSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.log")
+	if err := OpenLog(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(CloseLog)
+
+	Redact("keyring-token-value")
+	Logf("$ tool --token keyring-token-value")
+	w := LogWriter()
+	// A secret split across two writes is still found
+	w.Write([]byte("stderr: bad token keyring-tok"))
+	w.Write([]byte("en-value\n"))
+	w.Write([]byte("unregistered 0123456789abcdef0123456789abcdef\n"))
+	w.Write([]byte("no newline at the end"))
+	CloseLog()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	for _, leaked := range []string{"keyring-token-value", "0123456789abcdef0123456789abcdef"} {
+		if strings.Contains(log, leaked) {
+			t.Errorf("log leaked %q:\n%s", leaked, log)
+		}
+	}
+	if !strings.Contains(log, "no newline at the end\n") {
+		t.Errorf("partial line was not flushed on close:\n%s", log)
+	}
+	if LogWriter() != nil || LogPath() != "" {
+		t.Error("log still open after CloseLog")
+	}
+}
@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// progressInterval limits how often a progress bar is redrawn
+const progressInterval = 100 * time.Millisecond
+
+// progressLine is the terminal line progress bars are drawn on. Transfers may
+// run in parallel, as the Darwin installer downloads packages concurrently, so
+// the line belongs to one bar at a time; the others count bytes without drawing
+// until it is released. Messages printed meanwhile clear the line first.
+var progressLine struct {
+	mu    sync.Mutex
+	owner *Progress // Bar currently drawn, if any
+}
+
+// clearProgressLine clears the bar on the terminal line, if one is drawn. The
+// caller holds progressLine.mu.
+func clearProgressLine() {
+	if progressLine.owner != nil {
+		fmt.Fprint(color.Output, "\r\033[K") // Clear line
+		progressLine.owner = nil
+	}
+}
+
+// Progress renders a progress bar for a transfer. It only draws when output is
+// for a person on a terminal, so logs and JSON output are not filled with it.
+type Progress struct {
+	mu      sync.Mutex
+	label   string
+	done    int64
+	total   int64 // Bytes expected in total, or -1 if unknown
+	started time.Time
+	resumed int64 // Bytes already transferred when the current attempt started
+	drawn   time.Time
+	enabled bool
+}
+
+// NewProgress creates a progress bar labelled with label, e.g. a file name
+func NewProgress(label string) *Progress {
+	return &Progress{
+		label:   label,
+		total:   -1,
+		enabled: IsHuman() && term.IsTerminal(int(os.Stdout.Fd())),
+	}
+}
+
+// Start begins a transfer attempt that already has done of total bytes (-1 if
+// the total is unknown)
+func (p *Progress) Start(done, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done, p.total, p.resumed = done, total, done
+	p.started = time.Now()
+	p.draw(true)
+}
+
+// Add records n more bytes transferred
+func (p *Progress) Add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	p.draw(false)
+}
+
+// Done clears the progress bar and releases the line for other bars
+func (p *Progress) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	progressLine.mu.Lock()
+	defer progressLine.mu.Unlock()
+	if progressLine.owner == p {
+		clearProgressLine()
+	}
+}
+
+// draw redraws the bar, at most every progressInterval unless force is set
+func (p *Progress) draw(force bool) {
+	if !p.enabled || (!force && time.Since(p.drawn) < progressInterval) {
+		return
+	}
+	progressLine.mu.Lock()
+	defer progressLine.mu.Unlock()
+	if progressLine.owner != nil && progressLine.owner != p {
+		return
+	}
+	p.drawn = time.Now()
+	progressLine.owner = p
+
+	rate := ""
+	if elapsed := time.Since(p.started).Seconds(); elapsed > 0.5 {
+		rate = fmt.Sprintf("  %s/s", formatBytes(int64(float64(p.done-p.resumed)/elapsed)))
+	}
+	if p.total <= 0 {
+		cyan.Printf("\r\033[K  %s  %s%s", p.label, formatBytes(p.done), rate)
+		return
+	}
+
+	const width = 30
+	filled := int(min(p.done*width/p.total, width))
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+	cyan.Printf("\r\033[K  %s  %s %3d%%  %s / %s%s", p.label, bar, p.done*100/p.total, formatBytes(p.done), formatBytes(p.total), rate)
+}
+
+// formatBytes formats a byte count for people, e.g. "48.2 MB"
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
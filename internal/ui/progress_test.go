@@ -0,0 +1,94 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// lockedBuffer is a bytes.Buffer safe for concurrent writes
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureTerminal sends what progress bars draw to a buffer for the test
+func captureTerminal(t *testing.T) *lockedBuffer {
+	t.Helper()
+	out := &lockedBuffer{}
+	saved := color.Output
+	color.Output = out
+	t.Cleanup(func() {
+		color.Output = saved
+		progressLine.owner = nil
+	})
+	return out
+}
+
+// drawnBar returns a progress bar that draws as if on a terminal
+func drawnBar(label string) *Progress {
+	p := NewProgress(label)
+	p.enabled = true
+	return p
+}
+
+func TestProgressBarsShareTheLine(t *testing.T) {
+	out := captureTerminal(t)
+	a, b := drawnBar("a.pkg"), drawnBar("b.pkg")
+
+	a.Start(0, 100)
+	b.Start(0, 100)
+	if strings.Contains(out.String(), "b.pkg") {
+		t.Fatalf("b.pkg was drawn over a.pkg:\n%q", out.String())
+	}
+
+	a.Done()
+	b.Start(50, 100)
+	if !strings.Contains(out.String(), "b.pkg") {
+		t.Errorf("b.pkg was not drawn once a.pkg finished:\n%q", out.String())
+	}
+}
+
+func TestProgressBarsDrawWholeLinesInParallel(t *testing.T) {
+	out := captureTerminal(t)
+
+	var wg sync.WaitGroup
+	for _, label := range []string{"a.pkg", "b.pkg", "c.pkg"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := drawnBar(label)
+			defer p.Done()
+			for i := range 200 {
+				p.Start(int64(i), 200)
+				if i%50 == 0 {
+					PrintInfo("still downloading " + label)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Each redraw starts by clearing the line, so a segment holding two labels
+	// means two bars were drawn into each other
+	for _, segment := range strings.Split(out.String(), "\r\033[K") {
+		if strings.Count(segment, ".pkg") > 1 {
+			t.Fatalf("interleaved output %q", segment)
+		}
+	}
+}
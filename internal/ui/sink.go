@@ -0,0 +1,181 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Event types
+const (
+	EventStep    = "step"    // A step started or finished
+	EventMessage = "message" // A progress message (success, error, warning, info)
+	EventResult  = "result"  // The final outcome of a command
+)
+
+// Step statuses
+const (
+	StatusStarted   = "started"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+// Message levels
+const (
+	LevelSuccess = "success"
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelInfo    = "info"
+)
+
+// Event is a structured record of installer progress
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Step       string    `json:"step,omitempty"`   // Machine-readable step ID, e.g. "prerequisites"
+	Title      string    `json:"title,omitempty"`  // Human-readable step title
+	Status     string    `json:"status,omitempty"` // Step status
+	Current    int       `json:"current,omitempty"`
+	Total      int       `json:"total,omitempty"`
+	Level      string    `json:"level,omitempty"` // Message level
+	Message    string    `json:"message,omitempty"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	ErrorCode  string    `json:"error_code,omitempty"`
+	Result     any       `json:"result,omitempty"`
+}
+
+// Sink renders installer events. The human sink prints colored text; the JSON
+// sink writes one JSON object per line for automation.
+type Sink interface {
+	Emit(event Event)
+}
+
+// sink receives every event; it defaults to human-readable output on stdout
+var sink Sink = NewHumanSink(nil)
+
+// SetSink replaces the sink that events are rendered to
+func SetSink(s Sink) {
+	sink = s
+}
+
+// IsHuman reports whether output is being rendered for a person rather than a program
+func IsHuman() bool {
+	_, ok := sink.(*HumanSink)
+	return ok
+}
+
+// EnableJSON switches to JSON-lines events on stdout. Anything else written to
+// stdout afterwards, including subprocess output and prompts, is redirected to
+// stderr so the event stream stays parseable.
+func EnableJSON() {
+	SetSink(NewJSONSink(os.Stdout))
+	os.Stdout = os.Stderr
+	color.Output = color.Error
+}
+
+// Emit sends an event to the current sink, stamping it with the current time
+func Emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	sink.Emit(event)
+	logEvent(event)
+}
+
+// HumanSink prints events as colored text
+type HumanSink struct {
+	w io.Writer
+}
+
+// NewHumanSink creates a human-readable sink writing to w (nil means stdout)
+func NewHumanSink(w io.Writer) *HumanSink {
+	return &HumanSink{w: w}
+}
+
+// Emit prints step headers and messages; step completions and results are
+// already conveyed by messages and banners, so they print nothing
+func (h *HumanSink) Emit(event Event) {
+	w := h.w
+	if w == nil {
+		w = color.Output
+	}
+	progressLine.mu.Lock()
+	defer progressLine.mu.Unlock()
+	clearProgressLine()
+
+	switch event.Type {
+	case EventStep:
+		if event.Status != StatusStarted || event.Title == "" {
+			return
+		}
+		fmt.Fprintln(w)
+		if event.Total > 0 {
+			blue.Fprintf(w, "[%d/%d] %s\n", event.Current, event.Total, event.Title)
+		} else {
+			blue.Fprintf(w, "[%d] %s\n", event.Current, event.Title)
+		}
+	case EventMessage:
+		switch event.Level {
+		case LevelSuccess:
+			green.Fprintf(w, "✓ %s\n", event.Message)
+		case LevelError:
+			red.Fprintf(w, "✗ %s\n", event.Message)
+		case LevelWarning:
+			yellow.Fprintf(w, "⚠ %s\n", event.Message)
+		default:
+			cyan.Fprintf(w, "ℹ %s\n", event.Message)
+		}
+	}
+}
+
+// JSONSink writes each event as a single line of JSON
+type JSONSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONSink creates a sink writing JSON lines to w
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{enc: json.NewEncoder(w)}
+}
+
+// Emit writes the event; it is safe for concurrent use
+func (j *JSONSink) Emit(event Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.enc.Encode(event)
+}
+
+// StartStep reports that a step has begun. Steps without a title are only
+// visible in structured output.
+func StartStep(id, title string, current, total int) {
+	Emit(Event{Type: EventStep, Step: id, Title: title, Status: StatusStarted, Current: current, Total: total})
+}
+
+// EndStep reports that a step has finished, successfully if err is nil
+func EndStep(id string, duration time.Duration, err error, errorCode string) {
+	event := Event{Type: EventStep, Step: id, Status: StatusSucceeded, DurationMS: duration.Milliseconds()}
+	if err != nil {
+		event.Status = StatusFailed
+		event.Error = err.Error()
+		event.ErrorCode = errorCode
+	}
+	Emit(event)
+}
+
+// SkipStep reports that a step was not needed or was declined
+func SkipStep(id string) {
+	Emit(Event{Type: EventStep, Step: id, Status: StatusSkipped})
+}
+
+// PrintResult reports the final outcome of a command as a summary object
+func PrintResult(result any) {
+	Emit(Event{Type: EventResult, Result: result})
+}
@@ -0,0 +1,144 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// emitAll reports a step, a message, a failure and a result, as a command run does
+func emitAll() {
+	StartStep("prerequisites", "Checking prerequisites", 1, 2)
+	PrintInfo("uv is installed")
+	EndStep("prerequisites", 1500*time.Millisecond, nil, "")
+	StartStep("flash", "Flashing the board", 2, 2)
+	PrintError("Flashing failed")
+	EndStep("flash", time.Second, errors.New("J-Link not found"), "FLASH_FAILED")
+	PrintResult(map[string]string{"board": "nrf52840dk"})
+}
+
+// decodeLines parses out as JSON lines, failing the test on any other line
+func decodeLines(t *testing.T, out string) []Event {
+	t.Helper()
+	var events []Event
+	for i, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("line %d is not a JSON object: %q", i+1, line)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// useSink sends events to s for the rest of the test
+func useSink(t *testing.T, s Sink) {
+	t.Helper()
+	SetSink(s)
+	t.Cleanup(func() { SetSink(NewHumanSink(nil)) })
+}
+
+func TestJSONSink(t *testing.T) {
+	var out bytes.Buffer
+	useSink(t, NewJSONSink(&out))
+
+	emitAll()
+
+	events := decodeLines(t, out.String())
+	want := []struct {
+		typ, step, status, level, errorCode string
+	}{
+		{EventStep, "prerequisites", StatusStarted, "", ""},
+		{EventMessage, "", "", LevelInfo, ""},
+		{EventStep, "prerequisites", StatusSucceeded, "", ""},
+		{EventStep, "flash", StatusStarted, "", ""},
+		{EventMessage, "", "", LevelError, ""},
+		{EventStep, "flash", StatusFailed, "", "FLASH_FAILED"},
+		{EventResult, "", "", "", ""},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("wrote %d events, want %d:\n%s", len(events), len(want), out.String())
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Type != w.typ || e.Step != w.step || e.Status != w.status || e.Level != w.level || e.ErrorCode != w.errorCode {
+			t.Errorf("event %d = %+v, want %+v", i+1, e, w)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("event %d has no time", i+1)
+		}
+	}
+	if e := events[0]; e.Title != "Checking prerequisites" || e.Current != 1 || e.Total != 2 {
+		t.Errorf("step start = %+v, want its title and position", e)
+	}
+	if e := events[2]; e.DurationMS != 1500 {
+		t.Errorf("step end took %d ms, want 1500", e.DurationMS)
+	}
+	if e := events[5]; e.Error != "J-Link not found" {
+		t.Errorf("failed step error = %q", e.Error)
+	}
+	if result := fmt.Sprint(events[6].Result); result != "map[board:nrf52840dk]" {
+		t.Errorf("result = %s", result)
+	}
+}
+
+func TestEnableJSONKeepsStdoutParseable(t *testing.T) {
+	dir := t.TempDir()
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	origStdout, origStderr, origOutput, origError := os.Stdout, os.Stderr, color.Output, color.Error
+	t.Cleanup(func() {
+		os.Stdout, os.Stderr, color.Output, color.Error = origStdout, origStderr, origOutput, origError
+		SetSink(NewHumanSink(nil))
+	})
+	os.Stdout, os.Stderr, color.Error = stdout, stderr, stderr
+
+	EnableJSON()
+	if IsHuman() {
+		t.Fatal("IsHuman() after EnableJSON()")
+	}
+	emitAll()
+	PrintBanner()
+	PrintCompletionBanner(time.Second, "org", "token", "bench-01", "dev-1")
+	fmt.Println("output of a subprocess")
+	color.New(color.FgCyan).Println("a colored prompt")
+	stdout.Close()
+	stderr.Close()
+
+	data, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := decodeLines(t, string(data)); len(events) != 7 {
+		t.Errorf("stdout holds %d events, want 7:\n%s", len(events), data)
+	}
+	for _, banner := range []string{"Welcome to Hubble Network", "Installation Complete"} {
+		if bytes.Contains(data, []byte(banner)) {
+			t.Errorf("stdout holds the %q banner", banner)
+		}
+	}
+
+	data, err = os.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"output of a subprocess", "a colored prompt"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("stderr = %q, want the redirected %q", data, want)
+		}
+	}
+}
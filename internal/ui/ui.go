@@ -22,6 +22,9 @@ var (
 
 // PrintBanner prints the welcome banner
 func PrintBanner() {
+	if !IsHuman() {
+		return
+	}
 	cyan.Print(`
 ╔═══════════════════════════════════════════════════════════╗
 ║      Welcome to Hubble Network! Let's get you setup.      ║
@@ -31,32 +34,27 @@ func PrintBanner() {
 
 // PrintStep prints a step indicator
 func PrintStep(step string, current, total int) {
-	fmt.Println()
-	if total > 0 {
-		blue.Printf("[%d/%d] %s\n", current, total, step)
-	} else {
-		blue.Printf("[%d] %s\n", current, step)
-	}
+	StartStep("", step, current, total)
 }
 
 // PrintSuccess prints a success message
 func PrintSuccess(message string) {
-	green.Printf("✓ %s\n", message)
+	Emit(Event{Type: EventMessage, Level: LevelSuccess, Message: message})
 }
 
 // PrintError prints an error message
 func PrintError(message string) {
-	red.Printf("✗ %s\n", message)
+	Emit(Event{Type: EventMessage, Level: LevelError, Message: message})
 }
 
 // PrintWarning prints a warning message
 func PrintWarning(message string) {
-	yellow.Printf("⚠ %s\n", message)
+	Emit(Event{Type: EventMessage, Level: LevelWarning, Message: message})
 }
 
 // PrintInfo prints an info message
 func PrintInfo(message string) {
-	cyan.Printf("ℹ %s\n", message)
+	Emit(Event{Type: EventMessage, Level: LevelInfo, Message: message})
 }
 
 // Global reader for interactive input
@@ -196,7 +194,10 @@ func PromptChoice(prompt string, options []string) int {
 }
 
 // PrintCompletionBanner prints the success completion banner
-func PrintCompletionBanner(duration time.Duration, orgID, apiToken, deviceName string) {
+func PrintCompletionBanner(duration time.Duration, orgID, apiToken, deviceName, deviceID string) {
+	if !IsHuman() {
+		return
+	}
 	green.Print(`
 ╔═══════════════════════════════════════════════════════════╗
 ║     ✓ Installation Complete!                              ║
@@ -209,6 +210,10 @@ func PrintCompletionBanner(duration time.Duration, orgID, apiToken, deviceName s
 	fmt.Println()
 	fmt.Printf("  • Your device \"%s\" is now broadcasting on the Hubble Terrestrial Network\n", deviceName)
 	fmt.Println()
+	if deviceID != "" && deviceID != deviceName {
+		fmt.Printf("  • Device ID: %s\n", deviceID)
+		fmt.Println()
+	}
 	fmt.Println("  • In Sandbox, you will need the Hubble Connect mobile app to scan for device packets")
 	fmt.Println()
 	fmt.Println()
@@ -221,7 +226,10 @@ func PrintCompletionBanner(duration time.Duration, orgID, apiToken, deviceName s
 }
 
 // PrintUniflashCompletionBanner prints the completion banner for TI Uniflash boards
-func PrintUniflashCompletionBanner(duration time.Duration, hexFilePath, boardName, deviceName string) {
+func PrintUniflashCompletionBanner(duration time.Duration, hexFilePath, boardName, deviceName, deviceID string) {
+	if !IsHuman() {
+		return
+	}
 	green.Print(`
 ╔═══════════════════════════════════════════════════════════╗
 ║                  ✓ Hex File Generated!                    ║
@@ -234,6 +242,10 @@ func PrintUniflashCompletionBanner(duration time.Duration, hexFilePath, boardNam
 	fmt.Println()
 	fmt.Printf("  • Your new device is named \"%s\"\n", deviceName)
 	fmt.Println()
+	if deviceID != "" && deviceID != deviceName {
+		fmt.Printf("  • Device ID: %s\n", deviceID)
+		fmt.Println()
+	}
 	fmt.Printf("  • Your hex file for the %s has been generated:\n", boardName)
 	fmt.Println()
 	bold.Printf("    %s\n", hexFilePath)
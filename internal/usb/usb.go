@@ -0,0 +1,205 @@
+// Package usb enumerates the USB devices attached to this machine, so the
+// installer can recognize a development board's debug probe.
+package usb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+)
+
+// Device is a USB device. Vendor and product IDs are four lowercase hex digits.
+type Device struct {
+	VendorID     string `json:"vendor_id"`
+	ProductID    string `json:"product_id"`
+	Serial       string `json:"serial,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Product      string `json:"product,omitempty"`
+}
+
+// String describes the device for the user, e.g. "SEGGER J-Link (1366:1015)"
+func (d Device) String() string {
+	name := strings.TrimSpace(d.Manufacturer + " " + d.Product)
+	if name == "" {
+		name = "USB device"
+	}
+	return fmt.Sprintf("%s (%s:%s)", name, d.VendorID, d.ProductID)
+}
+
+// SysfsRoot is where List reads the Linux device tree
+const SysfsRoot = "/sys"
+
+// List returns the USB devices attached to this machine: from sysfs on Linux,
+// ioreg on macOS, and WMI on Windows
+func List(r platform.Runner, goos string) ([]Device, error) {
+	switch goos {
+	case "linux":
+		return ReadSysfs(SysfsRoot)
+	case "darwin":
+		output, err := r.Output(platform.Command{Path: "ioreg", Args: []string{"-p", "IOUSB", "-l", "-w", "0"}, ReadOnly: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list USB devices with ioreg: %w", err)
+		}
+		return ParseIoreg(string(output)), nil
+	case "windows":
+		output, err := r.Output(platform.Command{Path: "powershell", Args: []string{"-NoProfile", "-Command", wmiQuery}, ReadOnly: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list USB devices with WMI: %w", err)
+		}
+		return ParseWMI(string(output)), nil
+	default:
+		return nil, fmt.Errorf("USB detection is not supported on %s", goos)
+	}
+}
+
+// ReadSysfs reads the USB devices under root/bus/usb/devices. root is "/sys" on a
+// real system; pointing it at a copy of that tree makes the parser testable.
+func ReadSysfs(root string) ([]Device, error) {
+	dir := filepath.Join(root, "bus", "usb", "devices")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var devices []Device
+	for _, entry := range entries {
+		// Entries with a colon (e.g. "1-2:1.0") are interfaces of a device, not devices
+		if strings.Contains(entry.Name(), ":") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		device := Device{
+			VendorID:     readAttr(path, "idVendor"),
+			ProductID:    readAttr(path, "idProduct"),
+			Serial:       readAttr(path, "serial"),
+			Manufacturer: readAttr(path, "manufacturer"),
+			Product:      readAttr(path, "product"),
+		}
+		// Skip entries without IDs and the kernel's virtual root hubs ("usb1")
+		if device.VendorID == "" || device.ProductID == "" || device.VendorID == linuxFoundationVendorID {
+			continue
+		}
+		devices = append(devices, normalize(device))
+	}
+	return devices, nil
+}
+
+// linuxFoundationVendorID is the vendor ID of the kernel's virtual root hubs
+const linuxFoundationVendorID = "1d6b"
+
+// readAttr returns a sysfs attribute of a device, or "" if it does not have it
+func readAttr(devicePath, name string) string {
+	data, err := os.ReadFile(filepath.Join(devicePath, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+var (
+	// ioregDevice matches the line that starts a device in ioreg's tree, e.g.
+	//
+	//	+-o J-Link@01120000  <class IOUSBHostDevice, id 0x100000a3c, registered, matched, active, busy 0 (5 ms), retain 24>
+	ioregDevice = regexp.MustCompile(`\+-o .*<class (IOUSBHostDevice|IOUSBDevice),`)
+
+	// ioregProperty matches a property of the current device, e.g. "idVendor" = 4966
+	ioregProperty = regexp.MustCompile(`"([^"]+)" = (.*)$`)
+)
+
+// ParseIoreg extracts the USB devices from the output of `ioreg -p IOUSB -l -w 0`
+func ParseIoreg(output string) []Device {
+	var devices []Device
+	var current *Device
+	flush := func() {
+		if current != nil && current.VendorID != "" && current.ProductID != "" {
+			devices = append(devices, normalize(*current))
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if ioregDevice.MatchString(line) {
+			flush()
+			current = &Device{}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		match := ioregProperty.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(match[2]), `"`)
+		switch match[1] {
+		case "idVendor":
+			current.VendorID = decimalToHex(value)
+		case "idProduct":
+			current.ProductID = decimalToHex(value)
+		case "USB Serial Number", "kUSBSerialNumberString":
+			current.Serial = value
+		case "USB Vendor Name", "kUSBVendorString":
+			current.Manufacturer = value
+		case "USB Product Name", "kUSBProductString":
+			current.Product = value
+		}
+	}
+	flush()
+	return devices
+}
+
+// decimalToHex converts ioreg's decimal IDs to the four hex digits used elsewhere
+func decimalToHex(value string) string {
+	id, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%04x", id)
+}
+
+// wmiQuery prints one line per USB device as "PNPDeviceID|Manufacturer|Name"
+const wmiQuery = `Get-CimInstance -ClassName Win32_PnPEntity | ` +
+	`Where-Object { $_.PNPDeviceID -like 'USB\VID_*' } | ` +
+	`ForEach-Object { $_.PNPDeviceID + '|' + $_.Manufacturer + '|' + $_.Name }`
+
+// wmiDeviceID matches a USB PNPDeviceID, e.g. USB\VID_1366&PID_1015\000683000001
+var wmiDeviceID = regexp.MustCompile(`(?i)^USB\\VID_([0-9a-f]{4})&PID_([0-9a-f]{4})(&MI_[0-9a-f]{2})?\\(.*)$`)
+
+// ParseWMI extracts the USB devices from the output of wmiQuery
+func ParseWMI(output string) []Device {
+	var devices []Device
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "|", 3)
+		match := wmiDeviceID.FindStringSubmatch(fields[0])
+		// Interfaces of a composite device (&MI_xx) repeat the device itself
+		if match == nil || match[3] != "" {
+			continue
+		}
+
+		device := Device{VendorID: match[1], ProductID: match[2]}
+		// Windows makes up an instance ID containing '&' for devices without a serial number
+		if !strings.Contains(match[4], "&") {
+			device.Serial = match[4]
+		}
+		if len(fields) > 1 {
+			device.Manufacturer = strings.TrimSpace(fields[1])
+		}
+		if len(fields) > 2 {
+			device.Product = strings.TrimSpace(fields[2])
+		}
+		devices = append(devices, normalize(device))
+	}
+	return devices
+}
+
+// normalize lowercases the IDs so that devices compare equal across platforms
+func normalize(d Device) Device {
+	d.VendorID = strings.ToLower(d.VendorID)
+	d.ProductID = strings.ToLower(d.ProductID)
+	return d
+}
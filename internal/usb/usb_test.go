@@ -0,0 +1,79 @@
+package usb_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/usb"
+)
+
+// writeSysfs builds a fake /sys with the given devices and their attributes
+func writeSysfs(t *testing.T, devices map[string]map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, attrs := range devices {
+		dir := filepath.Join(root, "bus", "usb", "devices", name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for attr, value := range attrs {
+			if err := os.WriteFile(filepath.Join(dir, attr), []byte(value+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func TestReadSysfsDetectsBoards(t *testing.T) {
+	root := writeSysfs(t, map[string]map[string]string{
+		// Virtual root hub and an interface of a device: both skipped
+		"usb1":    {"idVendor": "1d6b", "idProduct": "0002"},
+		"1-1:1.0": {"idVendor": "1366", "idProduct": "1015"},
+		// nRF52840 DK
+		"1-1": {"idVendor": "1366", "idProduct": "1015", "serial": "000683123456", "manufacturer": "SEGGER", "product": "J-Link"},
+		// Another Nordic DK: same probe model, outside the nRF52840 DK's serial range
+		"1-2": {"idVendor": "1366", "idProduct": "1015", "serial": "001050123456", "manufacturer": "SEGGER", "product": "J-Link"},
+		// TI LaunchPad: shared by both CC2340 boards
+		"1-3": {"idVendor": "0451", "idProduct": "BEF3", "serial": "L1100ABC", "product": "XDS110"},
+	})
+
+	devices, err := usb.ReadSysfs(root)
+	if err != nil {
+		t.Fatalf("ReadSysfs() = %v", err)
+	}
+	if len(devices) != 3 {
+		t.Fatalf("ReadSysfs() found %v, want the three attached devices", devices)
+	}
+
+	detected := make(map[string][]string)
+	for _, device := range devices {
+		if device.VendorID == "0451" && device.ProductID != "bef3" {
+			t.Errorf("product ID %q was not normalized", device.ProductID)
+		}
+		for _, board := range boards.Default().MatchUSB(device.VendorID, device.ProductID, device.Serial) {
+			detected[device.Serial] = append(detected[device.Serial], board.ID)
+		}
+	}
+	want := map[string][]string{
+		// The nRF21540 DK uses the same J-Link OB without a serial range of its own
+		"000683123456": {"nrf21540dk", "nrf52840dk"},
+		"001050123456": {"nrf21540dk"},
+		// Both CC2340 LaunchPads carry an XDS110, so the menu decides between them
+		"L1100ABC": {"lp_em_cc2340r5", "lp_em_cc2340r53"},
+	}
+	for serial, ids := range want {
+		if got := detected[serial]; !slices.Equal(got, ids) {
+			t.Errorf("%s matched %v, want %v", serial, got, ids)
+		}
+	}
+}
+
+func TestReadSysfsMissingTree(t *testing.T) {
+	if _, err := usb.ReadSysfs(t.TempDir()); err == nil {
+		t.Fatal("ReadSysfs() of an empty root succeeded")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// loggedCommands are the commands whose runs are logged. The others only print
+// information, or, like support-bundle, read the logs themselves.
+var loggedCommands = []string{"install", "flash", "hex", "check", "probes", "profile", "devices", "bundle", "uninstall", "doctor"}
+
+// keptLogs is how many run logs are kept; the oldest are deleted as new runs start
+const keptLogs = 20
+
+// logDir returns where run logs are kept, in the user cache directory
+func logDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "hubble", "logs"), nil
+}
+
+// startLog logs this run of command to a new file and deletes the oldest logs.
+// Logging is an aid for support, so the run goes on unlogged if it fails.
+func startLog(command string) {
+	dir, err := logDir()
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	name := fmt.Sprintf("%s-%s.log", time.Now().Format("20060102-150405.000"), command)
+	if err := ui.OpenLog(filepath.Join(dir, name)); err != nil {
+		return
+	}
+	ui.Logf("hubble-install %s (commit %s) on %s/%s: %s", Version, Commit, runtime.GOOS, runtime.GOARCH, command)
+
+	logs, err := recentLogs(dir)
+	if err == nil && len(logs) > keptLogs {
+		for _, old := range logs[keptLogs:] {
+			os.Remove(old)
+		}
+	}
+}
+
+// recentLogs returns the run logs in dir, newest first
+func recentLogs(dir string) ([]string, error) {
+	logs, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	// Names start with the time the run started
+	slices.Sort(logs)
+	slices.Reverse(logs)
+	return logs, nil
+}
+
+// endLog records how the run ended and closes its log. After a failure it points
+// the user at the log.
+func endLog(code int) {
+	path := ui.LogPath()
+	if path == "" {
+		return
+	}
+	if code != exitOK && code != exitCancelled {
+		ui.PrintInfo(fmt.Sprintf("The full log of this run is in %s", path))
+		ui.PrintInfo("Run 'hubble-install support-bundle' to pack it up for a support ticket")
+	}
+	ui.Logf("exit %d", code)
+	ui.CloseLog()
+}
@@ -1,267 +1,231 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
-	"time"
 
 	"github.com/HubbleNetwork/hubble-install/internal/boards"
-	"github.com/HubbleNetwork/hubble-install/internal/config"
 	"github.com/HubbleNetwork/hubble-install/internal/platform"
 	"github.com/HubbleNetwork/hubble-install/internal/ui"
 )
 
-func main() {
-	// Print welcome banner
-	ui.PrintBanner()
-	fmt.Println()
+// Build information, injected via -ldflags at release time
+var (
+	Version = "dev"
+	Commit  = "none"
+	Date    = "unknown"
+)
 
-	// Show what will happen
-	ui.PrintInfo("This installer will:")
-	fmt.Println("  • Confirm your developer board model")
-	fmt.Println("  • Check for and install required dependencies")
-	fmt.Println("  • Configure your Hubble credentials")
-	fmt.Println("  • Register your board to your organization, and give it a name")
-	fmt.Println("  • Provision your board, or generate a hex file for you to flash")
-	fmt.Println()
+// Process exit codes. These are part of the command line interface: scripts branch
+// on them, so existing values must never change meaning.
+const (
+	exitOK                = 0
+	exitError             = 1 // Any failure not listed below, including invalid flags
+	exitReboot            = 2 // A system reboot is required before continuing
+	exitDependencyMissing = 3 // Required tools are missing and were not installed
+	exitProbeNotFound     = 4 // No J-Link probe, or not the requested one, is connected
+	exitFlashFailed       = 5 // The flashing tool failed to program the board or write the hex file
+	exitNetwork           = 6 // The Hubble API or a download server could not be reached
+	exitCredentialInvalid = 7 // The Org ID or API token is malformed or was rejected
+	exitCancelled         = 8 // The user declined a step the run cannot continue without
+)
 
-	// Prompt user to continue
-	if !ui.PromptYesNo("Ready to install?", true) {
-		ui.PrintWarning("Installation cancelled")
-		os.Exit(0)
-	}
-	fmt.Println()
+const usageText = `Usage: hubble-install [command] [flags]
+
+Commands:
+  install   Run the full guided installation (default)
+  flash     Flash a board directly with your Hubble credentials
+  hex       Generate a hex file for a UniFlash board
+  check     Check that the dependencies for a board are installed
+  boards    List supported developer boards
+  probes    List connected J-Link probes
+  profile   Manage saved credential profiles
+  devices   List, rename, delete, and register devices in your organization
+  bundle    Create or verify an offline bundle for machines without internet
+  uninstall Remove the dependencies the installer added
+  doctor    Diagnose problems with dependencies, probes and the network
+  support-bundle
+            Pack logs, diagnostics and redacted settings into a zip for support
+  version   Print the installer version
+
+Run 'hubble-install <command> -h' for the flags of a command.
+
+Exit codes: 0 success, 1 error, 2 reboot required, 3 dependency missing,
+4 probe not found, 5 flash failed, 6 network unreachable, 7 invalid
+credentials, 8 cancelled.
+
+Credentials are read, in order of precedence, from --profile,
+HUBBLE_CREDENTIALS (ignored when --org-id is given), --org-id or HUBBLE_ORG_ID
+with HUBBLE_API_TOKEN, and the default saved profile, before falling back to
+interactive prompts. --org-id and --profile cannot be combined.
+`
 
-	// Start timer for the installation
-	startTime := time.Now()
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	// Detect platform
-	installer, err := platform.GetInstaller()
-	if err != nil {
-		ui.PrintError(fmt.Sprintf("Platform detection failed: %v", err))
-		os.Exit(1)
+// run dispatches to the requested subcommand and returns the process exit code
+func run(args []string) int {
+	// Running without a subcommand keeps the original guided behavior
+	command := "install"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	// Check for pending reboot (especially important on Windows)
-	if err := installer.CheckPendingReboot(); err != nil {
-		fmt.Println()
-		ui.PrintWarning("═══════════════════════════════════════════════════════════════")
-		ui.PrintWarning("  SYSTEM REBOOT REQUIRED")
-		ui.PrintWarning("═══════════════════════════════════════════════════════════════")
-		fmt.Println()
-		ui.PrintWarning("A previous installation requires a system reboot before continuing.")
-		ui.PrintInfo(fmt.Sprintf("Reason: %v", err))
-		fmt.Println()
-		ui.PrintInfo("Please reboot your computer and run this installer again.")
-		fmt.Println()
-		os.Exit(2)
+	if !slices.Contains(loggedCommands, command) {
+		return dispatch(command, args)
 	}
+	startLog(command)
+	code := dispatch(command, args)
+	endLog(code)
+	return code
+}
 
-	// =========================================================================
-	// Step 1: Get credentials (may include pre-configured board)
-	// =========================================================================
-	currentStep := 1
-	totalSteps := 0
-	ui.PrintStep("Configuring credentials", currentStep, totalSteps)
-
-	cfg, preConfigured, err := config.PromptForConfig()
-	if err != nil {
-		ui.PrintError(fmt.Sprintf("Configuration failed: %v", err))
-		os.Exit(1)
+// dispatch runs the named subcommand and returns the process exit code
+func dispatch(command string, args []string) int {
+	switch command {
+	case "install":
+		return runInstall(args)
+	case "flash":
+		return runFlash(args)
+	case "hex":
+		return runHex(args)
+	case "check":
+		return runCheck(args)
+	case "boards":
+		return runBoards(args)
+	case "probes":
+		return runProbes(args)
+	case "profile":
+		return runProfile(args)
+	case "devices":
+		return runDevices(args)
+	case "bundle":
+		return runBundle(args)
+	case "uninstall":
+		return runUninstall(args)
+	case "doctor":
+		return runDoctor(args)
+	case "support-bundle":
+		return runSupportBundle(args)
+	case "version":
+		fmt.Printf("hubble-install %s (commit %s, built %s)\n", Version, Commit, Date)
+		return exitOK
+	case "help":
+		fmt.Print(usageText)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", command)
+		fmt.Fprint(os.Stderr, usageText)
+		return exitError
 	}
+}
 
-	if preConfigured {
-		fmt.Println()
-		ui.PrintSuccess("We've handled your setup details")
-		fmt.Println()
-		ui.PrintInfo("We've pre-filled your credentials for this command.")
-		fmt.Println()
-		ui.PrintInfo("Your Hubble Org ID and API Token are used to register your board to your organization.")
-		fmt.Println()
-	}
+// options holds the flags shared by the installer subcommands
+type options struct {
+	board       string
+	deviceName  string
+	orgID       string
+	yes         bool
+	manifest    string
+	report      string
+	probeSerial string
+	profile     string
+	skipVerify  bool
+	output      string
+	dryRun      bool
+	boardsFile  string
+	toolVersion string
+	bundle      string
+
+	toolVersionSet bool            // --tool-version was given, rather than defaulted
+	catalog        *boards.Catalog // Boards to choose from: the --boards-file catalog or the built-in one
+}
 
-	// =========================================================================
-	// Step 2: Select board (if not pre-configured)
-	// =========================================================================
-	currentStep++
-	ui.PrintStep("Selecting developer board", currentStep, totalSteps)
+// newFlagSet creates a flag set for a subcommand with the shared flags registered
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&opts.board, "board", "", "board ID to use (see 'hubble-install boards')")
+	fs.StringVar(&opts.boardsFile, "boards-file", "", "board catalog to use instead of the built-in one (file path or URL)")
+	fs.StringVar(&opts.deviceName, "device-name", "", "name to register the device under")
+	fs.StringVar(&opts.orgID, "org-id", "", "Hubble Org ID (overrides HUBBLE_ORG_ID and HUBBLE_CREDENTIALS)")
+	fs.StringVar(&opts.profile, "profile", "", "saved credential profile to use (see 'hubble-install profile')")
+	fs.BoolVar(&opts.skipVerify, "skip-verify", false, "do not check the credentials against the Hubble API before flashing (implied by --bundle)")
+	fs.BoolVar(&opts.yes, "yes", false, "assume yes for all prompts and never read from the terminal")
+	fs.StringVar(&opts.probeSerial, "probe-serial", "", "serial number of the J-Link probe to flash when several are attached")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "print the commands, downloads and changes the installer would make without making them")
+	fs.StringVar(&opts.output, "output", "human", "output format: human, or json for one JSON event per line on stdout")
+	fs.StringVar(&opts.toolVersion, "tool-version", platform.DefaultToolVersion, "pyhubbledemo release to flash with, or \"latest\" for the newest on PyPI")
+	fs.StringVar(&opts.bundle, "bundle", "", "offline bundle to install and flash from instead of downloading (see 'hubble-install bundle')")
+	fs.StringVar(&opts.manifest, "manifest", "", "CSV file listing boards to provision in one batch")
+	fs.StringVar(&opts.report, "report", "", "where to write the batch report (default <manifest>-report.csv)")
+	return fs
+}
 
-	var selectedBoard boards.Board
-	if cfg.Board != "" {
-		// Board was pre-configured via credentials
-		board, err := boards.GetBoard(cfg.Board)
-		if err != nil {
-			ui.PrintError(fmt.Sprintf("Invalid pre-configured board: %v", err))
-			os.Exit(1)
-		}
-		selectedBoard = *board
-		ui.PrintSuccess(fmt.Sprintf("Using pre-configured board: %s", selectedBoard.Name))
-	} else {
-		// Prompt user to select a board
-		boardOptions := make([]string, len(boards.AvailableBoards))
-		for i, board := range boards.AvailableBoards {
-			boardOptions[i] = fmt.Sprintf("%s - %s (%s)", board.Name, board.Description, board.Vendor)
+// parseOptions parses the shared flags, then applies the requested output format
+// and board catalog
+func parseOptions(fs *flag.FlagSet, opts *options, args []string) int {
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "tool-version" {
+			opts.toolVersionSet = true
 		}
-
-		selectedIndex := ui.PromptChoice("Available developer boards:", boardOptions)
-		selectedBoard = boards.AvailableBoards[selectedIndex]
-		cfg.Board = selectedBoard.ID
-
-		ui.PrintSuccess(fmt.Sprintf("Selected: %s", selectedBoard.Name))
+	})
+	if code := setOutput(opts.output); code >= 0 {
+		return code
 	}
-
-	fmt.Println()
-	if selectedBoard.RequiresJLink() {
-		ui.PrintInfo("This board uses SEGGER J-Link for direct flashing.")
-		ui.PrintWarning("Make sure your board is connected via USB with a data-capable cable.")
-	} else {
-		ui.PrintInfo("This board uses TI Uniflash. A hex file will be generated for you.")
-		ui.PrintInfo("You'll need Uniflash installed to complete the flashing process.")
+	if err := platform.ValidateToolVersion(opts.toolVersion); err != nil {
+		ui.PrintError(err.Error())
+		return exitError
 	}
-	fmt.Println()
-
-	// =========================================================================
-	// Step 3: Check prerequisites (based on selected board)
-	// =========================================================================
-	currentStep++
-	ui.PrintStep("Checking prerequisites", currentStep, totalSteps)
+	catalog, code := loadBoards(opts.boardsFile)
+	opts.catalog = catalog
+	return code
+}
 
-	requiredDeps := selectedBoard.GetDependencies()
-	missing, err := installer.CheckPrerequisites(requiredDeps)
-	if err != nil {
-		ui.PrintError(fmt.Sprintf("Prerequisites check failed: %v", err))
-		os.Exit(1)
+// loadBoards returns the board catalog read from source, or the built-in one
+// when no catalog file is given
+func loadBoards(source string) (*boards.Catalog, int) {
+	if source == "" {
+		return boards.Default(), -1
 	}
-
-	totalSteps = 4
-	if len(missing) > 0 {
-		totalSteps++
+	catalog, err := boards.LoadCatalog(source)
+	if err != nil {
+		ui.PrintError(err.Error())
+		return nil, exitError
 	}
+	return catalog, -1
+}
 
-	if len(missing) > 0 {
-		ui.PrintWarning("Missing dependencies detected:")
-		for _, dep := range missing {
-			fmt.Printf("  • %s: %s\n", dep.Name, dep.Status)
-		}
-		fmt.Println()
-
-		if !ui.PromptYesNo("Would you like to install missing dependencies?", true) {
-			ui.PrintError("Cannot proceed without dependencies")
-			os.Exit(1)
-		}
-	} else {
-		ui.PrintSuccess("All prerequisites satisfied")
+// setOutput switches the ui package to the named output format
+func setOutput(format string) int {
+	switch format {
+	case "human":
+	case "json":
+		ui.EnableJSON()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown output format: %s (expected human or json)\n", format)
+		return exitError
 	}
+	return -1
+}
 
-	// =========================================================================
-	// Step 4: Install dependencies (only if needed)
-	// =========================================================================
-	if len(missing) > 0 {
-		currentStep++
-		ui.PrintStep("Installing dependencies", currentStep, totalSteps)
-
-		// Check if we need to install package manager first
-		needsPackageManager := false
-		for _, dep := range missing {
-			if dep.Name == "Homebrew" {
-				needsPackageManager = true
-				break
-			}
-		}
-
-		if needsPackageManager {
-			if err := installer.InstallPackageManager(); err != nil {
-				ui.PrintError(fmt.Sprintf("Package manager installation failed: %v", err))
-				os.Exit(1)
-			}
-		}
-
-		// Install board-specific dependencies
-		if err := installer.InstallDependencies(requiredDeps); err != nil {
-			// Check if this is a reboot required error
-			if strings.Contains(err.Error(), "requires a system reboot") || strings.Contains(err.Error(), "RebootRequired") {
-				fmt.Println()
-				ui.PrintWarning("═══════════════════════════════════════════════════════════════")
-				ui.PrintWarning("  SYSTEM REBOOT REQUIRED")
-				ui.PrintWarning("═══════════════════════════════════════════════════════════════")
-				fmt.Println()
-				ui.PrintSuccess("Dependencies were installed successfully!")
-				fmt.Println()
-				ui.PrintWarning("However, system components were updated that require a reboot")
-				ui.PrintWarning("before you can continue.")
-				fmt.Println()
-				ui.PrintInfo("What to do next:")
-				ui.PrintInfo("  1. Reboot your computer")
-				ui.PrintInfo("  2. Run this installer again after rebooting")
-				ui.PrintInfo("  3. The installer will detect what's already installed and continue")
-				fmt.Println()
-				ui.PrintInfo("Note: If PowerShell doesn't work after reboot, use Command Prompt (cmd.exe)")
-				fmt.Println()
-				os.Exit(2) // Exit code 2 indicates reboot required
-			}
-			ui.PrintError(fmt.Sprintf("Dependency installation failed: %v", err))
-			os.Exit(1)
+// parseFlags parses args into fs, returning a non-negative exit code if the command should stop
+func parseFlags(fs *flag.FlagSet, args []string) int {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
 		}
-
-		ui.PrintSuccess("All dependencies installed")
+		return exitError
 	}
-
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		ui.PrintError(fmt.Sprintf("Invalid configuration: %v", err))
-		os.Exit(1)
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return exitError
 	}
-
-	// =========================================================================
-	// Final Step: Flash board or generate hex file
-	// =========================================================================
-	currentStep++
-
-	if selectedBoard.RequiresJLink() {
-		// J-Link path: Direct flash
-		if !ui.PromptYesNo(fmt.Sprintf("Would you like to flash your %s now?", selectedBoard.Name), true) {
-			ui.PrintWarning("Flashing skipped. You can flash later using:")
-			fmt.Printf("  uv tool run --from pyhubbledemo hubbledemo flash %s -o %s -t <your_token>\n", cfg.Board, cfg.OrgID)
-			os.Exit(0)
-		}
-
-		// Prompt for optional device name
-		deviceName := ui.PromptOptionalInput("What should the device name be?")
-
-		ui.PrintStep("Flashing board", currentStep, totalSteps)
-		result, err := installer.FlashBoard(cfg.OrgID, cfg.APIToken, cfg.Board, deviceName)
-		if err != nil {
-			ui.PrintError(fmt.Sprintf("Board flashing failed: %v", err))
-			os.Exit(1)
-		}
-
-		// Print J-Link completion banner
-		duration := time.Since(startTime)
-		ui.PrintCompletionBanner(duration, cfg.OrgID, cfg.APIToken, result.DeviceName)
-
-	} else {
-		// Uniflash path: Generate hex file
-		if !ui.PromptYesNo(fmt.Sprintf("Would you like to generate the hex file for your %s now?", selectedBoard.Name), true) {
-			ui.PrintWarning("Hex generation skipped. You can generate later using:")
-			fmt.Printf("  uv tool run --from pyhubbledemo hubbledemo flash %s -o %s -t <your_token>\n", cfg.Board, cfg.OrgID)
-			os.Exit(0)
-		}
-
-		// Prompt for optional device name
-		deviceName := ui.PromptOptionalInput("What should the device name be?")
-
-		ui.PrintStep("Generating hex file", currentStep, totalSteps)
-		result, err := installer.GenerateHexFile(cfg.OrgID, cfg.APIToken, cfg.Board, deviceName)
-		if err != nil {
-			ui.PrintError(fmt.Sprintf("Hex file generation failed: %v", err))
-			os.Exit(1)
-		}
-
-		// Print Uniflash completion banner
-		duration := time.Since(startTime)
-		ui.PrintUniflashCompletionBanner(duration, result.HexFilePath, selectedBoard.Name, deviceName)
-	}
-
-	os.Exit(0)
+	return -1
 }
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/HubbleNetwork/hubble-install/internal/config"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+const profileUsageText = `Usage: hubble-install profile <command> [flags]
+
+Commands:
+  add <name>      Save credentials under a profile name
+  list            List saved profiles
+  remove <name>   Delete a saved profile
+  use <name>      Make a profile the default for future runs
+
+Profiles are stored in your user config directory, readable only by you.
+`
+
+// runProfile manages saved credential profiles
+func runProfile(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, profileUsageText)
+		return exitError
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "add":
+		return runProfileAdd(args)
+	case "list":
+		return runProfileList(args)
+	case "remove":
+		return runProfileRemove(args)
+	case "use":
+		return runProfileUse(args)
+	case "help", "-h", "--help":
+		fmt.Print(profileUsageText)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown profile command: %s\n\n", command)
+		fmt.Fprint(os.Stderr, profileUsageText)
+		return exitError
+	}
+}
+
+// runProfileAdd saves credentials from flags, the environment, or prompts under a profile name
+func runProfileAdd(args []string) int {
+	fs := flag.NewFlagSet("profile add", flag.ContinueOnError)
+	orgID := fs.String("org-id", "", "Hubble Org ID (default HUBBLE_ORG_ID, or prompt)")
+	use := fs.Bool("use", false, "make this the default profile")
+	tokenStore := fs.String("token-store", "", "where to keep the API token: keyring, file, or plaintext (default keyring, or file if no keyring is available)")
+	name, code := parseProfileArgs(fs, args)
+	if code >= 0 {
+		return code
+	}
+
+	backend, err := config.BackendFor(*tokenStore)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Invalid token store: %v", err))
+		return exitError
+	}
+
+	store, err := config.LoadStore()
+	if err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+
+	profile := config.Profile{OrgID: *orgID, APIToken: os.Getenv("HUBBLE_API_TOKEN")}
+	if profile.OrgID == "" {
+		profile.OrgID = os.Getenv("HUBBLE_ORG_ID")
+	}
+	if profile.OrgID == "" {
+		profile.OrgID = strings.TrimSpace(ui.PromptInput("Enter your Hubble Org ID"))
+	}
+	if profile.APIToken == "" {
+		profile.APIToken = strings.TrimSpace(ui.PromptPassword("Enter your Hubble API Token (hidden)"))
+	}
+
+	if err := store.Add(name, profile, backend); err != nil {
+		ui.PrintError(fmt.Sprintf("Invalid profile: %v", err))
+		return exitError
+	}
+	if *use {
+		store.Use(name)
+	}
+	if err := store.Save(); err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Saved profile %q to %s", name, store.Path()))
+	if backend != nil {
+		ui.PrintInfo(fmt.Sprintf("API token stored in %s", backend.Name()))
+	} else {
+		ui.PrintWarning("API token stored in plain text in the profile file")
+	}
+	if store.Current == name {
+		ui.PrintInfo(fmt.Sprintf("%q is the default profile", name))
+	}
+	return exitOK
+}
+
+// runProfileList prints the saved profiles with their tokens masked
+func runProfileList(args []string) int {
+	fs := flag.NewFlagSet("profile list", flag.ContinueOnError)
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	store, err := config.LoadStore()
+	if err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+	if len(store.Profiles) == 0 {
+		ui.PrintInfo("No saved profiles. Add one with 'hubble-install profile add <name>'")
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tNAME\tORG ID\tAPI TOKEN")
+	for _, name := range store.Names() {
+		profile := store.Profiles[name]
+		marker := ""
+		if name == store.Current {
+			marker = "*"
+		}
+		token := config.MaskToken(profile.APIToken)
+		if profile.TokenStore != "" {
+			token = "(in " + profile.TokenStore + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", marker, name, profile.OrgID, token)
+	}
+	w.Flush()
+
+	return exitOK
+}
+
+// runProfileRemove deletes a saved profile
+func runProfileRemove(args []string) int {
+	fs := flag.NewFlagSet("profile remove", flag.ContinueOnError)
+	name, code := parseProfileArgs(fs, args)
+	if code >= 0 {
+		return code
+	}
+
+	store, err := config.LoadStore()
+	if err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+	if err := store.Remove(name); err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+	if err := store.Save(); err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Removed profile %q", name))
+	return exitOK
+}
+
+// runProfileUse sets the default profile
+func runProfileUse(args []string) int {
+	fs := flag.NewFlagSet("profile use", flag.ContinueOnError)
+	name, code := parseProfileArgs(fs, args)
+	if code >= 0 {
+		return code
+	}
+
+	store, err := config.LoadStore()
+	if err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+	if err := store.Use(name); err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+	if err := store.Save(); err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Default profile is now %q", name))
+	return exitOK
+}
+
+// parseProfileArgs parses flags followed or preceded by a single profile name argument
+func parseProfileArgs(fs *flag.FlagSet, args []string) (string, int) {
+	// Allow the name before the flags ("add staging --use") as well as after
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return "", exitOK
+		}
+		return "", exitError
+	}
+
+	rest := fs.Args()
+	if name == "" && len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
+	}
+	if name == "" || len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "Usage: hubble-install %s <name>\n", fs.Name())
+		return "", exitError
+	}
+	return name, -1
+}
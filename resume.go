@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/HubbleNetwork/hubble-install/internal/journal"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// journaledSteps describes the steps recorded in the journal, by step ID. Flashing
+// and hex generation are not journaled: finishing them finishes the run.
+var journaledSteps = map[string]string{
+	"credentials":   "configuring credentials",
+	"board":         "selecting the board",
+	"prerequisites": "checking prerequisites",
+	"install":       "installing dependencies",
+}
+
+// resumeJournal offers to resume an interrupted run of command, filling in the
+// choices made then wherever opts leaves them open, and returns the journal to
+// record this run in. Dry runs and batches are not journaled.
+func resumeJournal(opts *options, command string) *journal.Journal {
+	if opts.dryRun || opts.manifest != "" {
+		return nil
+	}
+	path, err := journal.DefaultPath()
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Progress will not be saved for resuming: %v", err))
+		return nil
+	}
+
+	previous, err := journal.Load(path)
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Ignoring the saved installation state: %v", err))
+	}
+	if previous == nil || previous.Command != command || previous.LastStep() == "" {
+		return journal.New(path, command)
+	}
+
+	stopped := journaledSteps[previous.LastStep()]
+	if board, err := opts.catalog.Board(previous.Board); err == nil {
+		stopped += " for the " + board.Name
+	}
+	if previous.IsPending(journal.PendingReboot) {
+		stopped += ", waiting for a reboot"
+	}
+	if !opts.yes {
+		ui.PrintInfo(fmt.Sprintf("A previous run stopped after %s.", stopped))
+		ui.PrintInfo(resumePlan(previous))
+		if !ui.PromptYesNo("Resume it?", true) {
+			return journal.New(path, command)
+		}
+	}
+
+	applyJournal(opts, previous)
+	ui.PrintInfo(fmt.Sprintf("Resuming the previous run, which stopped after %s", stopped))
+	if opts.yes {
+		ui.PrintInfo(resumePlan(previous))
+	}
+	return previous
+}
+
+// resumePlan explains what resuming j does. Only the dependency installation is
+// skipped; the other steps run again with the choices j recorded.
+func resumePlan(j *journal.Journal) string {
+	var choices []string
+	if j.Board != "" {
+		choices = append(choices, "board")
+	}
+	switch {
+	case j.Profile != "":
+		choices = append(choices, "credential profile")
+	case j.OrgID != "":
+		choices = append(choices, "Org ID")
+	}
+	if j.DeviceName != "" {
+		choices = append(choices, "device name")
+	}
+	if j.ToolVersion != "" {
+		choices = append(choices, "pyhubbledemo release")
+	}
+	if j.Bundle != "" {
+		choices = append(choices, "offline bundle")
+	}
+
+	plan := "Resuming"
+	if len(choices) > 0 {
+		plan += " reuses its " + joinChoices(choices)
+		if j.Done("install") || j.IsPending(journal.PendingInstall) {
+			plan += " and"
+		}
+	}
+	final := "flashing"
+	if j.Command == "hex" {
+		final = "generating the hex file"
+	}
+	switch {
+	case j.Done("install"):
+		return plan + " skips the dependency installation it finished; registering the device and " + final + " run again."
+	case j.IsPending(journal.PendingInstall):
+		return plan + " finishes its dependency installation without asking again; every other step runs again."
+	case len(choices) > 0:
+		return plan + "; every step runs again."
+	default:
+		return "Resuming runs every step again."
+	}
+}
+
+// joinChoices lists choices in prose, e.g. "board, Org ID and device name"
+func joinChoices(choices []string) string {
+	if len(choices) == 1 {
+		return choices[0]
+	}
+	return strings.Join(choices[:len(choices)-1], ", ") + " and " + choices[len(choices)-1]
+}
+
+// applyJournal fills in the choices recorded in j that opts leaves open
+func applyJournal(opts *options, j *journal.Journal) {
+	if opts.board == "" {
+		opts.board = j.Board
+	}
+	if opts.deviceName == "" {
+		opts.deviceName = j.DeviceName
+	}
+	if opts.orgID == "" && opts.profile == "" {
+		opts.profile = j.Profile
+		if j.Profile == "" {
+			opts.orgID = j.OrgID
+		}
+	}
+	if !opts.toolVersionSet && j.ToolVersion != "" {
+		opts.toolVersion = j.ToolVersion
+	}
+	if opts.bundle == "" {
+		opts.bundle = j.Bundle
+	}
+}
+
+// recordStep records a finished step in the journal, if it is one that a resumed
+// run can skip
+func (s *session) recordStep(id string) {
+	if s.journal == nil {
+		return
+	}
+	if _, ok := journaledSteps[id]; !ok {
+		return
+	}
+
+	switch id {
+	case "board":
+		// Dependencies checked for another board say nothing about this one
+		if previous, err := s.opts.catalog.Board(s.journal.Board); err == nil && previous.ID != s.board.ID {
+			s.journal.Forget("prerequisites", "install")
+			s.journal.Resolve(journal.PendingInstall)
+		}
+	case "install":
+		s.journal.Resolve(journal.PendingInstall)
+	}
+	s.journal.Complete(id)
+	s.saveJournal()
+}
+
+// addPending records an action the run is waiting on in the journal
+func (s *session) addPending(action string) {
+	if s.journal == nil {
+		return
+	}
+	s.journal.AddPending(action)
+	s.saveJournal()
+}
+
+// saveJournal records the choices made so far and writes the journal. Losing the
+// journal only loses the ability to resume, so a failure is reported once and
+// the run continues without it.
+func (s *session) saveJournal() {
+	j := s.journal
+	if s.cfg != nil {
+		j.Profile = s.cfg.Profile
+		j.OrgID = ""
+		if s.cfg.Profile == "" {
+			j.OrgID = s.cfg.OrgID
+		}
+	}
+	if s.board.ID != "" {
+		j.Board = s.board.ID
+	}
+	if s.opts.deviceName != "" {
+		j.DeviceName = s.opts.deviceName
+	}
+	j.ToolVersion = s.opts.toolVersion
+	if s.toolVersion != "" {
+		j.ToolVersion = s.toolVersion
+	}
+	j.Bundle = s.opts.bundle
+	if j.Bundle != "" {
+		if abs, err := filepath.Abs(j.Bundle); err == nil {
+			j.Bundle = abs
+		}
+	}
+
+	if err := j.Save(); err != nil {
+		ui.PrintWarning(fmt.Sprintf("Progress will not be saved for resuming: %v", err))
+		s.journal = nil
+	}
+}
+
+// exit ends a journaled run that finished without error, and returns the exit
+// code for err
+func (s *session) exit(err error) int {
+	if err == nil && s.journal != nil {
+		if err := s.journal.Remove(); err != nil {
+			ui.PrintWarning(err.Error())
+		}
+	}
+	return exitCodeFor(err)
+}
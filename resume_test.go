@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/journal"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+)
+
+const (
+	testOrgID    = "0f61efd0-24a7-4a2e-ae0f-8549d14ed901"
+	testAPIToken = "eb31d24113fadb77c6d89d65a8007c0eed3595e2255aaf1d7d81783900ab33be4332457a27861f67cc78fe930ea52941"
+)
+
+// stepInstaller is an Installer whose steps fail as scripted and that counts
+// the dependency checks and installations it was asked for
+type stepInstaller struct {
+	checkErr   error
+	installErr error
+	checked    int
+	installed  int
+}
+
+func (i *stepInstaller) Name() string                   { return "test" }
+func (i *stepInstaller) CheckPendingReboot() error      { return nil }
+func (i *stepInstaller) InstallPackageManager() error   { return nil }
+func (i *stepInstaller) TakeChanges() []platform.Change { return nil }
+
+func (i *stepInstaller) CheckPrerequisites(deps []string) ([]platform.MissingDependency, error) {
+	i.checked++
+	if i.checkErr != nil {
+		return nil, i.checkErr
+	}
+	return []platform.MissingDependency{{Name: "uv", Status: "Not installed"}}, nil
+}
+
+func (i *stepInstaller) InstallDependencies(deps []string) error {
+	i.installed++
+	return i.installErr
+}
+
+func (i *stepInstaller) FlashBoard(req platform.FlashRequest) (*platform.FlashResult, error) {
+	return nil, errors.New("not flashed in tests")
+}
+
+func (i *stepInstaller) GenerateHexFile(req platform.FlashRequest) (*platform.FlashResult, error) {
+	return nil, errors.New("not generated in tests")
+}
+
+// isolateConfig keeps the journal, profiles and install manifest of a test in a
+// temporary directory and supplies credentials through the environment
+func isolateConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("APPDATA", dir)
+	t.Setenv("HUBBLE_CREDENTIALS", "")
+	t.Setenv("HUBBLE_ORG_ID", testOrgID)
+	t.Setenv("HUBBLE_API_TOKEN", testAPIToken)
+}
+
+// prepareFlash runs the steps before flashing, as the flash command does
+func prepareFlash(opts *options, installer platform.Installer) (*session, error) {
+	opts.catalog = boards.Default()
+	j := resumeJournal(opts, "flash")
+	s := &session{opts: opts, runner: platformtest.NewRunner(), installer: installer, journal: j, mode: modeFlash}
+	return s, s.prepare()
+}
+
+func TestResumeAfterInterruption(t *testing.T) {
+	tests := []struct {
+		name        string
+		board       string // --board of the interrupted run
+		installer   *stepInstaller
+		noToken     bool
+		completed   []string // Steps the interrupted run recorded
+		pending     []string
+		resumeBoard string // --board of the resumed run, when the journal has none
+		checked     int    // Dependency checks and installations of the resumed run
+		installed   int
+		plan        string
+	}{
+		{
+			name:        "during credentials",
+			board:       "nrf52840dk",
+			installer:   &stepInstaller{},
+			noToken:     true,
+			completed:   []string{},
+			resumeBoard: "nrf52840dk",
+			checked:     1,
+			installed:   1,
+		},
+		{
+			name:        "during board selection",
+			board:       "no-such-board",
+			installer:   &stepInstaller{},
+			completed:   []string{"credentials"},
+			resumeBoard: "nrf52840dk",
+			checked:     1,
+			installed:   1,
+			plan:        "Resuming reuses its Org ID",
+		},
+		{
+			name:      "during the prerequisites check",
+			board:     "nrf52840dk",
+			installer: &stepInstaller{checkErr: errors.New("package manager not found")},
+			completed: []string{"credentials", "board"},
+			checked:   1,
+			installed: 1,
+			plan:      "Resuming reuses its board",
+		},
+		{
+			name:      "during installation",
+			board:     "nrf52840dk",
+			installer: &stepInstaller{installErr: errors.New("download failed")},
+			completed: []string{"credentials", "board", "prerequisites"},
+			pending:   []string{journal.PendingInstall},
+			checked:   1,
+			installed: 1,
+			plan:      "finishes its dependency installation without asking again",
+		},
+		{
+			name:      "for a reboot during installation",
+			board:     "nrf52840dk",
+			installer: &stepInstaller{installErr: &platform.RebootRequiredError{Message: "reboot to finish"}},
+			completed: []string{"credentials", "board", "prerequisites"},
+			pending:   []string{journal.PendingInstall, journal.PendingReboot},
+			checked:   1,
+			installed: 1,
+			plan:      "finishes its dependency installation",
+		},
+		{
+			name:      "after installation",
+			board:     "nrf52840dk",
+			installer: &stepInstaller{},
+			completed: []string{"credentials", "board", "prerequisites", "install"},
+			plan:      "skips the dependency installation it finished; registering the device and flashing run again",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateConfig(t)
+			if tt.noToken {
+				t.Setenv("HUBBLE_API_TOKEN", "")
+			}
+
+			// The interrupted run stops at the failing step, or before flashing
+			first, _ := prepareFlash(&options{board: tt.board, yes: true, skipVerify: true}, tt.installer)
+			saved, err := journal.Load(first.journal.Path())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved == nil {
+				saved = journal.New(first.journal.Path(), "flash")
+			}
+			if !slices.Equal(saved.Completed, tt.completed) || !slices.Equal(saved.Pending, tt.pending) {
+				t.Fatalf("journal recorded %v pending %v, want %v pending %v", saved.Completed, saved.Pending, tt.completed, tt.pending)
+			}
+			if tt.plan != "" && !strings.Contains(resumePlan(saved), tt.plan) {
+				t.Errorf("resumePlan() = %q, want it to say %q", resumePlan(saved), tt.plan)
+			}
+
+			t.Setenv("HUBBLE_API_TOKEN", testAPIToken)
+			installer := &stepInstaller{}
+			resumed, err := prepareFlash(&options{board: tt.resumeBoard, yes: true, skipVerify: true}, installer)
+			if err != nil {
+				t.Fatalf("resumed run failed: %v", err)
+			}
+			if resumed.board.ID != "nrf52840dk" {
+				t.Errorf("resumed run selected %q, want nrf52840dk", resumed.board.ID)
+			}
+			if installer.checked != tt.checked || installer.installed != tt.installed {
+				t.Errorf("resumed run checked dependencies %d times and installed them %d times, want %d and %d",
+					installer.checked, installer.installed, tt.checked, tt.installed)
+			}
+			if !resumed.journal.Done("install") || resumed.journal.IsPending(journal.PendingInstall) {
+				t.Errorf("resumed run left journal %v pending %v", resumed.journal.Completed, resumed.journal.Pending)
+			}
+		})
+	}
+}
+
+func TestResumeIgnoresOtherCommands(t *testing.T) {
+	isolateConfig(t)
+	prepareFlash(&options{board: "nrf52840dk", yes: true, skipVerify: true}, &stepInstaller{})
+
+	j := resumeJournal(&options{yes: true, catalog: boards.Default()}, "hex")
+	if j.Command != "hex" || len(j.Completed) != 0 {
+		t.Errorf("hex run resumed the interrupted flash run: %+v", j)
+	}
+}
@@ -0,0 +1,981 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/batch"
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/bundle"
+	"github.com/HubbleNetwork/hubble-install/internal/config"
+	"github.com/HubbleNetwork/hubble-install/internal/hubbleapi"
+	"github.com/HubbleNetwork/hubble-install/internal/journal"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+	"github.com/HubbleNetwork/hubble-install/internal/usb"
+)
+
+// session carries the state shared by the installation steps of a single run.
+// Each step prints its own progress and errors, and returns an error so the
+// calling command can decide on the exit code.
+type session struct {
+	opts      *options
+	runner    platform.Runner
+	installer platform.Installer
+	bundle    *bundle.Bundle   // Offline bundle everything is installed from, if any
+	journal   *journal.Journal // Progress saved for resuming an interrupted run, if journaled
+	cfg       *config.Config
+	board     boards.Board
+	startTime time.Time
+
+	// mode restricts board selection to boards flashed directly (modeFlash) or
+	// through a generated hex file (modeHex) when set
+	mode string
+
+	// toolVersion is the exact pyhubbledemo release, once resolved
+	toolVersion string
+
+	currentStep int
+	totalSteps  int
+	stepID      string    // ID of the step in progress, for structured output
+	stepStart   time.Time // When the step in progress began
+}
+
+// Session modes set by the flash and hex commands
+const (
+	modeFlash = "flash"
+	modeHex   = "hex"
+)
+
+// newSession detects the platform and prepares a session for the given options.
+// A dry run gets an installer whose changes are printed instead of made.
+func newSession(opts *options) (*session, error) {
+	var runner platform.Runner = platform.ExecRunner{}
+	if opts.dryRun {
+		runner = platform.NewDryRunner(runner)
+	}
+
+	b, err := openBundle(opts)
+	if err != nil {
+		return nil, err
+	}
+	installer, err := newInstaller(runner, b)
+	if err != nil {
+		closeBundle(b)
+		ui.PrintError(fmt.Sprintf("Platform detection failed: %v", err))
+		return nil, err
+	}
+
+	return &session{
+		opts:      opts,
+		runner:    runner,
+		installer: installer,
+		bundle:    b,
+		startTime: time.Now(),
+	}, nil
+}
+
+// close removes the unpacked offline bundle, if any
+func (s *session) close() {
+	closeBundle(s.bundle)
+}
+
+// beginStep starts timing a step and prints its header. Steps without a title
+// are internal checks: they are reported in structured output but not numbered.
+func (s *session) beginStep(id, title string) {
+	if title != "" {
+		s.currentStep++
+	}
+	s.stepID = id
+	s.stepStart = time.Now()
+	ui.StartStep(id, title, s.currentStep, s.totalSteps)
+}
+
+// endStep reports the outcome of the step in progress and records it in the journal
+func (s *session) endStep(err error) {
+	ui.EndStep(s.stepID, time.Since(s.stepStart), err, errorCode(err))
+	if err == nil {
+		s.recordStep(s.stepID)
+	}
+}
+
+// confirm asks a yes/no question, answering yes automatically with --yes
+func (s *session) confirm(question string) bool {
+	if s.opts.yes {
+		return true
+	}
+	return ui.PromptYesNo(question, true)
+}
+
+// deviceName returns the device name from the flags, prompting if allowed
+func (s *session) deviceName() string {
+	if s.opts.deviceName != "" || s.opts.yes {
+		return s.opts.deviceName
+	}
+	name := ui.PromptOptionalInput("What should the device name be?")
+	if s.journal != nil && name != "" {
+		s.journal.DeviceName = name
+		s.saveJournal()
+	}
+	return name
+}
+
+// prepare runs every step that precedes flashing: reboot check, credentials,
+// board selection, and dependency installation
+func (s *session) prepare() error {
+	if err := s.checkReboot(); err != nil {
+		return err
+	}
+	if err := s.configureCredentials(); err != nil {
+		return err
+	}
+	if err := s.checkRegistration(nil); err != nil {
+		return err
+	}
+	if err := s.selectBoard(); err != nil {
+		return err
+	}
+	if s.journal != nil && s.journal.Done("install") {
+		s.totalSteps = 4
+		ui.PrintSuccess("Dependencies were already installed by the interrupted run")
+		ui.SkipStep("prerequisites")
+		ui.SkipStep("install")
+		return nil
+	}
+	deps := s.dependencies(&s.board)
+	missing, err := s.checkPrerequisites(deps)
+	if err != nil {
+		return err
+	}
+	return s.installDependencies(missing, deps)
+}
+
+// checkReboot stops the run if the system has a reboot pending
+func (s *session) checkReboot() (err error) {
+	s.beginStep("reboot_check", "")
+	defer func() { s.endStep(err) }()
+
+	err = s.installer.CheckPendingReboot()
+	if err == nil {
+		if s.journal != nil {
+			s.journal.Resolve(journal.PendingReboot)
+		}
+		return nil
+	}
+	s.addPending(journal.PendingReboot)
+
+	fmt.Println()
+	ui.PrintWarning("═══════════════════════════════════════════════════════════════")
+	ui.PrintWarning("  SYSTEM REBOOT REQUIRED")
+	ui.PrintWarning("═══════════════════════════════════════════════════════════════")
+	fmt.Println()
+	ui.PrintWarning("A previous installation requires a system reboot before continuing.")
+	ui.PrintInfo(fmt.Sprintf("Reason: %v", err))
+	fmt.Println()
+	ui.PrintInfo("Please reboot your computer and run this installer again.")
+	fmt.Println()
+	return err
+}
+
+// configureCredentials resolves the Org ID and API token (and any pre-configured board)
+func (s *session) configureCredentials() (err error) {
+	s.beginStep("credentials", "Configuring credentials")
+	defer func() { s.endStep(err) }()
+
+	cfg, preConfigured, err := config.PromptForConfig(config.Options{
+		OrgID:          s.opts.orgID,
+		Profile:        s.opts.profile,
+		NonInteractive: s.opts.yes,
+		NoSave:         s.opts.dryRun,
+		Catalog:        s.opts.catalog,
+	})
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Configuration failed: %v", err))
+		return &platform.CredentialInvalidError{Err: err}
+	}
+	s.cfg = cfg
+
+	if err := s.verifyCredentials(); err != nil {
+		return err
+	}
+
+	// An explicit --board wins over a board embedded in HUBBLE_CREDENTIALS
+	if s.opts.board != "" {
+		s.cfg.Board = s.opts.board
+	}
+
+	if preConfigured && !s.opts.yes {
+		fmt.Println()
+		ui.PrintSuccess("We've handled your setup details")
+		fmt.Println()
+		ui.PrintInfo("We've pre-filled your credentials for this command.")
+		fmt.Println()
+		ui.PrintInfo("Your Hubble Org ID and API Token are used to register your board to your organization.")
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// verifyCredentials checks the Org ID and API token against the Hubble API so a bad
+// token is reported now rather than deep inside the flashing tool
+func (s *session) verifyCredentials() error {
+	if s.opts.skipVerify {
+		return nil
+	}
+	if s.opts.dryRun {
+		// Checking only reads the organization, so a dry run still does it
+		ui.PrintInfo("[dry run] checking the credentials with a read-only request to the Hubble API (--skip-verify skips it)")
+	}
+
+	err := s.checkCredentials()
+	if err == nil {
+		ui.PrintSuccess("Credentials verified with Hubble")
+		return nil
+	}
+	return reportCredentialError(err, s.cfg.OrgID)
+}
+
+// checkCredentials sends the Org ID and API token to the Hubble API
+func (s *session) checkCredentials() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := hubbleapi.NewClient(s.cfg.OrgID, s.cfg.APIToken)
+	if err != nil {
+		return err
+	}
+	return client.VerifyCredentials(ctx)
+}
+
+// reportCredentialError explains why the Hubble API refused or could not check the
+// credentials of orgID, and returns err classified
+func reportCredentialError(err error, orgID string) error {
+	var netErr *hubbleapi.NetworkError
+	switch {
+	case errors.Is(err, hubbleapi.ErrOrgNotFound):
+		ui.PrintError(fmt.Sprintf("Wrong Org ID: no organization %s exists", orgID))
+		ui.PrintInfo("Check the Org ID at https://dash.hubble.com/developer/api-tokens")
+	case errors.Is(err, hubbleapi.ErrForbidden):
+		ui.PrintError(fmt.Sprintf("Wrong Org ID: this API token does not belong to organization %s", orgID))
+		ui.PrintInfo("Check that the Org ID and API token were copied from the same organization")
+	case errors.Is(err, hubbleapi.ErrUnauthorized):
+		ui.PrintError("API token rejected: it may be mistyped, expired, or revoked")
+		ui.PrintInfo("Create a new token at https://dash.hubble.com/developer/api-tokens")
+	case errors.As(err, &netErr):
+		ui.PrintError("Network unreachable: could not contact the Hubble API")
+		ui.PrintInfo(fmt.Sprintf("Details: %v", netErr.Err))
+		ui.PrintInfo("Check your internet connection or proxy settings, or pass --skip-verify to continue without checking")
+	default:
+		ui.PrintError(fmt.Sprintf("Credential verification failed: %v", err))
+	}
+	return classifyAPIError(err)
+}
+
+// checkRegistration makes sure, before anything is installed, that a run from an
+// offline bundle can register its devices. Devices listed in the manifest with
+// their ID and key need nothing; any other is registered with the Hubble API, so
+// the API must be reachable. entries is nil for a run that flashes a single board.
+func (s *session) checkRegistration(entries []batch.Entry) error {
+	if s.bundle == nil || s.opts.dryRun {
+		return nil
+	}
+	unregistered := 1
+	if entries != nil {
+		unregistered = 0
+		for _, entry := range entries {
+			if !entry.Registered() {
+				unregistered++
+			}
+		}
+	}
+	if unregistered == 0 {
+		ui.PrintInfo("Every device in the manifest was registered in advance; the Hubble API is not needed")
+		return nil
+	}
+
+	err := s.checkCredentials()
+	var netErr *hubbleapi.NetworkError
+	if errors.As(err, &netErr) {
+		ui.PrintError(fmt.Sprintf("Devices not registered in advance are registered with the Hubble API, which cannot be reached: %v", netErr.Err))
+		ui.PrintInfo("To provision offline, register the devices on a machine with internet access:")
+		fmt.Printf("  hubble-install devices register --board <board> --count %d --out devices.csv\n", unregistered)
+		ui.PrintInfo("then copy devices.csv over and pass it with --manifest")
+		return classifyAPIError(err)
+	}
+	if err != nil {
+		return reportCredentialError(err, s.cfg.OrgID)
+	}
+	return nil
+}
+
+// selectBoard resolves the board from the configuration or prompts the user to choose one
+func (s *session) selectBoard() (err error) {
+	s.beginStep("board", "Selecting developer board")
+	defer func() { s.endStep(err) }()
+
+	if s.cfg.Board != "" {
+		board, err := s.opts.catalog.Board(s.cfg.Board)
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("Invalid board: %v", err))
+			return err
+		}
+		s.board = *board
+		ui.PrintSuccess(fmt.Sprintf("Using pre-configured board: %s", s.board.Name))
+	} else if found := s.detectBoards(); len(found) == 1 &&
+		s.confirm(fmt.Sprintf("Detected %s on %s. Use this board?", found[0].board.Name, found[0].device)) {
+		s.board = found[0].board
+		s.cfg.Board = s.board.ID
+		ui.PrintSuccess(fmt.Sprintf("Selected: %s", s.board.Name))
+	} else {
+		if s.opts.yes {
+			err := fmt.Errorf("no board specified: pass --board when running non-interactively")
+			if len(found) > 1 {
+				err = fmt.Errorf("several connected boards match (%s): pass --board to choose one", detectedNames(found))
+			}
+			ui.PrintError(err.Error())
+			return err
+		}
+
+		if len(found) > 1 {
+			ui.PrintInfo(fmt.Sprintf("Several connected boards match: %s", detectedNames(found)))
+		}
+
+		available := s.opts.catalog.Boards
+		boardOptions := make([]string, len(available))
+		for i, board := range available {
+			boardOptions[i] = fmt.Sprintf("%s - %s (%s)", board.Name, board.Description, board.Vendor)
+		}
+
+		selectedIndex := ui.PromptChoice("Available developer boards:", boardOptions)
+		s.board = available[selectedIndex]
+		s.cfg.Board = s.board.ID
+
+		ui.PrintSuccess(fmt.Sprintf("Selected: %s", s.board.Name))
+	}
+
+	if err := s.checkMode(&s.board); err != nil {
+		ui.PrintError(err.Error())
+		if s.board.Capability().Direct {
+			ui.PrintInfo("Use 'hubble-install flash' for this board")
+		} else {
+			ui.PrintInfo("Use 'hubble-install hex' for this board")
+		}
+		return err
+	}
+
+	fmt.Println()
+	switch s.board.FlashMethod {
+	case boards.FlashMethodJLink:
+		ui.PrintInfo("This board uses SEGGER J-Link for direct flashing.")
+		ui.PrintWarning("Make sure your board is connected via USB with a data-capable cable.")
+	case boards.FlashMethodCommander:
+		ui.PrintInfo("This board uses Silicon Labs Simplicity Commander for direct flashing.")
+		ui.PrintWarning("Make sure your board is connected via USB with a data-capable cable.")
+	default:
+		if s.board.Capability().Direct && s.mode != modeHex {
+			ui.PrintInfo("This board uses TI UniFlash. If UniFlash is installed, the board is flashed over its XDS110 debug probe;")
+			ui.PrintInfo("otherwise a hex file will be generated for you to flash with UniFlash.")
+			ui.PrintWarning("Make sure your board is connected via USB with a data-capable cable.")
+		} else {
+			ui.PrintInfo("This board uses TI Uniflash. A hex file will be generated for you.")
+			ui.PrintInfo("You'll need Uniflash installed to complete the flashing process.")
+		}
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// detectedBoard is a catalog board whose debug probe was found on USB
+type detectedBoard struct {
+	board  boards.Board
+	device usb.Device
+}
+
+// detectBoards matches the attached USB devices against the catalog, leaving out
+// boards that the running command cannot provision. Detection is best effort: on
+// failure the user picks the board from the menu.
+func (s *session) detectBoards() []detectedBoard {
+	devices, err := usb.List(s.runner, runtime.GOOS)
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Could not detect connected boards: %v", err))
+		return nil
+	}
+	return s.matchBoards(devices)
+}
+
+// matchBoards returns the catalog boards whose debug probe is among devices. A
+// probe model that several boards share matches all of them, which selectBoard
+// settles with the menu.
+func (s *session) matchBoards(devices []usb.Device) []detectedBoard {
+	var found []detectedBoard
+	seen := make(map[string]bool)
+	for _, device := range devices {
+		for _, board := range s.opts.catalog.MatchUSB(device.VendorID, device.ProductID, device.Serial) {
+			if seen[board.ID] || s.checkMode(&board) != nil {
+				continue
+			}
+			seen[board.ID] = true
+			found = append(found, detectedBoard{board: board, device: device})
+		}
+	}
+	return found
+}
+
+// detectedNames lists the names of detected boards for a message
+func detectedNames(found []detectedBoard) string {
+	names := make([]string, len(found))
+	for i, detected := range found {
+		names[i] = detected.board.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// checkMode rejects a board that the running command cannot provision
+func (s *session) checkMode(board *boards.Board) error {
+	capability := board.Capability()
+	switch {
+	case s.mode == modeFlash && !capability.Direct:
+		return fmt.Errorf("%s cannot be flashed directly; it needs a hex file for %s", board.Name, board.FlashMethod)
+	case s.mode == modeHex && !capability.Hex:
+		return fmt.Errorf("%s is flashed directly with %s, not through a hex file", board.Name, board.FlashMethod)
+	}
+	return nil
+}
+
+// dependencies returns what must be installed to provision board in this session.
+// The flash command also needs the tool of a board that otherwise falls back to a hex file.
+func (s *session) dependencies(board *boards.Board) []string {
+	deps := board.GetDependencies()
+	if capability := board.Capability(); s.mode == modeFlash && capability.Optional() && !slices.Contains(deps, capability.Tool) {
+		deps = append(deps, capability.Tool)
+	}
+	return deps
+}
+
+// flashesDirectly reports whether board is flashed by the installer rather than
+// through a generated hex file. Outside the flash and hex commands, a board that
+// supports both is flashed directly when its tool is already installed.
+func (s *session) flashesDirectly(board *boards.Board) bool {
+	capability := board.Capability()
+	switch {
+	case s.mode == modeFlash:
+		return true
+	case s.mode == modeHex || !capability.Direct:
+		return false
+	case !capability.Hex:
+		return true
+	}
+	missing, err := s.installer.CheckPrerequisites([]string{capability.Tool})
+	if err != nil {
+		return false
+	}
+	for _, dep := range missing {
+		if dep.Name == capability.Tool {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPrerequisites reports which of the given dependencies are missing
+func (s *session) checkPrerequisites(deps []string) (missing []platform.MissingDependency, err error) {
+	s.beginStep("prerequisites", "Checking prerequisites")
+	defer func() { s.endStep(err) }()
+
+	missing, err = s.installer.CheckPrerequisites(deps)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Prerequisites check failed: %v", err))
+		return nil, err
+	}
+
+	s.totalSteps = 4
+	if len(missing) == 0 {
+		ui.PrintSuccess("All prerequisites satisfied")
+		return nil, nil
+	}
+	s.totalSteps++
+
+	ui.PrintWarning("Missing dependencies detected:")
+	for _, dep := range missing {
+		fmt.Printf("  • %s: %s\n", dep.Name, dep.Status)
+	}
+	fmt.Println()
+
+	return missing, nil
+}
+
+// installDependencies installs deps after confirming with the user, if any are missing
+func (s *session) installDependencies(missing []platform.MissingDependency, deps []string) (err error) {
+	if len(missing) == 0 {
+		ui.SkipStep("install")
+		s.recordStep("install")
+		return nil
+	}
+
+	if s.journal != nil && s.journal.IsPending(journal.PendingInstall) {
+		ui.PrintInfo("Continuing the dependency installation of the interrupted run")
+	} else if !s.confirm("Would you like to install missing dependencies?") {
+		ui.PrintError("Cannot proceed without dependencies")
+		return &platform.UserCancelledError{Action: "dependency installation"}
+	}
+	s.addPending(journal.PendingInstall)
+
+	s.beginStep("install", "Installing dependencies")
+	defer func() { s.endStep(err) }()
+
+	// Check if we need to install package manager first
+	for _, dep := range missing {
+		if dep.Name == "Homebrew" {
+			if err := s.installer.InstallPackageManager(); err != nil {
+				ui.PrintError(fmt.Sprintf("Package manager installation failed: %v", err))
+				return err
+			}
+			// The package manager stays even if a dependency fails below
+			s.saveChanges()
+			break
+		}
+	}
+
+	// Install board-specific dependencies
+	if err := s.installer.InstallDependencies(deps); err != nil {
+		if isRebootRequired(err) {
+			s.saveChanges()
+			s.addPending(journal.PendingReboot)
+			fmt.Println()
+			ui.PrintWarning("═══════════════════════════════════════════════════════════════")
+			ui.PrintWarning("  SYSTEM REBOOT REQUIRED")
+			ui.PrintWarning("═══════════════════════════════════════════════════════════════")
+			fmt.Println()
+			ui.PrintSuccess("Dependencies were installed successfully!")
+			fmt.Println()
+			ui.PrintWarning("However, system components were updated that require a reboot")
+			ui.PrintWarning("before you can continue.")
+			fmt.Println()
+			ui.PrintInfo("What to do next:")
+			ui.PrintInfo("  1. Reboot your computer")
+			ui.PrintInfo("  2. Run this installer again after rebooting")
+			ui.PrintInfo("  3. The installer will offer to resume where it left off")
+			fmt.Println()
+			ui.PrintInfo("Note: If PowerShell doesn't work after reboot, use Command Prompt (cmd.exe)")
+			fmt.Println()
+			return err
+		}
+		ui.PrintError(fmt.Sprintf("Dependency installation failed: %v", err))
+		s.rollback()
+		names := make([]string, len(missing))
+		for i, dep := range missing {
+			names[i] = dep.Name
+		}
+		return &platform.DependencyMissingError{Dependencies: names, Err: err}
+	}
+
+	s.saveChanges()
+	ui.PrintSuccess("All dependencies installed")
+	return nil
+}
+
+// flash flashes the selected board directly and prints the completion banner
+func (s *session) flash() error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	if !s.confirm(fmt.Sprintf("Would you like to flash your %s now?", s.board.Name)) {
+		ui.PrintWarning("Flashing skipped. You can flash later using:")
+		switch s.board.FlashMethod {
+		case boards.FlashMethodCommander:
+			fmt.Printf("  uv tool run --from %s hubbledemo flash %s -o %s -t <your_token> -f %s.hex\n", s.toolRequirement(), s.cfg.Board, s.cfg.OrgID, s.cfg.Board)
+			fmt.Printf("  commander flash %s.hex\n", s.cfg.Board)
+		case boards.FlashMethodUniflash:
+			fmt.Printf("  hubble-install flash --board %s --org-id %s\n", s.cfg.Board, s.cfg.OrgID)
+		default:
+			fmt.Printf("  uv tool run --from %s hubbledemo flash %s -o %s -t <your_token>\n", s.toolRequirement(), s.cfg.Board, s.cfg.OrgID)
+		}
+		ui.SkipStep("flash")
+		return nil
+	}
+
+	// Probe enumeration goes through J-Link; Simplicity Commander and UniFlash
+	// take --probe-serial as given and pick the board's probe themselves otherwise
+	probeSerial := s.opts.probeSerial
+	if s.board.FlashMethod == boards.FlashMethodJLink {
+		var err error
+		if probeSerial, err = s.selectProbe(); err != nil {
+			return err
+		}
+	}
+
+	deviceName := s.deviceName()
+
+	s.beginStep("flash", "Flashing board")
+	result, err := s.runFlashRequest(deviceName, probeSerial, true)
+	s.endStep(err)
+	if err != nil {
+		return err
+	}
+
+	s.reportResult(result)
+	if s.opts.dryRun {
+		ui.PrintSuccess("Dry run complete: nothing was installed, registered or flashed")
+		return nil
+	}
+	ui.PrintCompletionBanner(time.Since(s.startTime), s.cfg.OrgID, s.cfg.APIToken, result.DeviceName, result.DeviceID)
+	return nil
+}
+
+// selectProbe picks the J-Link probe to flash. An explicit --probe-serial must match a
+// connected probe; otherwise a single probe is used as-is and several probes prompt the
+// user to choose. An empty serial means the flashing tool picks its default probe.
+func (s *session) selectProbe() (serial string, err error) {
+	s.beginStep("probe", "")
+	defer func() { s.endStep(err) }()
+
+	// Listing probes changes nothing, so a dry run lists them for real: the dry
+	// runner would neither write JLinkExe's command file nor run a JLinkExe that
+	// it only pretended to install
+	runner := s.runner
+	if dry, ok := runner.(*platform.DryRunner); ok {
+		runner = dry.Inner()
+	}
+	probes, err := platform.ListProbesWith(runner)
+	if err != nil {
+		// Enumeration is best effort; the flashing tool reports a missing probe itself
+		ui.PrintWarning(fmt.Sprintf("Could not list J-Link probes: %v", err))
+		return s.opts.probeSerial, nil
+	}
+
+	if s.opts.probeSerial != "" {
+		for _, probe := range probes {
+			if probe.Serial == strings.TrimLeft(s.opts.probeSerial, "0") {
+				ui.PrintSuccess(fmt.Sprintf("Using J-Link probe %s (%s)", probe.Serial, probe.Product))
+				return probe.Serial, nil
+			}
+		}
+		err := &platform.ProbeNotFoundError{Serial: s.opts.probeSerial}
+		ui.PrintError(err.Error())
+		printProbes(probes)
+		return "", err
+	}
+
+	switch len(probes) {
+	case 0:
+		if s.opts.dryRun {
+			ui.PrintWarning("No J-Link probe detected: connect the board before the real run")
+			return "", nil
+		}
+		err := &platform.ProbeNotFoundError{}
+		ui.PrintError("No J-Link probe detected")
+		ui.PrintInfo("Check that your board is powered and connected with a data-capable USB cable.")
+		return "", err
+	case 1:
+		return "", nil
+	}
+
+	if s.opts.yes {
+		err := fmt.Errorf("%d J-Link probes connected: pass --probe-serial to choose one", len(probes))
+		ui.PrintError(err.Error())
+		printProbes(probes)
+		return "", err
+	}
+
+	probeOptions := make([]string, len(probes))
+	for i, probe := range probes {
+		probeOptions[i] = fmt.Sprintf("%s - %s", probe.Serial, probe.Product)
+	}
+	selected := probes[ui.PromptChoice("Several J-Link probes are connected. Which one should be flashed?", probeOptions)]
+	ui.PrintSuccess(fmt.Sprintf("Using J-Link probe %s", selected.Serial))
+	return selected.Serial, nil
+}
+
+// printProbes lists the connected probes so the user can pick a serial number
+func printProbes(probes []platform.Probe) {
+	if len(probes) == 0 {
+		return
+	}
+	ui.PrintInfo("Connected J-Link probes:")
+	for _, probe := range probes {
+		fmt.Printf("  • %s - %s\n", probe.Serial, probe.Product)
+	}
+}
+
+// generateHex generates a hex file for the selected UniFlash board and prints the completion banner
+func (s *session) generateHex() error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	if s.mode != modeHex && s.board.Capability().Direct {
+		ui.PrintInfo("UniFlash was not found. Install it from ti.com to have the installer flash this board directly.")
+	}
+
+	if !s.confirm(fmt.Sprintf("Would you like to generate the hex file for your %s now?", s.board.Name)) {
+		ui.PrintWarning("Hex generation skipped. You can generate later using:")
+		fmt.Printf("  uv tool run --from %s hubbledemo flash %s -o %s -t <your_token>\n", s.toolRequirement(), s.cfg.Board, s.cfg.OrgID)
+		ui.SkipStep("hex")
+		return nil
+	}
+
+	deviceName := s.deviceName()
+
+	s.beginStep("hex", "Generating hex file")
+	result, err := s.runFlashRequest(deviceName, "", false)
+	s.endStep(err)
+	if err != nil {
+		return err
+	}
+
+	s.reportResult(result)
+	if s.opts.dryRun {
+		ui.PrintSuccess("Dry run complete: nothing was installed, registered or written")
+		return nil
+	}
+	ui.PrintUniflashCompletionBanner(time.Since(s.startTime), result.HexFilePath, s.board.Name, result.DeviceName, result.DeviceID)
+	return nil
+}
+
+// runFlashRequest registers the device, then flashes the selected board directly
+// or generates its hex file
+func (s *session) runFlashRequest(deviceName, probeSerial string, direct bool) (*platform.FlashResult, error) {
+	req, err := s.newFlashRequest(&s.board, deviceName, probeSerial, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if direct {
+		result, err := s.installer.FlashBoard(req)
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("Board flashing failed: %v", err))
+		}
+		return result, err
+	}
+
+	result, err := s.installer.GenerateHexFile(req)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Hex file generation failed: %v", err))
+	}
+	return result, err
+}
+
+// installResult is the summary reported at the end of a successful flash or hex run
+type installResult struct {
+	Board string `json:"board"`
+	*platform.FlashResult
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// reportResult emits the final summary for structured output
+func (s *session) reportResult(result *platform.FlashResult) {
+	ui.PrintResult(installResult{
+		Board:       s.board.ID,
+		FlashResult: result,
+		DurationMS:  time.Since(s.startTime).Milliseconds(),
+	})
+}
+
+// newFlashRequest registers a device with the Hubble API and returns the request that
+// provisions the board with it. A device registered in advance is used instead when
+// given. If the API cannot be reached, the request falls back to letting the flashing
+// tool register the device itself, except offline, where neither can.
+func (s *session) newFlashRequest(board *boards.Board, deviceName, probeSerial string, registered *hubbleapi.Device) (platform.FlashRequest, error) {
+	toolVersion, err := s.resolveToolVersion(board)
+	if err != nil {
+		return platform.FlashRequest{}, err
+	}
+
+	req := platform.FlashRequest{
+		OrgID:       s.cfg.OrgID,
+		APIToken:    s.cfg.APIToken,
+		Board:       board.ID,
+		FlashMethod: board.FlashMethod,
+		Target:      board.Target,
+		ToolVersion: toolVersion,
+		DeviceName:  deviceName,
+		ProbeSerial: probeSerial,
+	}
+
+	if s.opts.dryRun {
+		if registered != nil {
+			ui.PrintInfo(fmt.Sprintf("[dry run] would flash the registered device %s", registered.ID))
+		} else {
+			ui.PrintInfo("[dry run] would register a device with the Hubble API")
+		}
+		return req, nil
+	}
+
+	// Selecting a probe and flashing a registered device need options that only
+	// some pyhubbledemo releases have; they are passed only where its help lists them
+	if probeSerial != "" {
+		if ok, err := platform.HubbledemoAccepts(s.runner, toolVersion, "--serial"); !ok {
+			err = fmt.Errorf("pyhubbledemo %s cannot be told which J-Link probe to use (%v): leave only the board to flash connected and run again without --probe-serial", toolVersion, describeHelpError(err))
+			ui.PrintError(err.Error())
+			return req, err
+		}
+	}
+	if ok, err := platform.HubbledemoAccepts(s.runner, toolVersion, "--key"); !ok {
+		if registered != nil || s.bundle != nil {
+			err = fmt.Errorf("pyhubbledemo %s cannot flash a device registered in advance (%v): use a release whose flash command has --key", toolVersion, describeHelpError(err))
+			ui.PrintError(err.Error())
+			return req, err
+		}
+		ui.PrintInfo(fmt.Sprintf("pyhubbledemo %s registers the device itself (%v)", toolVersion, describeHelpError(err)))
+		return req, nil
+	}
+	if registered != nil {
+		req.DeviceID = registered.ID
+		req.DeviceKey = registered.Key
+		ui.PrintSuccess(fmt.Sprintf("Using device %s, registered in advance", registered.ID))
+		return req, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := hubbleapi.NewClient(s.cfg.OrgID, s.cfg.APIToken)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Device registration failed: %v", err))
+		return req, err
+	}
+	device, err := client.RegisterDevice(ctx, deviceName)
+	var namingErr *hubbleapi.NamingError
+	switch {
+	case errors.As(err, &namingErr):
+		// The device exists and has its key, so it can still be flashed
+		ui.PrintWarning(fmt.Sprintf("%v; rename it later with 'hubble-install devices rename %s <name>'", err, device.ID))
+		req.DeviceName = ""
+	case err != nil:
+		var netErr *hubbleapi.NetworkError
+		if errors.As(err, &netErr) && s.bundle == nil {
+			ui.PrintWarning("Could not reach the Hubble API; the flashing tool will register the device instead")
+			return req, nil
+		}
+		ui.PrintError(fmt.Sprintf("Device registration failed: %v", err))
+		return req, classifyAPIError(err)
+	}
+
+	req.DeviceID = device.ID
+	req.DeviceKey = device.Key
+	ui.PrintSuccess(fmt.Sprintf("Registered device %s", device.ID))
+	return req, nil
+}
+
+// describeHelpError explains why an option of pyhubbledemo is not used
+func describeHelpError(err error) string {
+	if err != nil {
+		return fmt.Sprintf("its options could not be read: %v", err)
+	}
+	return "its flash command does not list the option"
+}
+
+// resolveToolVersion returns the pyhubbledemo release to flash board with. "latest"
+// is looked up once, so every board of a run gets the same release.
+func (s *session) resolveToolVersion(board *boards.Board) (string, error) {
+	if s.toolVersion == "" {
+		version, err := platform.ResolveToolVersion(s.opts.toolVersion)
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("Could not resolve the pyhubbledemo version: %v", err))
+			return "", err
+		}
+		if s.opts.toolVersion == platform.LatestToolVersion {
+			ui.PrintInfo(fmt.Sprintf("Using pyhubbledemo %s, the latest release", version))
+		}
+		s.toolVersion = version
+	}
+
+	if !board.SupportsToolVersion(s.toolVersion) {
+		err := fmt.Errorf("%s needs pyhubbledemo %s or newer, but %s was requested (see --tool-version)", board.Name, board.MinToolVersion, s.toolVersion)
+		ui.PrintError(err.Error())
+		return "", err
+	}
+	return s.toolVersion, nil
+}
+
+// toolRequirement returns the pyhubbledemo requirement shown in manual instructions
+func (s *session) toolRequirement() string {
+	if s.opts.toolVersion == platform.LatestToolVersion {
+		return "pyhubbledemo"
+	}
+	return "pyhubbledemo==" + s.opts.toolVersion
+}
+
+// validate checks the resolved configuration before anything is written to a board
+func (s *session) validate() error {
+	if err := s.cfg.Validate(); err != nil {
+		ui.PrintError(fmt.Sprintf("Invalid configuration: %v", err))
+		return &platform.CredentialInvalidError{Err: err}
+	}
+	return nil
+}
+
+// isRebootRequired reports whether err signals that the system must be rebooted
+func isRebootRequired(err error) bool {
+	var rebootErr *platform.RebootRequiredError
+	return errors.As(err, &rebootErr)
+}
+
+// classifyAPIError maps a Hubble API failure onto the platform error types
+func classifyAPIError(err error) error {
+	var netErr *hubbleapi.NetworkError
+	switch {
+	case errors.Is(err, hubbleapi.ErrUnauthorized),
+		errors.Is(err, hubbleapi.ErrForbidden),
+		errors.Is(err, hubbleapi.ErrOrgNotFound):
+		return &platform.CredentialInvalidError{Err: err}
+	case errors.As(err, &netErr):
+		return &platform.NetworkError{Op: "contact the Hubble API", Err: err}
+	}
+	return err
+}
+
+// classify maps a step error to its structured error code and process exit code.
+// The checks run from the most to the least specific cause, so a reboot reported
+// while installing a dependency is still classified as a reboot.
+func classify(err error) (string, int) {
+	var (
+		rebootErr     *platform.RebootRequiredError
+		cancelledErr  *platform.UserCancelledError
+		credentialErr *platform.CredentialInvalidError
+		networkErr    *platform.NetworkError
+		probeErr      *platform.ProbeNotFoundError
+		dependencyErr *platform.DependencyMissingError
+		flashErr      *platform.FlashFailedError
+	)
+
+	switch {
+	case err == nil:
+		return "", exitOK
+	case errors.As(err, &rebootErr):
+		return "reboot_required", exitReboot
+	case errors.As(err, &cancelledErr):
+		return "user_cancelled", exitCancelled
+	case errors.As(err, &credentialErr):
+		return "credential_invalid", exitCredentialInvalid
+	case errors.As(err, &networkErr):
+		return "network_error", exitNetwork
+	case errors.As(err, &probeErr):
+		return "probe_not_found", exitProbeNotFound
+	case errors.As(err, &dependencyErr):
+		return "dependency_missing", exitDependencyMissing
+	case errors.As(err, &flashErr):
+		return "flash_failed", exitFlashFailed
+	default:
+		return "error", exitError
+	}
+}
+
+// errorCode classifies a step error for structured output
+func errorCode(err error) string {
+	code, _ := classify(err)
+	return code
+}
+
+// exitCodeFor maps a step error to the process exit code
+func exitCodeFor(err error) int {
+	_, code := classify(err)
+	return code
+}
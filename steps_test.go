@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/config"
+	"github.com/HubbleNetwork/hubble-install/internal/hubbleapi"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/platform/platformtest"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+	"github.com/HubbleNetwork/hubble-install/internal/usb"
+)
+
+// messageSink records the messages printed during a test
+type messageSink struct {
+	messages []string
+}
+
+func (m *messageSink) Emit(event ui.Event) {
+	if event.Type == ui.EventMessage {
+		m.messages = append(m.messages, event.Message)
+	}
+}
+
+// printed returns everything printed, one message per line
+func (m *messageSink) printed() string {
+	return strings.Join(m.messages, "\n")
+}
+
+// captureMessages sends the messages printed during the test to a messageSink
+func captureMessages(t *testing.T) *messageSink {
+	t.Helper()
+	sink := &messageSink{}
+	ui.SetSink(sink)
+	t.Cleanup(func() { ui.SetSink(ui.NewHumanSink(nil)) })
+	return sink
+}
+
+// hubbleAPI serves status for every request to a fake Hubble API and counts them
+func hubbleAPI(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Method != http.MethodGet || r.URL.Path != "/api/org/"+testOrgID {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer "+testAPIToken {
+			t.Errorf("request sent without the API token")
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(hubbleapi.BaseURLEnv, server.URL)
+	return server, &requests
+}
+
+// credentialSession returns a session holding the test credentials
+func credentialSession(opts *options) *session {
+	return &session{opts: opts, cfg: &config.Config{OrgID: testOrgID, APIToken: testAPIToken}}
+}
+
+func TestVerifyCredentials(t *testing.T) {
+	var (
+		credentialErr *platform.CredentialInvalidError
+		networkErr    *platform.NetworkError
+	)
+	tests := []struct {
+		name    string
+		status  int
+		down    bool // The API cannot be reached at all
+		want    string
+		wantErr any // Pointer to the error type expected, or nil for success
+	}{
+		{name: "valid", status: http.StatusOK, want: "Credentials verified"},
+		{name: "unknown organization", status: http.StatusNotFound, want: "Wrong Org ID: no organization", wantErr: &credentialErr},
+		{name: "token of another organization", status: http.StatusForbidden, want: "does not belong to organization", wantErr: &credentialErr},
+		{name: "rejected token", status: http.StatusUnauthorized, want: "API token rejected", wantErr: &credentialErr},
+		{name: "server error", status: http.StatusInternalServerError, want: "Credential verification failed"},
+		{name: "unreachable", down: true, want: "Network unreachable", wantErr: &networkErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := hubbleAPI(t, tt.status)
+			if tt.down {
+				server.Close()
+			}
+			out := captureMessages(t)
+
+			err := credentialSession(&options{}).verifyCredentials()
+			switch {
+			case tt.wantErr != nil:
+				if !errors.As(err, tt.wantErr) {
+					t.Errorf("verifyCredentials() = %v, want a %T", err, tt.wantErr)
+				}
+			case tt.status == http.StatusOK && err != nil:
+				t.Errorf("verifyCredentials() = %v", err)
+			case tt.status != http.StatusOK && err == nil:
+				t.Errorf("verifyCredentials() accepted a %d response", tt.status)
+			}
+			if !strings.Contains(out.printed(), tt.want) {
+				t.Errorf("printed %q, want it to say %q", out.printed(), tt.want)
+			}
+			if !tt.down && requests.Load() != 1 {
+				t.Errorf("sent %d requests, want 1", requests.Load())
+			}
+		})
+	}
+}
+
+func TestVerifyCredentialsDryRun(t *testing.T) {
+	_, requests := hubbleAPI(t, http.StatusOK)
+	out := captureMessages(t)
+
+	if err := credentialSession(&options{dryRun: true}).verifyCredentials(); err != nil {
+		t.Fatalf("verifyCredentials() = %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("dry run sent %d requests, want the 1 read-only check", requests.Load())
+	}
+	if !strings.Contains(out.printed(), "[dry run] checking the credentials with a read-only request") {
+		t.Errorf("dry run did not say it contacts the API:\n%s", out.printed())
+	}
+
+	if err := credentialSession(&options{dryRun: true, skipVerify: true}).verifyCredentials(); err != nil {
+		t.Fatalf("verifyCredentials() = %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("--skip-verify still contacted the API")
+	}
+}
+
+func TestMalformedCredentialsExitCode(t *testing.T) {
+	isolateConfig(t)
+	t.Setenv("HUBBLE_ORG_ID", "")
+	t.Setenv("HUBBLE_API_TOKEN", "")
+	t.Setenv("HUBBLE_CREDENTIALS", base64.StdEncoding.EncodeToString([]byte("not-an-org:short")))
+	captureMessages(t)
+
+	s := &session{opts: &options{yes: true, catalog: boards.Default()}}
+	if code := exitCodeFor(s.configureCredentials()); code != exitCredentialInvalid {
+		t.Errorf("configureCredentials() exits %d, want %d", code, exitCredentialInvalid)
+	}
+	if code := runDevices([]string{"list", "--yes"}); code != exitCredentialInvalid {
+		t.Errorf("devices list exits %d, want %d", code, exitCredentialInvalid)
+	}
+}
+
+func TestSelectProbeDryRun(t *testing.T) {
+	const emuList = "J-Link[0]: Connection: USB, Serial number: 683000001, ProductName: J-Link OB-SAM3U128-V2-NordicSemi\n"
+
+	tests := []struct {
+		name        string
+		inner       *platformtest.Runner
+		simulated   bool // JLinkExe is only installed by the dry run
+		probeSerial string
+		want        string
+	}{
+		{
+			name:        "probe connected",
+			inner:       platformtest.NewRunner("JLinkExe").On(platformtest.Response{Match: "/fake/bin/JLinkExe", Output: emuList}),
+			probeSerial: "683000001",
+			want:        "683000001",
+		},
+		{
+			name:  "no probe connected",
+			inner: platformtest.NewRunner("JLinkExe"),
+		},
+		{
+			name:      "J-Link installed only by the dry run",
+			inner:     platformtest.NewRunner(),
+			simulated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := platform.NewDryRunner(tt.inner)
+			if tt.simulated {
+				runner.Run(platform.Command{Path: "brew", Args: []string{"install", "segger-jlink"}, Installs: []string{"JLinkExe"}})
+			}
+			s := &session{opts: &options{dryRun: true, probeSerial: tt.probeSerial}, runner: runner}
+
+			serial, err := s.selectProbe()
+			if err != nil || serial != tt.want {
+				t.Errorf("selectProbe() = %q, %v, want %q", serial, err, tt.want)
+			}
+			if !tt.simulated && !tt.inner.Ran("/fake/bin/JLinkExe -NoGui 1 -CommandFile") {
+				t.Errorf("probes were not listed: %v", tt.inner.Calls())
+			}
+		})
+	}
+}
+
+func TestMatchBoardsSharedProbe(t *testing.T) {
+	s := &session{opts: &options{catalog: boards.Default()}}
+	tests := []struct {
+		name    string
+		devices []usb.Device
+		want    []string
+	}{
+		{
+			name:    "XDS110 of either CC2340 LaunchPad",
+			devices: []usb.Device{{VendorID: "0451", ProductID: "bef3", Serial: "L1100ABC"}},
+			want:    []string{"lp_em_cc2340r5", "lp_em_cc2340r53"},
+		},
+		{
+			name: "J-Link OB of an nRF52840 DK, connected twice",
+			devices: []usb.Device{
+				{VendorID: "1366", ProductID: "1015", Serial: "000683123456"},
+				{VendorID: "1366", ProductID: "1051", Serial: "000683654321"},
+			},
+			want: []string{"nrf21540dk", "nrf52840dk"},
+		},
+		{
+			name:    "unknown device",
+			devices: []usb.Device{{VendorID: "046d", ProductID: "c52b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, detected := range s.matchBoards(tt.devices) {
+				got = append(got, detected.board.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matchBoards() = %v, want %v, for selectBoard to offer the menu", got, tt.want)
+			}
+		})
+	}
+}
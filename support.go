@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/boards"
+	"github.com/HubbleNetwork/hubble-install/internal/config"
+	"github.com/HubbleNetwork/hubble-install/internal/journal"
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/redact"
+	"github.com/HubbleNetwork/hubble-install/internal/support"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// credentialVariables are the environment variables that hold credentials
+var credentialVariables = []string{"HUBBLE_ORG_ID", "HUBBLE_API_TOKEN", "HUBBLE_CREDENTIALS"}
+
+// systemInfo describes the machine and installer in a support bundle
+type systemInfo struct {
+	Version     string            `json:"version"`
+	Commit      string            `json:"commit"`
+	Date        string            `json:"date"`
+	OS          string            `json:"os"`
+	Arch        string            `json:"arch"`
+	GoVersion   string            `json:"go_version"`
+	Created     time.Time         `json:"created"`
+	Environment map[string]string `json:"environment"` // HUBBLE_* variables and PATH
+}
+
+// supportBundleResult is the structured result of the support-bundle command
+type supportBundleResult struct {
+	Path  string   `json:"path"`
+	Files []string `json:"files"`
+}
+
+// runSupportBundle packs the latest logs, a doctor report, system information and
+// the installer's settings, with credentials masked, into a zip for a support ticket
+func runSupportBundle(args []string) int {
+	fs := flag.NewFlagSet("support-bundle", flag.ContinueOnError)
+	out := fs.String("out", "", "where to write the bundle (default hubble-support-<time>.zip)")
+	logs := fs.Int("logs", 5, "how many of the latest run logs to include")
+	skipDoctor := fs.Bool("skip-doctor", false, "leave out the doctor report, which needs a few seconds of network checks")
+	output := fs.String("output", "human", "output format: human or json")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := setOutput(*output); code >= 0 {
+		return code
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("hubble-support-%s.zip", time.Now().Format("20060102-150405"))
+	}
+
+	archive, err := support.Create(*out, redact.New(knownCredentials()...))
+	if err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+	if err := fillSupportBundle(archive, *logs, !*skipDoctor); err != nil {
+		archive.Close()
+		os.Remove(*out)
+		ui.PrintError(err.Error())
+		return exitError
+	}
+	if err := archive.Close(); err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Support bundle written to %s", archive.Path()))
+	ui.PrintInfo("API tokens and Org IDs are masked; look through it before attaching it to a ticket")
+	ui.PrintResult(supportBundleResult{Path: archive.Path(), Files: archive.Names()})
+	return exitOK
+}
+
+// fillSupportBundle adds everything a support bundle holds to archive
+func fillSupportBundle(archive *support.Archive, logCount int, withDoctor bool) error {
+	info := systemInfo{
+		Version:     Version,
+		Commit:      Commit,
+		Date:        Date,
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		GoVersion:   runtime.Version(),
+		Created:     time.Now().UTC(),
+		Environment: map[string]string{"PATH": os.Getenv("PATH")},
+	}
+	for _, entry := range os.Environ() {
+		if name, value, _ := strings.Cut(entry, "="); strings.HasPrefix(name, "HUBBLE_") {
+			info.Environment[name] = value
+		}
+	}
+	for _, name := range credentialVariables {
+		if _, ok := info.Environment[name]; ok {
+			info.Environment[name] = redact.Mask
+		}
+	}
+	if err := archive.AddJSON("system.json", info); err != nil {
+		return err
+	}
+
+	if withDoctor {
+		ui.PrintInfo("Running diagnostics...")
+		report, err := diagnose(boards.Default(), platform.DefaultToolVersion)
+		if err != nil {
+			if err := archive.Add("doctor-error.txt", []byte(err.Error()+"\n")); err != nil {
+				return err
+			}
+		} else if err := archive.AddJSON("doctor.json", report); err != nil {
+			return err
+		}
+	}
+
+	if dir, err := logDir(); err == nil {
+		logs, _ := recentLogs(dir)
+		for _, path := range logs[:min(logCount, len(logs))] {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			if err := archive.Add("logs/"+filepath.Base(path), data); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, path := range configFiles() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if err := archive.AddConfig("config/"+filepath.Base(path), data); err != nil {
+			ui.PrintWarning(err.Error())
+		}
+	}
+	return nil
+}
+
+// configFiles lists the installer's settings files that are worth including
+func configFiles() []string {
+	var paths []string
+	for _, locate := range []func() (string, error){config.DefaultStorePath, journal.DefaultPath, platform.DefaultInstallManifestPath} {
+		if path, err := locate(); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// knownCredentials gathers the Org IDs and API tokens this machine is configured
+// with, so that they are masked wherever they appear in a support bundle
+func knownCredentials() []string {
+	var values []string
+	for _, name := range credentialVariables {
+		values = append(values, os.Getenv(name))
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(os.Getenv("HUBBLE_CREDENTIALS")); err == nil {
+		// org_id:api_token[:board_id]
+		parts := strings.SplitN(string(decoded), ":", 3)
+		for _, part := range parts[:min(2, len(parts))] {
+			values = append(values, strings.TrimSpace(part))
+		}
+	}
+
+	if store, err := config.LoadStore(); err == nil {
+		for name, profile := range store.Profiles {
+			values = append(values, profile.OrgID, profile.APIToken)
+			// Tokens kept in the keyring or the encrypted file are fetched from there
+			if resolved, err := store.Get(name); err == nil {
+				values = append(values, resolved.APIToken)
+			}
+		}
+	}
+	if path, err := journal.DefaultPath(); err == nil {
+		if j, err := journal.Load(path); err == nil && j != nil {
+			values = append(values, j.OrgID)
+		}
+	}
+	return values
+}
@@ -0,0 +1,110 @@
+// Command pin-downloads fills in the SHA-256 of every download listed in the
+// installer's download manifest (internal/platform/downloads.json), or checks
+// that the pinned checksums still match what the servers serve.
+//
+// Usage:
+//
+//	go run ./tools/pin-downloads [-check] [-update] [manifest]
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+)
+
+func main() {
+	check := flag.Bool("check", false, "only check the manifest; fail if a checksum is missing or does not match")
+	update := flag.Bool("update", false, "replace pinned checksums that no longer match instead of failing")
+	flag.Parse()
+
+	path := "internal/platform/downloads.json"
+	if flag.NArg() > 0 {
+		path = flag.Arg(0)
+	}
+	if err := run(path, *check, *update); err != nil {
+		fmt.Fprintf(os.Stderr, "pin-downloads: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run hashes every download in the manifest at path and pins or checks its checksum
+func run(path string, check, update bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// Parse it the way the installer does, so an invalid manifest fails here first
+	if _, err := platform.ParseDownloadPins(data); err != nil {
+		return err
+	}
+	var manifest platform.DownloadManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Minute}
+	failed, changed := 0, false
+	for i, pin := range manifest.Downloads {
+		sum, err := hash(client, pin.URL)
+		if err != nil {
+			fmt.Printf("FAIL    %s: %v\n", pin.URL, err)
+			failed++
+			continue
+		}
+		switch {
+		case pin.SHA256 == sum:
+			fmt.Printf("ok      %s\n", pin.URL)
+		case check:
+			fmt.Printf("FAIL    %s: SHA-256 is %s, but %q is pinned\n", pin.URL, sum, pin.SHA256)
+			failed++
+		case pin.SHA256 != "" && !update:
+			fmt.Printf("CHANGED %s: SHA-256 is %s, but %s is pinned (rerun with -update to accept it)\n", pin.URL, sum, pin.SHA256)
+			failed++
+		default:
+			fmt.Printf("pinned  %s: %s\n", pin.URL, sum)
+			manifest.Downloads[i].SHA256 = sum
+			changed = true
+		}
+	}
+
+	if changed {
+		out, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, append(out, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads could not be pinned", failed, len(manifest.Downloads))
+	}
+	return nil
+}
+
+// hash downloads url and returns its hex SHA-256
+func hash(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"slices"
+
+	"github.com/HubbleNetwork/hubble-install/internal/platform"
+	"github.com/HubbleNetwork/hubble-install/internal/ui"
+)
+
+// uninstallResult is the structured result of the uninstall command
+type uninstallResult struct {
+	Removed []platform.Change `json:"removed"`
+	Kept    []platform.Change `json:"kept"` // Changes that could not be undone and stay in the manifest
+	Left    []platform.Change `json:"left"` // Package managers left installed and no longer recorded
+}
+
+// saveChanges adds what the installer changed so far to the install manifest,
+// for 'hubble-install uninstall'. Dry runs change nothing and are not recorded.
+func (s *session) saveChanges() {
+	changes := s.installer.TakeChanges()
+	if s.opts.dryRun || len(changes) == 0 {
+		return
+	}
+	if err := recordChanges(changes); err != nil {
+		ui.PrintWarning(fmt.Sprintf("What was installed could not be recorded, so 'hubble-install uninstall' will not remove it: %v", err))
+	}
+}
+
+// rollback undoes what a failed dependency installation changed, so that a
+// retry starts from the system as it was. What cannot be undone is recorded for
+// uninstall instead.
+func (s *session) rollback() {
+	changes := s.installer.TakeChanges()
+	if len(changes) == 0 {
+		return
+	}
+	ui.PrintInfo("Rolling back the dependencies installed before the failure")
+	failed := platform.Rollback(s.runner, runtime.GOOS, changes)
+	if s.opts.dryRun || len(failed) == 0 {
+		return
+	}
+	if err := recordChanges(failed); err != nil {
+		ui.PrintWarning(fmt.Sprintf("What could not be rolled back was not recorded: %v", err))
+	}
+}
+
+// recordChanges appends changes to the install manifest
+func recordChanges(changes []platform.Change) error {
+	manifest, err := loadInstallManifest()
+	if err != nil {
+		return err
+	}
+	manifest.Changes = append(manifest.Changes, changes...)
+	return manifest.Save()
+}
+
+// loadInstallManifest reads the install manifest from its default location
+func loadInstallManifest() (*platform.InstallManifest, error) {
+	path, err := platform.DefaultInstallManifestPath()
+	if err != nil {
+		return nil, err
+	}
+	return platform.LoadInstallManifest(path)
+}
+
+// runUninstall removes what the installer added to this system, as recorded in
+// the install manifest. Anything that was installed before is never touched.
+func runUninstall(args []string) int {
+	fs := flag.NewFlagSet("uninstall", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "remove everything without asking, except package managers")
+	removePackageManager := fs.Bool("remove-package-manager", false, "with --yes, also remove Homebrew or Chocolatey when the installer added it and nothing else is installed with it")
+	dryRun := fs.Bool("dry-run", false, "print what would be removed without removing it")
+	output := fs.String("output", "human", "output format: human or json")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := setOutput(*output); code >= 0 {
+		return code
+	}
+
+	manifest, err := loadInstallManifest()
+	if err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+	result := uninstallResult{Removed: []platform.Change{}, Kept: []platform.Change{}, Left: []platform.Change{}}
+	if len(manifest.Changes) == 0 {
+		ui.PrintInfo("Nothing to uninstall: the installer has not added anything to this system")
+		ui.PrintResult(result)
+		return exitOK
+	}
+
+	if ui.IsHuman() {
+		ui.PrintInfo("The installer added:")
+		for _, c := range manifest.Changes {
+			fmt.Printf("  • %s\n", c)
+		}
+		fmt.Println()
+	}
+
+	var runner platform.Runner = platform.ExecRunner{}
+	if *dryRun {
+		runner = platform.NewDryRunner(runner)
+	}
+	if !*yes && !*dryRun && !ui.PromptYesNo("Remove all of these?", false) {
+		ui.PrintWarning("Uninstall cancelled")
+		return exitCancelled
+	}
+
+	// Rollback leaves package managers alone; each is offered separately below
+	kept := platform.Rollback(runner, runtime.GOOS, manifest.Changes)
+	var failed []platform.Change
+	for _, c := range kept {
+		if c.Kind != platform.ChangePackageManager {
+			failed = append(failed, c)
+			continue
+		}
+		switch removed, err := uninstallPackageManager(runner, c, *yes || *dryRun, *removePackageManager || *dryRun); {
+		case err != nil:
+			ui.PrintWarning(fmt.Sprintf("Could not remove %s: %v", c, err))
+			failed = append(failed, c)
+		case removed:
+			ui.PrintSuccess(fmt.Sprintf("Removed %s", c))
+		default:
+			result.Left = append(result.Left, c)
+		}
+	}
+	if *dryRun {
+		return exitOK
+	}
+	for _, c := range manifest.Changes {
+		if !slices.Contains(failed, c) && !slices.Contains(result.Left, c) {
+			result.Removed = append(result.Removed, c)
+		}
+	}
+	result.Kept = append(result.Kept, failed...)
+
+	manifest.Changes = failed
+	if err := manifest.Save(); err != nil {
+		ui.PrintError(err.Error())
+		return exitError
+	}
+	ui.PrintResult(result)
+	if len(failed) > 0 {
+		ui.PrintError(fmt.Sprintf("%d item(s) could not be removed; they stay recorded in %s for the next attempt", len(failed), manifest.Path()))
+		return exitError
+	}
+	ui.PrintSuccess("Everything the installer added has been removed")
+	return exitOK
+}
+
+// uninstallPackageManager removes a package manager the installer added, but
+// only when nothing else is installed with it and the user explicitly agrees:
+// at the prompt, or with --remove-package-manager when --yes skips prompts. A
+// dry run shows its removal without asking. It reports whether the package
+// manager was removed.
+func uninstallPackageManager(r platform.Runner, c platform.Change, yes, confirmed bool) (bool, error) {
+	others, err := platform.OtherPackages(r, runtime.GOOS, c)
+	if err != nil {
+		ui.PrintInfo(fmt.Sprintf("Keeping %s: %v", c.Name, err))
+		return false, nil
+	}
+	if len(others) > 0 {
+		ui.PrintInfo(fmt.Sprintf("Keeping %s: %d other package(s) are installed with it", c.Name, len(others)))
+		return false, nil
+	}
+	if yes && !confirmed {
+		ui.PrintInfo(fmt.Sprintf("Keeping %s: pass --remove-package-manager to remove it too", c.Name))
+		return false, nil
+	}
+	if !yes && !ui.PromptYesNo(fmt.Sprintf("The installer also installed %s, and nothing else uses it now. Remove it?", c.Name), false) {
+		ui.PrintInfo(fmt.Sprintf("Keeping %s", c.Name))
+		return false, nil
+	}
+	if err := platform.Undo(r, runtime.GOOS, c); err != nil {
+		return false, err
+	}
+	return true, nil
+}